@@ -0,0 +1,25 @@
+package dhcpv6
+
+import (
+	"time"
+)
+
+// Clock is a source of time. It allows to replace the real time in tests of
+// the code depending on timers, such as retransmissions or lease expiry.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the Clock reading the system time.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
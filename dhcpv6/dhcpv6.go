@@ -1,8 +1,11 @@
 package dhcpv6
 
 import (
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"strings"
 
@@ -28,8 +31,41 @@ type DHCPv6 interface {
 // structures. This is used to simplify packet manipulation
 type Modifier func(d DHCPv6)
 
+// MaxRelayDepth is the maximum number of relay messages that may be nested in
+// each other. Parsing or decapsulating a message nested deeper fails, so that
+// a malicious packet cannot make the parser recurse without bound.
+var MaxRelayDepth = 32
+
+// MaxMessageBytes is the maximum size of a serialized message accepted by
+// MarshalBinary. It defaults to the largest UDP datagram.
+var MaxMessageBytes = 65535
+
+// checkMessageSize returns b, or an error if it exceeds MaxMessageBytes.
+func checkMessageSize(b []byte) ([]byte, error) {
+	if len(b) > MaxMessageBytes {
+		return nil, fmt.Errorf("message too long: %d bytes, limit is %d", len(b), MaxMessageBytes)
+	}
+	return b, nil
+}
+
+// relayParser returns an OptionParser for the options of a message enclosed
+// in depth relay messages, which keeps track of the depth of Relay Message
+// options.
+func relayParser(depth int) OptionParser {
+	return func(code OptionCode, data []byte) (Option, error) {
+		if code == OptionRelayMsg {
+			return parseOptRelayMsg(data, depth+1)
+		}
+		return ParseOption(code, data)
+	}
+}
+
 // MessageFromBytes parses a DHCPv6 message from a byte stream.
 func MessageFromBytes(data []byte) (*Message, error) {
+	return messageFromBytes(data, 0)
+}
+
+func messageFromBytes(data []byte, depth int) (*Message, error) {
 	buf := uio.NewBigEndianBuffer(data)
 	messageType := MessageType(buf.Read8())
 
@@ -41,14 +77,83 @@ func MessageFromBytes(data []byte) (*Message, error) {
 		MessageType: messageType,
 	}
 	buf.ReadBytes(d.TransactionID[:])
-	if err := d.Options.FromBytes(buf.Data()); err != nil {
+	if err := d.Options.FromBytesWithParser(buf.Data(), relayParser(depth)); err != nil {
 		return nil, err
 	}
 	return d, nil
 }
 
+// MessageFromBytesStrict is like MessageFromBytes, but also returns an error
+// if the parsed message fails Validate.
+func MessageFromBytesStrict(data []byte) (*Message, error) {
+	m, err := MessageFromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MessagesFromStream reads messages framed by a 2-byte big-endian length
+// prefix, as used by the TCP transport of RFC 5460, from r until EOF.
+//
+// The messages are parsed in a goroutine and sent on the first channel as soon
+// as they are read, so that a caller can handle the replies on a connection
+// the server keeps open. The channel is closed at EOF, when a frame is
+// truncated or cannot be parsed, or when ctx is done. The error channel then
+// yields the error, if any, and is closed once the goroutine returned.
+//
+// A caller that stops reading the messages early must cancel ctx for the
+// goroutine to return. If the goroutine is blocked reading r, r must also be
+// closed, or its read deadline set.
+func MessagesFromStream(ctx context.Context, r io.Reader) (<-chan *Message, <-chan error) {
+	ch := make(chan *Message)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(ch)
+		for n := 0; ; n++ {
+			var hdr [2]byte
+			if _, err := io.ReadFull(r, hdr[:]); err != nil {
+				if err != io.EOF {
+					errc <- fmt.Errorf("truncated length of frame %d: %v", n, err)
+				}
+				return
+			}
+			frame := make([]byte, binary.BigEndian.Uint16(hdr[:]))
+			if got, err := io.ReadFull(r, frame); err != nil {
+				errc <- fmt.Errorf("truncated frame %d: got %d of %d bytes: %v", n, got, len(frame), err)
+				return
+			}
+			m, err := MessageFromBytes(frame)
+			if err != nil {
+				errc <- fmt.Errorf("invalid frame %d: %v", n, err)
+				return
+			}
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return ch, errc
+}
+
 // RelayMessageFromBytes parses a relay message from a byte stream.
 func RelayMessageFromBytes(data []byte) (*RelayMessage, error) {
+	return relayMessageFromBytes(data, 0)
+}
+
+// relayMessageFromBytes parses a relay message enclosed in depth other relay
+// messages.
+func relayMessageFromBytes(data []byte, depth int) (*RelayMessage, error) {
+	if depth >= MaxRelayDepth {
+		return nil, fmt.Errorf("relay messages nested more than %d deep", MaxRelayDepth)
+	}
 	buf := uio.NewBigEndianBuffer(data)
 	messageType := MessageType(buf.Read8())
 
@@ -64,21 +169,30 @@ func RelayMessageFromBytes(data []byte) (*RelayMessage, error) {
 	d.PeerAddr = net.IP(buf.CopyN(net.IPv6len))
 
 	// TODO: fail if no OptRelayMessage is present.
-	if err := d.Options.FromBytes(buf.Data()); err != nil {
+	if err := d.Options.FromBytesWithParser(buf.Data(), relayParser(depth)); err != nil {
 		return nil, err
 	}
+	if opt, ok := d.GetOneOption(OptionRelayMsg).(*OptRelayMsg); ok {
+		if m, ok := opt.RelayMessage().(*Message); ok {
+			m.relay = d
+		}
+	}
 	return d, nil
 }
 
 // FromBytes reads a DHCPv6 message from a byte stream.
 func FromBytes(data []byte) (DHCPv6, error) {
+	return fromBytes(data, 0)
+}
+
+func fromBytes(data []byte, depth int) (DHCPv6, error) {
 	buf := uio.NewBigEndianBuffer(data)
 	messageType := MessageType(buf.Read8())
 
 	if messageType == MessageTypeRelayForward || messageType == MessageTypeRelayReply {
-		return RelayMessageFromBytes(data)
+		return relayMessageFromBytes(data, depth)
 	} else {
-		return MessageFromBytes(data)
+		return messageFromBytes(data, depth)
 	}
 }
 
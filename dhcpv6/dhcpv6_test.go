@@ -1,11 +1,15 @@
 package dhcpv6
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"io"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -29,7 +33,7 @@ func (s *GenerateTransactionIDTestSuite) SetupTest() {
 	s.random = make([]byte, 16)
 }
 
-func (s *GenerateTransactionIDTestSuite) TearDown() {
+func (s *GenerateTransactionIDTestSuite) TearDownTest() {
 	randomRead = rand.Read
 }
 
@@ -54,6 +58,27 @@ func (s *GenerateTransactionIDTestSuite) TestSuccess() {
 	s.Assert().Equal(TransactionID{0x1, 0x2, 0x3}, tid)
 }
 
+func (s *GenerateTransactionIDTestSuite) TestZero() {
+	// The first read yields the all-zero transaction ID, which is skipped.
+	reads := 0
+	randomRead = func(b []byte) (int, error) {
+		reads++
+		if reads == 1 {
+			return copy(b, []byte{0, 0, 0}), nil
+		}
+		return copy(b, []byte{0, 0, 1}), nil
+	}
+	tid, err := GenerateTransactionID()
+	s.Require().NoError(err)
+	s.Assert().Equal(TransactionID{0, 0, 1}, tid)
+	s.Assert().Equal(2, reads)
+
+	// A random source only ever yielding zero is an error.
+	randomRead = randomReadMock(s.random, 3, nil)
+	_, err = GenerateTransactionID()
+	s.Assert().Error(err)
+}
+
 func TestGenerateTransactionIDTestSuite(t *testing.T) {
 	suite.Run(t, new(GenerateTransactionIDTestSuite))
 }
@@ -129,6 +154,72 @@ func TestToBytes(t *testing.T) {
 	require.Equal(t, expected, bytes)
 }
 
+func TestFromBytesZeroCodeOption(t *testing.T) {
+	data := []byte{
+		01, 0xab, 0xcd, 0xef,
+		0x00, 0x08, 0x00, 0x02, 0x00, 0x2a, // Elapsed Time
+		0x00, 0x00, 0x00, 0x03, 0xde, 0xad, 0xbe, // stray option code 0
+		0x00, 0x07, 0x00, 0x01, 0xff, // Preference
+	}
+	d, err := MessageFromBytes(data)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(d.Options))
+	require.Equal(t, &OptionGeneric{OptionCode: 0, OptionData: []byte{0xde, 0xad, 0xbe}}, d.Options[1])
+	et, ok := d.GetOneOption(OptionElapsedTime).(*OptElapsedTime)
+	require.True(t, ok)
+	require.Equal(t, uint16(0x2a), et.ElapsedTime)
+	require.NotNil(t, d.GetOneOption(OptionPreference))
+	require.Equal(t, data, d.ToBytes())
+}
+
+func TestFromBytesTrailingBytes(t *testing.T) {
+	// Stray bytes after the last option are rejected in messages, relay
+	// messages and IAs alike.
+	opts := []byte{0x00, 0x08, 0x00, 0x02, 0x00, 0x2a} // Elapsed Time
+	for n := 1; n < 4; n++ {
+		trailing := make([]byte, n)
+
+		_, err := MessageFromBytes(append(append([]byte{01, 0xab, 0xcd, 0xef}, opts...), trailing...))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "too short for an option header")
+
+		relay := append([]byte{byte(MessageTypeRelayForward), 0}, make([]byte, 2*net.IPv6len)...)
+		_, err = RelayMessageFromBytes(append(append(relay, opts...), trailing...))
+		require.Error(t, err)
+
+		_, err = ParseOptIAForPrefixDelegation(append(append(make([]byte, iaHeaderLen), opts...), trailing...))
+		require.Error(t, err)
+	}
+}
+
+func TestFromBytesDoesNotRetainInput(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply, TransactionID: TransactionID{1, 2, 3}}
+	m.AddOption(&OptClientId{Cid: Duid{
+		Type:          DUID_LLT,
+		HwType:        iana.HWTypeEthernet,
+		Time:          42,
+		LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+	}})
+	m.AddOption(&OptServerId{Sid: Duid{Type: DUID_EN, EnterpriseNumber: 9, EnterpriseIdentifier: []byte("server")}})
+	m.AddOption(&OptionGeneric{OptionCode: 0xfe00, OptionData: []byte("generic")})
+	m.AddOption(&OptVendorOpts{EnterpriseNumber: 9, VendorOpts: Options{
+		&OptionGeneric{OptionCode: 1, OptionData: []byte("vendor")},
+	}})
+	m.AddOption(&OptNTPServer{Suboptions: Options{
+		&OptionGeneric{OptionCode: 99, OptionData: []byte("ntp")},
+	}})
+	expected := m.ToBytes()
+
+	data := append([]byte(nil), expected...)
+	d, err := MessageFromBytes(data)
+	require.NoError(t, err)
+	// Overwrite the input, as a caller reusing its read buffer would.
+	for i := range data {
+		data[i] = 0xff
+	}
+	require.Equal(t, expected, d.ToBytes())
+}
+
 func TestFromAndToBytes(t *testing.T) {
 	expected := []byte{01, 0xab, 0xcd, 0xef, 0x00, 0x00, 0x00, 0x00}
 	d, err := FromBytes(expected)
@@ -198,6 +289,36 @@ func TestNewReplyFromMessage(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestNewReplyFromRequest(t *testing.T) {
+	cid := Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	sid := Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 6}}
+	req := &Message{
+		MessageType:   MessageTypeRequest,
+		TransactionID: TransactionID{0xa, 0xb, 0xc},
+	}
+	req.AddOption(&OptClientId{Cid: cid})
+	req.AddOption(&OptServerId{Sid: sid})
+
+	rep, err := NewReplyFromRequest(req, WithServerID(sid))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeReply, rep.Type())
+	require.Equal(t, req.TransactionID, rep.TransactionID)
+	require.Equal(t, cid, rep.GetOneOption(OptionClientID).(*OptClientId).Cid)
+	require.Equal(t, sid, rep.GetOneOption(OptionServerID).(*OptServerId).Sid)
+	require.NoError(t, rep.Validate())
+
+	// The Server ID must be provided.
+	_, err = NewReplyFromRequest(req)
+	require.Error(t, err)
+
+	_, err = NewReplyFromRequest(nil, WithServerID(sid))
+	require.Error(t, err)
+
+	req.MessageType = MessageTypeRenew
+	_, err = NewReplyFromRequest(req, WithServerID(sid))
+	require.Error(t, err)
+}
+
 func TestNewMessageTypeSolicitWithCID(t *testing.T) {
 	hwAddr, err := net.ParseMAC("24:0A:9E:9F:EB:2B")
 	require.NoError(t, err)
@@ -278,3 +399,94 @@ func TestGetTransactionIDRelay(t *testing.T) {
 
 // TODO test NewMessageTypeSolicit
 //      test String and Summary
+
+func TestMessagesFromStream(t *testing.T) {
+	frame := func(m DHCPv6) []byte {
+		b := m.ToBytes()
+		return append([]byte{byte(len(b) >> 8), byte(len(b))}, b...)
+	}
+	m1 := &Message{MessageType: MessageTypeLeaseQueryReply, TransactionID: TransactionID{1, 2, 3}}
+	m1.AddOption(&OptElapsedTime{})
+	m2 := &Message{MessageType: MessageTypeLeaseQueryDone, TransactionID: TransactionID{1, 2, 3}}
+	stream := append(frame(m1), frame(m2)...)
+
+	read := func(ch <-chan *Message, errc <-chan error) ([]*Message, error) {
+		var msgs []*Message
+		for m := range ch {
+			msgs = append(msgs, m)
+		}
+		return msgs, <-errc
+	}
+
+	msgs, err := read(MessagesFromStream(context.Background(), bytes.NewReader(stream)))
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, m1.ToBytes(), msgs[0].ToBytes())
+	require.Equal(t, m2.ToBytes(), msgs[1].ToBytes())
+
+	msgs, err = read(MessagesFromStream(context.Background(), bytes.NewReader(nil)))
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// Truncated trailing frame.
+	msgs, err = read(MessagesFromStream(context.Background(), bytes.NewReader(stream[:len(stream)-1])))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "truncated frame 1")
+	require.Len(t, msgs, 1)
+
+	// The error of a frame cut short by the connection is reported.
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(stream[:len(stream)-1])
+		pw.CloseWithError(errors.New("connection reset"))
+	}()
+	msgs, err = read(MessagesFromStream(context.Background(), pr))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "truncated frame 1")
+	require.Contains(t, err.Error(), "connection reset")
+	require.Len(t, msgs, 1)
+
+	// Truncated length.
+	msgs, err = read(MessagesFromStream(context.Background(), bytes.NewReader(append(frame(m1), 0))))
+	require.Error(t, err)
+	require.Len(t, msgs, 1)
+
+	// Relay messages are not messages.
+	relay, err := EncapsulateRelay(m1, MessageTypeRelayForward, net.IPv6loopback, net.IPv6loopback)
+	require.NoError(t, err)
+	_, err = read(MessagesFromStream(context.Background(), bytes.NewReader(frame(relay))))
+	require.Error(t, err)
+
+	// Messages are delivered as they arrive, while the stream is still
+	// open.
+	pr, pw = io.Pipe()
+	ch, errc := MessagesFromStream(context.Background(), pr)
+	go pw.Write(frame(m1))
+	select {
+	case m := <-ch:
+		require.Equal(t, m1.ToBytes(), m.ToBytes())
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not delivered before the end of the stream")
+	}
+	go pw.Write(frame(m2))
+	require.Equal(t, m2.ToBytes(), (<-ch).ToBytes())
+	pw.Close()
+	msgs, err = read(ch, errc)
+	require.NoError(t, err)
+	require.Empty(t, msgs)
+
+	// A caller abandoning the messages midway cancels the context, which
+	// makes the goroutine return.
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, errc = MessagesFromStream(ctx, bytes.NewReader(stream))
+	require.Equal(t, m1.ToBytes(), (<-ch).ToBytes())
+	cancel()
+	select {
+	case err := <-errc:
+		require.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("goroutine did not return once the context was done")
+	}
+	_, ok := <-errc
+	require.False(t, ok)
+}
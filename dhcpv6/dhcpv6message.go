@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/insomniacslk/dhcp/iana"
@@ -18,21 +20,35 @@ type Message struct {
 	MessageType   MessageType
 	TransactionID TransactionID
 	Options       Options
+
+	// relay is the relay message this message was parsed from, if any.
+	relay *RelayMessage
 }
 
 var randomRead = rand.Read
 
+// maxTransactionIDAttempts is the number of times GenerateTransactionID reads
+// from the random source before giving up on getting a non-zero value.
+const maxTransactionIDAttempts = 8
+
 // GenerateTransactionID generates a random 3-byte transaction ID.
 func GenerateTransactionID() (TransactionID, error) {
 	var tid TransactionID
-	n, err := randomRead(tid[:])
-	if err != nil {
-		return tid, err
-	}
-	if n != len(tid) {
-		return tid, fmt.Errorf("invalid random sequence: shorter than 3 bytes")
+	// The all-zero transaction ID is valid on the wire, but is also the
+	// value of an uninitialized TransactionID, so it is never generated.
+	for i := 0; i < maxTransactionIDAttempts; i++ {
+		n, err := randomRead(tid[:])
+		if err != nil {
+			return tid, err
+		}
+		if n != len(tid) {
+			return tid, fmt.Errorf("invalid random sequence: shorter than 3 bytes")
+		}
+		if tid != (TransactionID{}) {
+			return tid, nil
+		}
 	}
-	return tid, nil
+	return tid, fmt.Errorf("invalid random sequence: got zero transaction ID %d times", maxTransactionIDAttempts)
 }
 
 // GetTime returns a time integer suitable for DUID-LLT, i.e. the current time counted
@@ -138,15 +154,23 @@ func NewRequestFromAdvertise(adv *Message, modifiers ...Modifier) (*Message, err
 	if sid == nil {
 		return nil, fmt.Errorf("Server ID cannot be nil in ADVERTISE when building REQUEST")
 	}
+	if opt, ok := sid.(*OptServerId); !ok {
+		return nil, fmt.Errorf("Server ID in ADVERTISE is malformed")
+	} else if err := opt.Sid.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Server ID in ADVERTISE: %v", err)
+	}
 	req.AddOption(sid)
 	// add Elapsed Time
 	req.AddOption(&OptElapsedTime{})
-	// add IA_NA
-	iaNa := adv.GetOneOption(OptionIANA)
-	if iaNa == nil {
-		return nil, fmt.Errorf("IA_NA cannot be nil in ADVERTISE when building REQUEST")
+	// add IA_NA and IA_PD
+	ias := adv.GetOption(OptionIANA)
+	ias = append(ias, adv.GetOption(OptionIAPD)...)
+	if len(ias) == 0 {
+		return nil, fmt.Errorf("IA_NA or IA_PD required in ADVERTISE when building REQUEST")
+	}
+	for _, ia := range ias {
+		req.AddOption(ZeroIATimers(ia))
 	}
-	req.AddOption(iaNa)
 	// add OptRequestedOption
 	oro := OptRequestedOption{}
 	oro.SetRequestedOptions([]OptionCode{
@@ -168,6 +192,95 @@ func NewRequestFromAdvertise(adv *Message, modifiers ...Modifier) (*Message, err
 	return req, nil
 }
 
+// ZeroIATimers returns a copy of ia with T1 and T2 set to 0 if it is an IA_NA
+// or an IA_PD, and ia itself otherwise. Clients should send zero timers, as
+// RFC 8415, Section 21.4 recommends, rather than echo those of a server,
+// which may not pass Validate.
+func ZeroIATimers(ia Option) Option {
+	switch opt := ia.(type) {
+	case *OptIANA:
+		cp := *opt
+		cp.T1, cp.T2 = 0, 0
+		return &cp
+	case *OptIAForPrefixDelegation:
+		cp := *opt
+		cp.T1, cp.T2 = 0, 0
+		return &cp
+	}
+	return ia
+}
+
+// NewRequestFromLease creates a new REQUEST packet asking the server of a
+// previously obtained lease, e.g. one loaded with LoadLease after a restart,
+// for the same addresses and prefixes, without soliciting again. The Request
+// has a new transaction ID, and its IAs carry the IAIDs, addresses and
+// prefixes of the lease with zero timers and lifetimes, leaving them to the
+// server.
+//
+// A lease holding IAs of several servers, as built with Lease.Merge, must be
+// split with Lease.Split first.
+func NewRequestFromLease(lease *Lease, modifiers ...Modifier) (*Message, error) {
+	if lease == nil {
+		return nil, errors.New("Lease cannot be nil")
+	}
+	if len(lease.merged) > 0 {
+		return nil, errors.New("Lease holds IAs of several servers; use Lease.Split")
+	}
+	if err := lease.ClientID.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Client ID in lease: %v", err)
+	}
+	if err := lease.ServerID.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Server ID in lease: %v", err)
+	}
+	if len(lease.IANA) == 0 && len(lease.IAPD) == 0 {
+		return nil, errors.New("IA_NA or IA_PD required in lease when building REQUEST")
+	}
+	tid, err := GenerateTransactionID()
+	if err != nil {
+		return nil, err
+	}
+	req := &Message{
+		MessageType:   MessageTypeRequest,
+		TransactionID: tid,
+	}
+	req.AddOption(&OptClientId{Cid: lease.ClientID})
+	req.AddOption(&OptServerId{Sid: lease.ServerID})
+	req.AddOption(&OptElapsedTime{})
+	for _, ia := range lease.IANA {
+		na := &OptIANA{IaId: ia.IaId}
+		for _, opt := range ia.Options.Get(OptionIAAddr) {
+			if addr, ok := opt.(*OptIAAddress); ok {
+				na.Options.Add(&OptIAAddress{IPv6Addr: addr.IPv6Addr})
+			}
+		}
+		req.AddOption(na)
+	}
+	for _, ia := range lease.IAPD {
+		pd := &OptIAForPrefixDelegation{IaId: ia.IaId}
+		for _, opt := range ia.Options.Get(OptionIAPrefix) {
+			if p, ok := opt.(*OptIAPrefix); ok {
+				prefix := &OptIAPrefix{}
+				prefix.SetPrefixLength(p.PrefixLength())
+				prefix.SetIPv6Prefix(p.IPv6Prefix())
+				pd.Options.Add(prefix)
+			}
+		}
+		req.AddOption(pd)
+	}
+	oro := OptRequestedOption{}
+	oro.SetRequestedOptions([]OptionCode{
+		OptionDNSRecursiveNameServer,
+		OptionDomainSearchList,
+	})
+	req.AddOption(&oro)
+
+	// apply modifiers
+	for _, mod := range modifiers {
+		mod(req)
+	}
+	return req, nil
+}
+
 // NewReplyFromMessage creates a new REPLY packet based on a
 // Message. The function is to be used when generating a reply to
 // REQUEST, CONFIRM, RENEW, REBIND, RELEASE and INFORMATION-REQUEST packets.
@@ -201,6 +314,87 @@ func NewReplyFromMessage(msg *Message, modifiers ...Modifier) (*Message, error)
 	return rep, nil
 }
 
+// NewReplyFromRequest creates a minimal valid REPLY to a REQUEST packet, e.g.
+// for server stubs. The REPLY echoes the Transaction ID and Client ID of req,
+// and must be given a Server ID by the modifiers, typically with
+// WithServerID.
+func NewReplyFromRequest(req *Message, modifiers ...Modifier) (*Message, error) {
+	if req == nil {
+		return nil, errors.New("REQUEST cannot be nil")
+	}
+	if req.Type() != MessageTypeRequest {
+		return nil, fmt.Errorf("The passed REQUEST must have REQUEST type set, got %s", req.Type())
+	}
+	rep, err := NewReplyFromMessage(req, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	if rep.GetOneOption(OptionServerID) == nil {
+		return nil, errors.New("Server ID cannot be nil when building REPLY")
+	}
+	return rep, nil
+}
+
+// IgnoredIAsError is returned by VerifyReply when a Reply does not answer
+// some of the IAs of the message it responds to.
+type IgnoredIAsError struct {
+	// IANA and IAPD are the IAIDs of the ignored IA_NAs and IA_PDs.
+	IANA [][4]byte
+	IAPD [][4]byte
+}
+
+func (e *IgnoredIAsError) Error() string {
+	var ias []string
+	for _, id := range e.IANA {
+		ias = append(ias, fmt.Sprintf("IA_NA %x", id))
+	}
+	for _, id := range e.IAPD {
+		ias = append(ias, fmt.Sprintf("IA_PD %x", id))
+	}
+	return "REPLY ignored " + strings.Join(ias, ", ")
+}
+
+// VerifyReply checks that reply answers each IA_NA and IA_PD of msg, as RFC
+// 8415, Section 18.3.2 requires of servers: reply must include an IA with the
+// same IAID, carrying either an address or prefix, or a status code. If it
+// does not, an *IgnoredIAsError listing the ignored IAIDs is returned.
+func VerifyReply(msg, reply *Message) error {
+	if msg == nil || reply == nil {
+		return errors.New("messages cannot be nil")
+	}
+	answered := func(opts Options, binding OptionCode) bool {
+		return opts.GetOne(binding) != nil || opts.GetOne(OptionStatusCode) != nil
+	}
+	nas := make(map[[4]byte]bool)
+	for _, opt := range reply.GetOption(OptionIANA) {
+		if ia, ok := opt.(*OptIANA); ok && answered(ia.Options, OptionIAAddr) {
+			nas[ia.IaId] = true
+		}
+	}
+	pds := make(map[[4]byte]bool)
+	for _, opt := range reply.GetOption(OptionIAPD) {
+		if ia, ok := opt.(*OptIAForPrefixDelegation); ok && answered(ia.Options, OptionIAPrefix) {
+			pds[ia.IaId] = true
+		}
+	}
+
+	var ignored IgnoredIAsError
+	for _, opt := range msg.GetOption(OptionIANA) {
+		if ia, ok := opt.(*OptIANA); ok && !nas[ia.IaId] {
+			ignored.IANA = append(ignored.IANA, ia.IaId)
+		}
+	}
+	for _, opt := range msg.GetOption(OptionIAPD) {
+		if ia, ok := opt.(*OptIAForPrefixDelegation); ok && !pds[ia.IaId] {
+			ignored.IAPD = append(ignored.IAPD, ia.IaId)
+		}
+	}
+	if len(ignored.IANA) > 0 || len(ignored.IAPD) > 0 {
+		return &ignored
+	}
+	return nil
+}
+
 // Type returns this message's message type.
 func (m Message) Type() MessageType {
 	return m.MessageType
@@ -285,17 +479,98 @@ func (m *Message) ToBytes() []byte {
 	return buf.Data()
 }
 
+// MarshalBinary is like ToBytes, but returns an error if the message fails
+// Validate, or if the serialized message is longer than MaxMessageBytes, which
+// could not be sent and may be an attempt at exhausting the resources of its
+// recipient.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return checkMessageSize(m.ToBytes())
+}
+
+// Validate checks the consistency of the message's options, calling Validate
+// on each option that implements Validator, e.g. to check the timers of
+// IA_NAs or the length of prefixes.
+func (m *Message) Validate() error {
+	return m.Options.Validate()
+}
+
+// ToBytesCanonical returns the serialized version of this message with its
+// options sorted by ascending option code, so that messages carrying the same
+// options in a different order serialize identically. Options sharing a code
+// keep their relative order. The message itself is not modified.
+//
+// Digests such as the one of the Authentication option (RFC 3315, Section
+// 21.4) must be computed over this canonical form, with the authentication
+// information field zeroed, so that client and server compute matching
+// values regardless of the order options were added in.
+func (m *Message) ToBytesCanonical() []byte {
+	opts := make(Options, len(m.Options))
+	copy(opts, m.Options)
+	sort.SliceStable(opts, func(i, j int) bool {
+		return opts[i].Code() < opts[j].Code()
+	})
+	canonical := Message{
+		MessageType:   m.MessageType,
+		TransactionID: m.TransactionID,
+		Options:       opts,
+	}
+	return canonical.ToBytes()
+}
+
 // GetOption returns the options associated with the code.
 func (m *Message) GetOption(code OptionCode) []Option {
 	return m.Options.Get(code)
 }
 
+// GetOptions returns the options associated with any of the codes, grouped by
+// code. It is more efficient than calling GetOption once per code.
+func (m *Message) GetOptions(codes ...OptionCode) map[OptionCode][]Option {
+	return m.Options.GetMany(codes...)
+}
+
 // GetOneOption returns the first associated option with the code from this
 // message.
 func (m *Message) GetOneOption(code OptionCode) Option {
 	return m.Options.GetOne(code)
 }
 
+// OptionCodes returns the codes of the top-level options of the message, in
+// order, with a code repeated for each option carrying it.
+func (m *Message) OptionCodes() []OptionCode {
+	codes := make([]OptionCode, 0, len(m.Options))
+	for _, opt := range m.Options {
+		codes = append(codes, opt.Code())
+	}
+	return codes
+}
+
+// HasOption returns whether the message has a top-level option with the code.
+func (m *Message) HasOption(code OptionCode) bool {
+	return m.Options.GetOne(code) != nil
+}
+
+// RelayContext returns the Interface-Id and Remote-Id options of the relay
+// message directly encapsulating this message, i.e. the ones inserted by the
+// relay agent closest to the client. Either may be nil if the relay message
+// does not carry the option.
+//
+// ok is false if the message was not parsed from a relay message.
+func (m *Message) RelayContext() (interfaceID, remoteID []byte, ok bool) {
+	if m.relay == nil {
+		return nil, nil, false
+	}
+	if opt, ok := m.relay.GetOneOption(OptionInterfaceID).(*OptInterfaceId); ok {
+		interfaceID = opt.InterfaceID()
+	}
+	if opt, ok := m.relay.GetOneOption(OptionRemoteID).(*OptRemoteId); ok {
+		remoteID = opt.RemoteID()
+	}
+	return interfaceID, remoteID, true
+}
+
 // IsRelay returns whether this is a relay message or not.
 func (m *Message) IsRelay() bool {
 	return false
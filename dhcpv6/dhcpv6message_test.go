@@ -1,8 +1,11 @@
 package dhcpv6
 
 import (
+	"net"
 	"testing"
+	"time"
 
+	"github.com/insomniacslk/dhcp/iana"
 	"github.com/stretchr/testify/require"
 )
 
@@ -32,3 +35,359 @@ func TestIsOptionRequested(t *testing.T) {
 	msg2.AddOption(&optro)
 	require.True(t, msg2.IsOptionRequested(OptionDNSRecursiveNameServer))
 }
+
+// newMessageWithOptions returns a message with n options, cycling through DNS
+// servers, domain search list, NTP server and a few other option codes.
+func newMessageWithOptions(n int) *Message {
+	codes := []OptionCode{
+		OptionDNSRecursiveNameServer,
+		OptionDomainSearchList,
+		OptionNTPServer,
+		OptionPreference,
+		OptionUserClass,
+	}
+	msg := &Message{}
+	for i := 0; i < n; i++ {
+		msg.AddOption(&OptionGeneric{OptionCode: codes[i%len(codes)]})
+	}
+	return msg
+}
+
+func TestGetOptions(t *testing.T) {
+	msg := newMessageWithOptions(7)
+
+	opts := msg.GetOptions(OptionDNSRecursiveNameServer, OptionNTPServer, OptionBootfileURL)
+	require.Len(t, opts, 2)
+	require.Len(t, opts[OptionDNSRecursiveNameServer], 2)
+	require.Len(t, opts[OptionNTPServer], 1)
+	require.Equal(t, msg.GetOption(OptionDNSRecursiveNameServer), opts[OptionDNSRecursiveNameServer])
+	require.NotContains(t, opts, OptionBootfileURL)
+
+	require.Empty(t, msg.GetOptions())
+}
+
+func BenchmarkGetOptions(b *testing.B) {
+	msg := newMessageWithOptions(20)
+	for i := 0; i < b.N; i++ {
+		msg.GetOptions(OptionDNSRecursiveNameServer, OptionDomainSearchList, OptionNTPServer)
+	}
+}
+
+func BenchmarkGetOptionRepeated(b *testing.B) {
+	msg := newMessageWithOptions(20)
+	for i := 0; i < b.N; i++ {
+		msg.GetOption(OptionDNSRecursiveNameServer)
+		msg.GetOption(OptionDomainSearchList)
+		msg.GetOption(OptionNTPServer)
+	}
+}
+
+func TestNewRequestFromAdvertiseIAs(t *testing.T) {
+	adv := Message{MessageType: MessageTypeAdvertise}
+	adv.AddOption(&OptClientId{})
+	adv.AddOption(&OptServerId{Sid: Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}})
+
+	_, err := NewRequestFromAdvertise(&adv)
+	require.Error(t, err, "an ADVERTISE without IAs cannot be requested")
+
+	adv.AddOption(&OptIAForPrefixDelegation{IaId: [4]byte{1, 2, 3, 4}})
+	req, err := NewRequestFromAdvertise(&adv)
+	require.NoError(t, err)
+	require.Nil(t, req.GetOneOption(OptionIANA))
+	require.NotNil(t, req.GetOneOption(OptionIAPD))
+
+	adv.AddOption(&OptIANA{IaId: [4]byte{1, 2, 3, 4}})
+	adv.AddOption(&OptIANA{IaId: [4]byte{5, 6, 7, 8}})
+	req, err = NewRequestFromAdvertise(&adv)
+	require.NoError(t, err)
+	require.Len(t, req.GetOption(OptionIANA), 2)
+	require.Len(t, req.GetOption(OptionIAPD), 1)
+
+	// The timers of the server are not echoed, and the ADVERTISE is
+	// left untouched.
+	na := &OptIANA{IaId: [4]byte{9, 9, 9, 9}, T1: 3600, T2: 5400, Options: Options{
+		&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 1800, ValidLifetime: 3600},
+	}}
+	adv.AddOption(na)
+	req, err = NewRequestFromAdvertise(&adv)
+	require.NoError(t, err)
+	for _, opt := range req.GetOption(OptionIANA) {
+		require.NoError(t, opt.(*OptIANA).Validate())
+		require.Equal(t, uint32(0), opt.(*OptIANA).T1)
+		require.Equal(t, uint32(0), opt.(*OptIANA).T2)
+	}
+	require.Equal(t, uint32(3600), na.T1)
+}
+
+func TestNewRequestFromAdvertiseServerID(t *testing.T) {
+	for _, tt := range []struct {
+		desc string
+		sid  Option
+	}{
+		{desc: "unknown DUID type", sid: &OptServerId{Sid: Duid{Type: 42, Opaque: []byte{1, 2}}}},
+		{desc: "empty DUID-LL", sid: &OptServerId{Sid: Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet}}},
+		{desc: "empty DUID-EN", sid: &OptServerId{Sid: Duid{Type: DUID_EN, EnterpriseNumber: 1}}},
+		{desc: "too long DUID-LLT", sid: &OptServerId{Sid: Duid{Type: DUID_LLT, LinkLayerAddr: make([]byte, 125)}}},
+		{desc: "unparsed", sid: &OptionGeneric{OptionCode: OptionServerID, OptionData: []byte{0}}},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			adv := Message{MessageType: MessageTypeAdvertise}
+			adv.AddOption(&OptClientId{})
+			adv.AddOption(tt.sid)
+			adv.AddOption(&OptIANA{IaId: [4]byte{1, 2, 3, 4}})
+			_, err := NewRequestFromAdvertise(&adv)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNewRequestFromLease(t *testing.T) {
+	reply := newTestReply()
+	reply.TransactionID = TransactionID{1, 2, 3}
+	reply.AddOption(&OptIANA{IaId: [4]byte{1}, T1: 1800, T2: 2880, Options: Options{
+		&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 3600, ValidLifetime: 7200},
+	}})
+	prefix := &OptIAPrefix{PreferredLifetime: 3600, ValidLifetime: 7200}
+	prefix.SetPrefixLength(56)
+	prefix.SetIPv6Prefix(net.ParseIP("2001:db8:1::"))
+	reply.AddOption(&OptIAForPrefixDelegation{IaId: [4]byte{2}, T1: 1800, T2: 2880, Options: Options{prefix}})
+	lease, err := NewLeaseFromReply(reply, time.Now())
+	require.NoError(t, err)
+
+	req, err := NewRequestFromLease(lease, WithUserClass([]byte("foo")))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeRequest, req.MessageType)
+	require.NotEqual(t, reply.TransactionID, req.TransactionID)
+	require.Equal(t, lease.ClientID, req.GetOneOption(OptionClientID).(*OptClientId).Cid)
+	require.Equal(t, lease.ServerID, req.GetOneOption(OptionServerID).(*OptServerId).Sid)
+	require.True(t, req.HasOption(OptionUserClass))
+
+	na := req.GetOneOption(OptionIANA).(*OptIANA)
+	require.Equal(t, [4]byte{1}, na.IaId)
+	require.Equal(t, Options{&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1")}}, na.Options)
+	pd := req.GetOneOption(OptionIAPD).(*OptIAForPrefixDelegation)
+	require.Equal(t, [4]byte{2}, pd.IaId)
+	require.Len(t, pd.Options, 1)
+	p := pd.Options[0].(*OptIAPrefix)
+	require.Equal(t, uint8(56), p.PrefixLength())
+	require.True(t, net.ParseIP("2001:db8:1::").Equal(p.IPv6Prefix()))
+	require.Zero(t, p.ValidLifetime)
+
+	// The lease is left untouched.
+	require.Equal(t, uint32(7200), lease.IAPD[0].Options[0].(*OptIAPrefix).ValidLifetime)
+}
+
+func TestNewRequestFromLeaseInvalid(t *testing.T) {
+	_, err := NewRequestFromLease(nil)
+	require.Error(t, err)
+
+	lease, err := NewLeaseFromReply(newTestReply(), time.Now())
+	require.NoError(t, err)
+	_, err = NewRequestFromLease(lease)
+	require.Error(t, err, "a lease without IAs")
+
+	lease.IANA = []*OptIANA{{IaId: [4]byte{1}}}
+	lease.ServerID = Duid{}
+	_, err = NewRequestFromLease(lease)
+	require.Error(t, err, "a lease without Server ID")
+}
+
+func TestToBytesCanonical(t *testing.T) {
+	m1 := Message{MessageType: MessageTypeSolicit, TransactionID: TransactionID{1, 2, 3}}
+	m1.AddOption(&OptElapsedTime{ElapsedTime: 1})
+	m1.AddOption(&OptionGeneric{OptionCode: OptionPreference, OptionData: []byte{1}})
+	m1.AddOption(&OptClientId{Cid: Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}})
+	m1.AddOption(&OptionGeneric{OptionCode: OptionPreference, OptionData: []byte{2}})
+
+	m2 := Message{MessageType: MessageTypeSolicit, TransactionID: TransactionID{1, 2, 3}}
+	m2.AddOption(m1.Options[2])
+	m2.AddOption(m1.Options[1])
+	m2.AddOption(m1.Options[0])
+	m2.AddOption(m1.Options[3])
+
+	require.NotEqual(t, m1.ToBytes(), m2.ToBytes())
+	require.Equal(t, m1.ToBytesCanonical(), m2.ToBytesCanonical())
+
+	// The message itself is left untouched.
+	require.Equal(t, OptionElapsedTime, m1.Options[0].Code())
+
+	canonical, err := MessageFromBytes(m1.ToBytesCanonical())
+	require.NoError(t, err)
+	codes := make([]OptionCode, 0, len(canonical.Options))
+	for _, opt := range canonical.Options {
+		codes = append(codes, opt.Code())
+	}
+	require.Equal(t, []OptionCode{OptionClientID, OptionPreference, OptionPreference, OptionElapsedTime}, codes)
+	require.Equal(t, []byte{1}, canonical.Options[1].ToBytes())
+	require.Equal(t, []byte{2}, canonical.Options[2].ToBytes())
+}
+
+func TestMarshalBinaryMaxMessageBytes(t *testing.T) {
+	m, err := NewMessage()
+	require.NoError(t, err)
+	b, err := m.MarshalBinary()
+	require.NoError(t, err)
+	require.Equal(t, m.ToBytes(), b)
+
+	// 5000 options of 20 bytes each exceed the limit.
+	for i := 0; i < 5000; i++ {
+		m.AddOption(&OptDNSRecursiveNameServer{NameServers: []net.IP{net.IPv6loopback}})
+	}
+	_, err = m.MarshalBinary()
+	require.Error(t, err)
+
+	r, err := EncapsulateRelay(m, MessageTypeRelayForward, net.IPv6zero, net.IPv6loopback)
+	require.NoError(t, err)
+	_, err = r.MarshalBinary()
+	require.Error(t, err)
+
+	defer func(old int) { MaxMessageBytes = old }(MaxMessageBytes)
+	MaxMessageBytes = 10
+	m, err = NewMessage(WithClientID(Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}))
+	require.NoError(t, err)
+	_, err = m.MarshalBinary()
+	require.Error(t, err)
+}
+
+func TestMessageOptionCodes(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	require.Empty(t, m.OptionCodes())
+	require.False(t, m.HasOption(OptionDNSRecursiveNameServer))
+
+	m.AddOption(&OptElapsedTime{})
+	m.AddOption(&OptDNSRecursiveNameServer{NameServers: []net.IP{net.ParseIP("2001:db8::53")}})
+	m.AddOption(&OptIANA{IaId: [4]byte{1}})
+	m.AddOption(&OptIANA{IaId: [4]byte{2}})
+	m.AddOption(&OptDomainSearchList{})
+	require.Equal(t, []OptionCode{
+		OptionElapsedTime,
+		OptionDNSRecursiveNameServer,
+		OptionIANA,
+		OptionIANA,
+		OptionDomainSearchList,
+	}, m.OptionCodes())
+	require.True(t, m.HasOption(OptionDNSRecursiveNameServer))
+	require.True(t, m.HasOption(OptionIANA))
+	require.False(t, m.HasOption(OptionNTPServer))
+}
+
+func TestMessageValidate(t *testing.T) {
+	cid := WithClientID(Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}})
+	valid, err := NewMessage(cid, WithIANA(OptIAAddress{
+		IPv6Addr:          net.ParseIP("2001:db8::1"),
+		PreferredLifetime: 3600,
+		ValidLifetime:     7200,
+	}))
+	require.NoError(t, err)
+	valid.AddOption(&OptElapsedTime{})
+	require.NoError(t, valid.Validate())
+	parsed, err := MessageFromBytesStrict(valid.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, valid.ToBytes(), parsed.ToBytes())
+
+	// An address nested in an IA_NA has its lifetimes inverted.
+	invalid, err := NewMessage(cid, WithIANA(OptIAAddress{
+		IPv6Addr:          net.ParseIP("2001:db8::1"),
+		PreferredLifetime: 7200,
+		ValidLifetime:     3600,
+	}))
+	require.NoError(t, err)
+	require.Error(t, invalid.Validate())
+	_, err = invalid.MarshalBinary()
+	require.Error(t, err)
+	_, err = MessageFromBytes(invalid.ToBytes())
+	require.NoError(t, err, "non-strict parsing does not validate")
+	_, err = MessageFromBytesStrict(invalid.ToBytes())
+	require.Error(t, err)
+
+	// Relayed messages are validated as well.
+	relay, err := EncapsulateRelay(invalid, MessageTypeRelayForward, net.IPv6zero, net.IPv6loopback)
+	require.NoError(t, err)
+	require.Error(t, relay.Validate())
+	_, err = relay.MarshalBinary()
+	require.Error(t, err)
+
+	// An empty DUID is invalid.
+	m, err := NewMessage(WithServerID(Duid{Type: DUID_LL}))
+	require.NoError(t, err)
+	require.Error(t, m.Validate())
+}
+
+func TestVerifyReply(t *testing.T) {
+	request := &Message{MessageType: MessageTypeRequest}
+	request.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 1}})
+	request.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 2}})
+	request.AddOption(&OptIAForPrefixDelegation{IaId: [4]byte{0, 0, 0, 3}})
+
+	reply := &Message{MessageType: MessageTypeReply}
+	reply.AddOption(&OptIANA{
+		IaId:    [4]byte{0, 0, 0, 1},
+		Options: Options{&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1")}},
+	})
+	reply.AddOption(&OptIAForPrefixDelegation{
+		IaId:    [4]byte{0, 0, 0, 3},
+		Options: Options{&OptStatusCode{StatusCode: iana.StatusNoPrefixAvail}},
+	})
+	err := VerifyReply(request, reply)
+	require.Error(t, err)
+	ignored, ok := err.(*IgnoredIAsError)
+	require.True(t, ok)
+	require.Equal(t, [][4]byte{{0, 0, 0, 2}}, ignored.IANA)
+	require.Empty(t, ignored.IAPD)
+	require.Equal(t, "REPLY ignored IA_NA 00000002", err.Error())
+
+	// An empty IA does not answer the request either.
+	reply.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 2}})
+	require.Error(t, VerifyReply(request, reply))
+
+	reply.AddOption(&OptIANA{
+		IaId:    [4]byte{0, 0, 0, 2},
+		Options: Options{&OptStatusCode{StatusCode: iana.StatusNoAddrsAvail}},
+	})
+	require.NoError(t, VerifyReply(request, reply))
+
+	require.Error(t, VerifyReply(nil, reply))
+}
+
+func TestRelayContext(t *testing.T) {
+	reply := &Message{MessageType: MessageTypeReply, TransactionID: TransactionID{1, 2, 3}}
+	_, _, ok := reply.RelayContext()
+	require.False(t, ok)
+
+	inner, err := EncapsulateRelay(reply, MessageTypeRelayReply, net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	iid := &OptInterfaceId{}
+	iid.SetInterfaceID([]byte("eth0"))
+	inner.AddOption(iid)
+	rid := &OptRemoteId{}
+	rid.SetEnterpriseNumber(123)
+	rid.SetRemoteID([]byte("port1"))
+	inner.AddOption(rid)
+	outer, err := EncapsulateRelay(inner, MessageTypeRelayReply, net.IPv6zero, net.ParseIP("fe80::2"))
+	require.NoError(t, err)
+
+	parsed, err := RelayMessageFromBytes(outer.ToBytes())
+	require.NoError(t, err)
+	m, err := parsed.GetInnerMessage()
+	require.NoError(t, err)
+	interfaceID, remoteID, ok := m.RelayContext()
+	require.True(t, ok)
+	require.Equal(t, []byte("eth0"), interfaceID)
+	require.Equal(t, []byte("port1"), remoteID)
+
+	// The outer relay does not carry the options.
+	parsed, err = RelayMessageFromBytes(outer.ToBytes())
+	require.NoError(t, err)
+	parsed.Options.Del(OptionRelayMsg)
+	orm := &OptRelayMsg{}
+	orm.SetRelayMessage(reply)
+	parsed.AddOption(orm)
+	parsed, err = RelayMessageFromBytes(parsed.ToBytes())
+	require.NoError(t, err)
+	m, err = parsed.GetInnerMessage()
+	require.NoError(t, err)
+	interfaceID, remoteID, ok = m.RelayContext()
+	require.True(t, ok)
+	require.Nil(t, interfaceID)
+	require.Nil(t, remoteID)
+}
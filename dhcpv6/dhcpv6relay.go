@@ -10,6 +10,11 @@ import (
 
 const RelayHeaderSize = 34
 
+// HopCountLimit is the maximum hop count of a relay message, as defined by RFC
+// 3315, Section 5.6. Relay agents must not relay a Relay-Forward message whose
+// hop count has reached it.
+const HopCountLimit = 32
+
 // RelayMessage is a DHCPv6 relay agent message as defined by RFC 3315 Section
 // 7.
 type RelayMessage struct {
@@ -64,6 +69,21 @@ func (r *RelayMessage) ToBytes() []byte {
 	return buf.Data()
 }
 
+// MarshalBinary is like ToBytes, but returns an error if the message fails
+// Validate, or if the serialized message is longer than MaxMessageBytes.
+func (r *RelayMessage) MarshalBinary() ([]byte, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return checkMessageSize(r.ToBytes())
+}
+
+// Validate checks the consistency of the relay message's options, including
+// the relayed message. See Message.Validate.
+func (r *RelayMessage) Validate() error {
+	return r.Options.Validate()
+}
+
 // GetOption returns the options associated with the code.
 func (r *RelayMessage) GetOption(code OptionCode) []Option {
 	return r.Options.Get(code)
@@ -90,21 +110,55 @@ func (r *RelayMessage) IsRelay() bool {
 	return true
 }
 
+// RelayNestingError is returned by RelayMessage.GetInnerMessage when the
+// nested relay messages do not end with a Message, e.g. when a relay message
+// has no Relay Message option, or relays are nested too deep.
+type RelayNestingError struct {
+	// Depth is the nesting depth of the faulty relay message, 0 being the
+	// outermost one.
+	Depth  int
+	Reason string
+}
+
+func (e *RelayNestingError) Error() string {
+	return fmt.Sprintf("invalid relay message at depth %d: %s", e.Depth, e.Reason)
+}
+
 // GetInnerMessage recurses into a relay message and extract and return the
-// inner Message. Return nil if none found (e.g. not a relay message).
+// inner Message.
+//
+// Each Relay Message option must carry either a relay message of the same
+// type as r, or the final Message. A *RelayNestingError is returned otherwise,
+// or if the relay messages are nested more than MaxRelayDepth deep.
 func (r *RelayMessage) GetInnerMessage() (*Message, error) {
-	var (
-		p   DHCPv6
-		err error
-	)
-	p = r
-	for {
-		p, err = DecapsulateRelay(p)
-		if err != nil {
-			return nil, err
+	relay := r
+	for depth := 0; ; depth++ {
+		if depth >= MaxRelayDepth {
+			return nil, &RelayNestingError{
+				Depth:  depth,
+				Reason: fmt.Sprintf("relay messages nested more than %d deep", MaxRelayDepth),
+			}
+		}
+		opt, ok := relay.GetOneOption(OptionRelayMsg).(*OptRelayMsg)
+		if !ok || opt.RelayMessage() == nil {
+			return nil, &RelayNestingError{Depth: depth, Reason: "no Relay Message option"}
 		}
-		if m, ok := p.(*Message); ok {
-			return m, nil
+		switch inner := opt.RelayMessage().(type) {
+		case *Message:
+			return inner, nil
+		case *RelayMessage:
+			if inner.MessageType != r.MessageType {
+				return nil, &RelayNestingError{
+					Depth:  depth,
+					Reason: fmt.Sprintf("%s carries a %s", r.MessageType, inner.MessageType),
+				}
+			}
+			relay = inner
+		default:
+			return nil, &RelayNestingError{
+				Depth:  depth,
+				Reason: fmt.Sprintf("unexpected relayed message %T", inner),
+			}
 		}
 	}
 }
@@ -159,3 +213,58 @@ func NewRelayReplFromRelayForw(relay *RelayMessage, msg *Message) (DHCPv6, error
 	}
 	return m, nil
 }
+
+// NewRelayForward creates a MessageTypeRelayForward encapsulating the passed
+// DHCPv6 message, which may itself be a relay message when nesting relays.
+//
+// RFC 3315, Section 20.1.1, requires a relay agent relaying a message received
+// from a client to set link-address to an address assigned to the client's
+// link, since servers use it to pick the subnet. Therefore a zero or
+// unspecified linkAddr is rejected unless d is a relay message, in which case
+// link-address may legitimately be zero.
+func NewRelayForward(d DHCPv6, linkAddr, peerAddr net.IP, modifiers ...Modifier) (*RelayMessage, error) {
+	if d == nil {
+		return nil, errors.New("The passed message cannot be nil")
+	}
+	if !d.IsRelay() && (linkAddr == nil || linkAddr.IsUnspecified()) {
+		return nil, errors.New("Link address cannot be zero when relaying a client message")
+	}
+	if peerAddr == nil {
+		peerAddr = net.IPv6unspecified
+	}
+	if linkAddr == nil {
+		linkAddr = net.IPv6unspecified
+	}
+	relay, err := EncapsulateRelay(d, MessageTypeRelayForward, linkAddr, peerAddr)
+	if err != nil {
+		return nil, err
+	}
+	for _, mod := range modifiers {
+		mod(relay)
+	}
+	return relay, nil
+}
+
+// SetHopAddrs sets the link-address and peer-address of the relay message
+// with the given hop count, walking nested relay messages starting from r.
+// Hop 0 is the relay agent closest to the client.
+func (r *RelayMessage) SetHopAddrs(hop uint8, linkAddr, peerAddr net.IP) error {
+	var p DHCPv6 = r
+	for p.IsRelay() {
+		relay := p.(*RelayMessage)
+		if relay.HopCount == hop {
+			if hop == 0 && (linkAddr == nil || linkAddr.IsUnspecified()) {
+				return errors.New("Link address cannot be zero when relaying a client message")
+			}
+			relay.LinkAddr = linkAddr
+			relay.PeerAddr = peerAddr
+			return nil
+		}
+		d, err := DecapsulateRelay(relay)
+		if err != nil {
+			return err
+		}
+		p = d
+	}
+	return fmt.Errorf("No relay message with hop count %d", hop)
+}
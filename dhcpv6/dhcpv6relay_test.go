@@ -114,3 +114,170 @@ func TestNewRelayRepFromRelayForw(t *testing.T) {
 	rr, err = NewRelayReplFromRelayForw(&rf, nil)
 	require.Error(t, err)
 }
+
+func TestNewRelayForward(t *testing.T) {
+	s, err := NewMessage()
+	require.NoError(t, err)
+
+	_, err = NewRelayForward(nil, net.ParseIP("2001:db8::1"), nil)
+	require.Error(t, err)
+	_, err = NewRelayForward(s, nil, net.ParseIP("fe80::1"))
+	require.Error(t, err)
+	_, err = NewRelayForward(s, net.IPv6unspecified, net.ParseIP("fe80::1"))
+	require.Error(t, err)
+
+	r, err := NewRelayForward(s, net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeRelayForward, r.Type())
+	require.Equal(t, uint8(0), r.HopCount)
+
+	// A relay of a relay may use a zero link address.
+	r2, err := NewRelayForward(r, nil, net.ParseIP("fe80::2"))
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), r2.HopCount)
+	require.True(t, r2.LinkAddr.Equal(net.IPv6unspecified))
+}
+
+func TestRelayMessageSetHopAddrs(t *testing.T) {
+	s, err := NewMessage()
+	require.NoError(t, err)
+	r1, err := NewRelayForward(s, net.ParseIP("2001:db8::1"), net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	r2, err := NewRelayForward(r1, nil, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, r2.SetHopAddrs(0, net.ParseIP("2001:db8:1::1"), net.ParseIP("fe80::a")))
+	require.NoError(t, r2.SetHopAddrs(1, net.ParseIP("2001:db8:2::1"), net.ParseIP("fe80::b")))
+	require.Error(t, r2.SetHopAddrs(0, net.IPv6zero, net.ParseIP("fe80::a")))
+	require.Error(t, r2.SetHopAddrs(2, net.ParseIP("2001:db8:3::1"), nil))
+
+	// Check that each hop's addresses serialize correctly.
+	parsed, err := FromBytes(r2.ToBytes())
+	require.NoError(t, err)
+	outer := parsed.(*RelayMessage)
+	require.Equal(t, uint8(1), outer.HopCount)
+	require.True(t, outer.LinkAddr.Equal(net.ParseIP("2001:db8:2::1")))
+	require.True(t, outer.PeerAddr.Equal(net.ParseIP("fe80::b")))
+
+	d, err := DecapsulateRelay(outer)
+	require.NoError(t, err)
+	inner := d.(*RelayMessage)
+	require.Equal(t, uint8(0), inner.HopCount)
+	require.True(t, inner.LinkAddr.Equal(net.ParseIP("2001:db8:1::1")))
+	require.True(t, inner.PeerAddr.Equal(net.ParseIP("fe80::a")))
+
+	m, err := outer.GetInnerMessage()
+	require.NoError(t, err)
+	require.Equal(t, s.TransactionID, m.TransactionID)
+}
+
+// nestRelays encapsulates d in n relay messages.
+func nestRelays(t *testing.T, d DHCPv6, n int) DHCPv6 {
+	for i := 0; i < n; i++ {
+		r, err := EncapsulateRelay(d, MessageTypeRelayForward, net.IPv6zero, net.IPv6loopback)
+		require.NoError(t, err)
+		d = r
+	}
+	return d
+}
+
+func TestRelayMessageMaxDepth(t *testing.T) {
+	m, err := NewMessage()
+	require.NoError(t, err)
+
+	// Nesting up to MaxRelayDepth is fine.
+	d, err := FromBytes(nestRelays(t, m, MaxRelayDepth).ToBytes())
+	require.NoError(t, err)
+	inner, err := d.GetInnerMessage()
+	require.NoError(t, err)
+	require.Equal(t, m.TransactionID, inner.TransactionID)
+
+	deep := nestRelays(t, m, MaxRelayDepth+1)
+	_, err = FromBytes(deep.ToBytes())
+	require.Error(t, err)
+	_, err = deep.GetInnerMessage()
+	require.Error(t, err)
+
+	// Far deeper relays fail cleanly as well.
+	_, err = FromBytes(nestRelays(t, m, 1000).ToBytes())
+	require.Error(t, err)
+
+	// A relay hidden in the options of a non-relay message counts too.
+	bogus, err := NewMessage()
+	require.NoError(t, err)
+	bogus.AddOption(&OptRelayMsg{relayMessage: nestRelays(t, m, MaxRelayDepth)})
+	_, err = FromBytes(nestRelays(t, bogus, 1).ToBytes())
+	require.Error(t, err)
+}
+
+func TestRelayMessageMaxDepthConfigurable(t *testing.T) {
+	defer func(old int) { MaxRelayDepth = old }(MaxRelayDepth)
+	MaxRelayDepth = 2
+
+	m, err := NewMessage()
+	require.NoError(t, err)
+	_, err = FromBytes(nestRelays(t, m, 2).ToBytes())
+	require.NoError(t, err)
+	_, err = FromBytes(nestRelays(t, m, 3).ToBytes())
+	require.Error(t, err)
+}
+
+func TestRelayMessageGetInnerMessageNesting(t *testing.T) {
+	nest := func(d DHCPv6, types ...MessageType) DHCPv6 {
+		for _, typ := range types {
+			r, err := EncapsulateRelay(d, typ, net.IPv6zero, net.IPv6loopback)
+			require.NoError(t, err)
+			d = r
+		}
+		return d
+	}
+	repl := MessageTypeRelayReply
+	fromBytes := func(d DHCPv6) *RelayMessage {
+		r, err := RelayMessageFromBytes(d.ToBytes())
+		require.NoError(t, err)
+		return r
+	}
+
+	reply, err := NewMessage()
+	require.NoError(t, err)
+	reply.MessageType = MessageTypeReply
+	m, err := fromBytes(nest(reply, repl, repl, repl)).GetInnerMessage()
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeReply, m.MessageType)
+	require.Equal(t, reply.TransactionID, m.TransactionID)
+
+	for _, tt := range []struct {
+		desc     string
+		relay    *RelayMessage
+		maxDepth int
+		depth    int
+	}{
+		{
+			desc:  "relays all the way down",
+			relay: fromBytes(nest(&RelayMessage{MessageType: repl, LinkAddr: net.IPv6zero, PeerAddr: net.IPv6zero}, repl, repl)),
+			depth: 2,
+		},
+		{
+			desc:  "RELAY-REPL carrying a RELAY-FORW",
+			relay: fromBytes(nest(reply, MessageTypeRelayForward, repl)),
+			depth: 0,
+		},
+		{
+			desc:     "too deep",
+			relay:    nest(reply, repl, repl, repl).(*RelayMessage),
+			maxDepth: 2,
+			depth:    2,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			if tt.maxDepth > 0 {
+				defer func(old int) { MaxRelayDepth = old }(MaxRelayDepth)
+				MaxRelayDepth = tt.maxDepth
+			}
+			_, err := tt.relay.GetInnerMessage()
+			nerr, ok := err.(*RelayNestingError)
+			require.True(t, ok, "got %v", err)
+			require.Equal(t, tt.depth, nerr.Depth)
+		})
+	}
+}
@@ -62,6 +62,35 @@ func (d *Duid) Length() int {
 	}
 }
 
+// maxDuidLength is the maximum length of a DUID, type code included, as
+// defined by RFC 8415, Section 11.1.
+const maxDuidLength = 2 + 128
+
+// Validate checks that the DUID is of a known type and carries the
+// identifier its type requires.
+func (d *Duid) Validate() error {
+	switch d.Type {
+	case DUID_LLT, DUID_LL:
+		if len(d.LinkLayerAddr) == 0 {
+			return fmt.Errorf("%s has an empty link-layer address", d.Type)
+		}
+	case DUID_EN:
+		if len(d.EnterpriseIdentifier) == 0 {
+			return fmt.Errorf("%s has an empty identifier", d.Type)
+		}
+	case DUID_UUID:
+		if len(d.Uuid) != 16 {
+			return fmt.Errorf("%s must have a 16 bytes UUID, got %d", d.Type, len(d.Uuid))
+		}
+	default:
+		return fmt.Errorf("unknown DUID type %d", d.Type)
+	}
+	if d.Length() > maxDuidLength {
+		return fmt.Errorf("%s is too long: %d bytes", d.Type, d.Length())
+	}
+	return nil
+}
+
 // Equal compares two Duid objects.
 func (d Duid) Equal(o Duid) bool {
 	if d.Type != o.Type ||
@@ -124,6 +153,9 @@ func DuidFromBytes(data []byte) (*Duid, error) {
 	if len(data) < 2 {
 		return nil, fmt.Errorf("Invalid DUID: shorter than 2 bytes")
 	}
+	// The fields below alias data, so take a copy to keep the DUID
+	// independent of the caller's buffer.
+	data = append([]byte(nil), data...)
 	d := Duid{}
 	d.Type = DuidType(binary.BigEndian.Uint16(data[0:2]))
 	if d.Type == DUID_LLT {
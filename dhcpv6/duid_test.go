@@ -146,3 +146,27 @@ func TestDuidEqualNotEqual(t *testing.T) {
 	}
 	require.False(t, d.Equal(o))
 }
+
+func TestDuidValidate(t *testing.T) {
+	valid := []Duid{
+		{Type: DUID_LLT, HwType: iana.HWTypeEthernet, Time: 1, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}},
+		{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}},
+		{Type: DUID_EN, EnterpriseNumber: 32473, EnterpriseIdentifier: []byte{1}},
+		{Type: DUID_UUID, Uuid: make([]byte, 16)},
+	}
+	for _, d := range valid {
+		require.NoError(t, d.Validate(), d.Type.String())
+	}
+
+	invalid := []Duid{
+		{Type: DUID_LLT, HwType: iana.HWTypeEthernet},
+		{Type: DUID_LL, HwType: iana.HWTypeEthernet},
+		{Type: DUID_EN, EnterpriseNumber: 32473},
+		{Type: DUID_UUID, Uuid: make([]byte, 15)},
+		{Type: DUID_EN, EnterpriseIdentifier: make([]byte, 125)},
+		{Type: 0, Opaque: []byte{1}},
+	}
+	for _, d := range invalid {
+		require.Error(t, d.Validate(), d.Type.String())
+	}
+}
@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package dhcpv6
+
+import (
+	"testing"
+)
+
+// truncatedIASeeds are IA_NA and IA_PD bodies whose options declare more
+// bytes than they have, as sent by some buggy relay agents.
+var truncatedIASeeds = [][]byte{
+	{1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 5, 0, 0x18, 0x20, 0x01},
+	{1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 26, 0, 25, 0, 0, 0, 2},
+	{1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 5},
+	{1, 0, 0, 0, 0, 0, 0, 1, 0, 0},
+	{0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 2, 0, 13, 0xff, 0xff},
+}
+
+func FuzzParseOptIANA(f *testing.F) {
+	for _, seed := range truncatedIASeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if opt, err := ParseOptIANA(data); err == nil {
+			opt.ToBytes()
+		}
+	})
+}
+
+func FuzzParseOptIAForPrefixDelegation(f *testing.F) {
+	for _, seed := range truncatedIASeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if opt, err := ParseOptIAForPrefixDelegation(data); err == nil {
+			opt.ToBytes()
+		}
+	})
+}
+
+func FuzzMessageFromBytes(f *testing.F) {
+	for _, seed := range truncatedIASeeds {
+		reply := []byte{byte(MessageTypeReply), 0xaa, 0xbb, 0xcc}
+		f.Add(append(append(reply, 0, 3, 0, byte(len(seed))), seed...))
+		f.Add(append(append(reply, 0, 25, 0, byte(len(seed))), seed...))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if msg, err := MessageFromBytes(data); err == nil {
+			msg.ToBytes()
+		}
+	})
+}
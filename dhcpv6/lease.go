@@ -0,0 +1,529 @@
+package dhcpv6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// Lease is the configuration a client obtained from a DHCPv6 server, as
+// extracted from the server's Reply.
+type Lease struct {
+	// ClientID and ServerID are the DUIDs of the client and of the server
+	// that granted the lease.
+	ClientID Duid
+	ServerID Duid
+
+	// IANA and IAPD are the identity associations for non-temporary
+	// addresses and for prefix delegation granted by the server.
+	IANA []*OptIANA
+	IAPD []*OptIAForPrefixDelegation
+
+	// DNS, DomainSearch and NTPServers are the other configuration
+	// parameters found in the Reply, if any.
+	DNS          []net.IP
+	DomainSearch []string
+	NTPServers   []net.IP
+
+	// Acquired is the time the Reply was received. IA timers and lifetimes
+	// are relative to it.
+	Acquired time.Time
+
+	// Reply is the message the lease was extracted from.
+	Reply *Message
+
+	// merged are the leases added with Merge.
+	merged []*Lease
+}
+
+// NewLeaseFromReply extracts a Lease from a Reply message received at the
+// given time.
+//
+// An error is returned if the Reply does not carry a Client ID and a Server ID,
+// or if it carries a Status Code option other than Success.
+func NewLeaseFromReply(reply *Message, acquired time.Time) (*Lease, error) {
+	if reply == nil {
+		return nil, errors.New("REPLY cannot be nil")
+	}
+	if reply.MessageType != MessageTypeReply {
+		return nil, fmt.Errorf("The passed REPLY must have REPLY type set")
+	}
+	cid, ok := reply.GetOneOption(OptionClientID).(*OptClientId)
+	if !ok {
+		return nil, errors.New("Client ID cannot be nil in REPLY")
+	}
+	sid, ok := reply.GetOneOption(OptionServerID).(*OptServerId)
+	if !ok {
+		return nil, errors.New("Server ID cannot be nil in REPLY")
+	}
+	if sc, ok := reply.GetOneOption(OptionStatusCode).(*OptStatusCode); ok && sc.StatusCode != iana.StatusSuccess {
+		return nil, fmt.Errorf("REPLY has status %s: %s", sc.StatusCode, sc.StatusMessage)
+	}
+
+	l := &Lease{
+		ClientID: cid.Cid,
+		ServerID: sid.Sid,
+		Acquired: acquired,
+		Reply:    reply,
+	}
+	for _, opt := range reply.Options {
+		switch o := opt.(type) {
+		case *OptIANA:
+			l.IANA = append(l.IANA, o)
+		case *OptIAForPrefixDelegation:
+			l.IAPD = append(l.IAPD, o)
+		case *OptDNSRecursiveNameServer:
+			l.DNS = append(l.DNS, o.NameServers...)
+		case *OptDomainSearchList:
+			if o.DomainSearchList != nil {
+				l.DomainSearch = append(l.DomainSearch, o.DomainSearchList.Labels...)
+			}
+		case *OptNTPServer:
+			l.NTPServers = append(l.NTPServers, o.ServerAddrs()...)
+		}
+	}
+	return l, nil
+}
+
+// Merge adds the IA_NAs and IA_PDs of other to the lease. It is meant for
+// split-scope deployments, in which a client holds IAs granted by different
+// servers: the IAs of other remain associated with the server that granted
+// them, see ServersByIAID. The other configuration parameters of other are
+// ignored.
+//
+// other must have the same Client ID, and none of the IAIDs of the lease.
+func (l *Lease) Merge(other *Lease) error {
+	if other == nil {
+		return errors.New("lease cannot be nil")
+	}
+	if !l.ClientID.Equal(other.ClientID) {
+		return fmt.Errorf("cannot merge the lease of client %s into the lease of client %s", other.ClientID.String(), l.ClientID.String())
+	}
+	servers := l.ServersByIAID()
+	for iaid := range other.ServersByIAID() {
+		if _, ok := servers[iaid]; ok {
+			return fmt.Errorf("IAID %x is already part of the lease", iaid)
+		}
+	}
+	l.IANA = append(l.IANA, other.IANA...)
+	l.IAPD = append(l.IAPD, other.IAPD...)
+	l.merged = append(l.merged, other)
+	return nil
+}
+
+// ServersByIAID returns the DUID of the server that granted each IA_NA and
+// IA_PD of the lease, by IAID: ServerID, unless the IA was added with Merge.
+func (l *Lease) ServersByIAID() map[[4]byte]Duid {
+	servers := make(map[[4]byte]Duid, len(l.IANA)+len(l.IAPD))
+	for _, ia := range l.IANA {
+		servers[ia.IaId] = l.ServerID
+	}
+	for _, ia := range l.IAPD {
+		servers[ia.IaId] = l.ServerID
+	}
+	for _, m := range l.merged {
+		for iaid, sid := range m.ServersByIAID() {
+			servers[iaid] = sid
+		}
+	}
+	return servers
+}
+
+// Split returns the leases the lease was built from: the lease itself without
+// the IAs added with Merge, followed by each lease added with Merge. Each of
+// them only holds IAs of a single server, so that messages such as the one
+// built by NewRequestFromLease are sent to the right server.
+func (l *Lease) Split() []*Lease {
+	if len(l.merged) == 0 {
+		return []*Lease{l}
+	}
+	merged := make(map[Option]bool)
+	var leases []*Lease
+	for _, m := range l.merged {
+		for _, ia := range m.IANA {
+			merged[ia] = true
+		}
+		for _, ia := range m.IAPD {
+			merged[ia] = true
+		}
+		leases = append(leases, m.Split()...)
+	}
+	own := *l
+	own.merged = nil
+	own.IANA, own.IAPD = nil, nil
+	for _, ia := range l.IANA {
+		if !merged[ia] {
+			own.IANA = append(own.IANA, ia)
+		}
+	}
+	for _, ia := range l.IAPD {
+		if !merged[ia] {
+			own.IAPD = append(own.IAPD, ia)
+		}
+	}
+	return append([]*Lease{&own}, leases...)
+}
+
+// Addresses returns the addresses of all IA_NA in the lease.
+func (l *Lease) Addresses() []net.IP {
+	var ips []net.IP
+	for _, ia := range l.IANA {
+		for _, opt := range ia.Options.Get(OptionIAAddr) {
+			if addr, ok := opt.(*OptIAAddress); ok {
+				ips = append(ips, addr.IPv6Addr)
+			}
+		}
+	}
+	return ips
+}
+
+// Prefixes returns the prefixes of all IA_PD in the lease.
+func (l *Lease) Prefixes() []net.IPNet {
+	var prefixes []net.IPNet
+	for _, ia := range l.IAPD {
+		for _, opt := range ia.Options.Get(OptionIAPrefix) {
+			if p, ok := opt.(*OptIAPrefix); ok {
+				prefixes = append(prefixes, net.IPNet{
+					IP:   p.IPv6Prefix(),
+					Mask: net.CIDRMask(int(p.PrefixLength()), 128),
+				})
+			}
+		}
+	}
+	return prefixes
+}
+
+// AddrConfig is the configuration of an address or prefix obtained in a
+// lease, in the form expected to assign it to an interface, e.g. with netlink.
+type AddrConfig struct {
+	IP        net.IP
+	PrefixLen int
+
+	// PreferredLifetime and ValidLifetime are the lifetimes of the address
+	// or prefix. Infinite lifetimes are 0xffffffff seconds.
+	PreferredLifetime time.Duration
+	ValidLifetime     time.Duration
+}
+
+// ToAddrConfig returns the configuration of the addresses of all IA_NA in the
+// lease, as /128s, followed by that of the prefixes of all IA_PD.
+func (l *Lease) ToAddrConfig() []AddrConfig {
+	var configs []AddrConfig
+	for _, ia := range l.IANA {
+		for _, opt := range ia.Options.Get(OptionIAAddr) {
+			if addr, ok := opt.(*OptIAAddress); ok {
+				configs = append(configs, AddrConfig{
+					IP:                addr.IPv6Addr,
+					PrefixLen:         8 * net.IPv6len,
+					PreferredLifetime: time.Duration(addr.PreferredLifetime) * time.Second,
+					ValidLifetime:     time.Duration(addr.ValidLifetime) * time.Second,
+				})
+			}
+		}
+	}
+	for _, ia := range l.IAPD {
+		for _, opt := range ia.Options.Get(OptionIAPrefix) {
+			if p, ok := opt.(*OptIAPrefix); ok {
+				configs = append(configs, AddrConfig{
+					IP:                p.IPv6Prefix(),
+					PrefixLen:         int(p.PrefixLength()),
+					PreferredLifetime: time.Duration(p.PreferredLifetime) * time.Second,
+					ValidLifetime:     time.Duration(p.ValidLifetime) * time.Second,
+				})
+			}
+		}
+	}
+	return configs
+}
+
+// NormalizeTimers fixes the T1 and T2 timers of the lease's IA_NAs when the
+// server left them to the client's discretion by setting them to zero, or when
+// they fail ValidateIANATimers. As recommended by RFC 8415, Section 18.2.4,
+// T1 and T2 are then set to 0.5 and 0.8 times the shortest preferred lifetime
+// of the IA_NA's addresses. IA_NAs without addresses are left untouched.
+func (l *Lease) NormalizeTimers() {
+	for _, ia := range l.IANA {
+		err := ValidateIANATimers(*ia)
+		if err == nil && (ia.T1 != 0 || ia.T2 != 0) {
+			continue
+		}
+		pref, ok := ia.shortestPreferredLifetime()
+		if !ok {
+			continue
+		}
+		t1, t2 := uint32(infiniteLifetime), uint32(infiniteLifetime)
+		if pref != infiniteLifetime {
+			t1, t2 = pref/2, uint32(uint64(pref)*4/5)
+		}
+		if err != nil {
+			log.Printf("IA_NA %x: %v; using T1=%d T2=%d", ia.IaId, err, t1, t2)
+		}
+		ia.T1, ia.T2 = t1, t2
+	}
+}
+
+// RenewOrRebind is the next action a client must take to extend a Lease.
+type RenewOrRebind int
+
+// Actions returned by Lease.NextAction.
+const (
+	ActionRenew RenewOrRebind = iota
+	ActionRebind
+)
+
+func (a RenewOrRebind) String() string {
+	switch a {
+	case ActionRenew:
+		return "Renew"
+	case ActionRebind:
+		return "Rebind"
+	}
+	return fmt.Sprintf("RenewOrRebind(%d)", int(a))
+}
+
+// farFuture is used as the time of actions scheduled at an infinite T1 or T2.
+var farFuture = time.Unix(1<<62, 0)
+
+// NextAction returns the next action scheduled for the lease, and when it is
+// due, based on the earliest T1 and T2 of its IA_NAs and IA_PDs:
+//
+//   - before T1, it is a Renew at T1;
+//   - between T1 and T2, while the Renew is in progress, a Rebind at T2;
+//   - once T2 has passed, a Renew, due now.
+//
+// Infinite timers are scheduled in the far future. IAs whose T1 and T2 are
+// zero, which leaves them to the client's discretion, are ignored; call
+// NormalizeTimers first to account for them.
+func (l *Lease) NextAction(now time.Time) (action RenewOrRebind, at time.Time) {
+	t1, t2 := uint32(infiniteLifetime), uint32(infiniteLifetime)
+	update := func(iaT1, iaT2 uint32) {
+		if iaT1 == 0 && iaT2 == 0 {
+			return
+		}
+		if iaT1 < t1 {
+			t1 = iaT1
+		}
+		if iaT2 < t2 {
+			t2 = iaT2
+		}
+	}
+	for _, ia := range l.IANA {
+		update(ia.T1, ia.T2)
+	}
+	for _, ia := range l.IAPD {
+		update(ia.T1, ia.T2)
+	}
+
+	deadline := func(t uint32) time.Time {
+		if t == infiniteLifetime {
+			return farFuture
+		}
+		return l.Acquired.Add(time.Duration(t) * time.Second)
+	}
+	renewAt, rebindAt := deadline(t1), deadline(t2)
+	switch {
+	case now.Before(renewAt):
+		return ActionRenew, renewAt
+	case now.Before(rebindAt):
+		return ActionRebind, rebindAt
+	default:
+		return ActionRenew, now
+	}
+}
+
+// MissingOptions returns the codes in want that the lease's Reply does not
+// carry, in the order of want, e.g. to request only those in the Option
+// Request option of the next Information-Request.
+func (l *Lease) MissingOptions(want []OptionCode) []OptionCode {
+	var missing []OptionCode
+	for _, code := range want {
+		if l.Reply == nil || l.Reply.GetOneOption(code) == nil {
+			missing = append(missing, code)
+		}
+	}
+	return missing
+}
+
+// Expiry returns the time the first of the lease's addresses and prefixes
+// reaches the end of its valid lifetime, i.e. when the interface must be
+// deconfigured at the latest if all attempts to extend the lease failed.
+// Infinite lifetimes, and leases without addresses or prefixes, expire in the
+// far future.
+func (l *Lease) Expiry() time.Time {
+	valid := uint32(infiniteLifetime)
+	for _, ia := range l.IANA {
+		for _, opt := range ia.Options.Get(OptionIAAddr) {
+			if addr, ok := opt.(*OptIAAddress); ok && addr.ValidLifetime < valid {
+				valid = addr.ValidLifetime
+			}
+		}
+	}
+	for _, ia := range l.IAPD {
+		for _, opt := range ia.Options.Get(OptionIAPrefix) {
+			if p, ok := opt.(*OptIAPrefix); ok && p.ValidLifetime < valid {
+				valid = p.ValidLifetime
+			}
+		}
+	}
+	if valid == infiniteLifetime {
+		return farFuture
+	}
+	return l.Acquired.Add(time.Duration(valid) * time.Second)
+}
+
+// WatchExpiry returns a channel that receives the lease's Expiry time once it
+// is reached, and is then closed. This is distinct from the T1 and T2 timers
+// of NextAction: it covers the case where all renewals failed, so that the
+// caller can deconfigure the interface.
+//
+// The channel is closed without receiving anything when stop is closed first,
+// e.g. because the lease was renewed and a new one must be watched instead.
+// stop may be nil if the watch is never to be stopped. The channel of a lease
+// that never expires never receives anything, and is only closed by stop.
+func (l *Lease) WatchExpiry(stop <-chan struct{}) <-chan time.Time {
+	return l.WatchExpiryWithClock(RealClock, stop)
+}
+
+// WatchExpiryWithClock is like WatchExpiry, but measures time with clock.
+func (l *Lease) WatchExpiryWithClock(clock Clock, stop <-chan struct{}) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	expiry := l.Expiry()
+	if expiry.Equal(farFuture) && stop == nil {
+		// Nothing to wait for.
+		return ch
+	}
+	go func() {
+		defer close(ch)
+		if expiry.Equal(farFuture) {
+			<-stop
+			return
+		}
+		select {
+		case <-clock.After(expiry.Sub(clock.Now())):
+			ch <- expiry
+		case <-stop:
+		}
+	}()
+	return ch
+}
+
+// NTPServerFQDNs returns the NTP server FQDNs found in the Reply, whose
+// addresses are not part of NTPServers. Use ResolveNTPServers to resolve
+// them.
+func (l *Lease) NTPServerFQDNs() []string {
+	if l.Reply == nil {
+		return nil
+	}
+	var names []string
+	for _, opt := range l.Reply.GetOption(OptionNTPServer) {
+		if ntp, ok := opt.(*OptNTPServer); ok {
+			names = append(names, ntp.FQDNs()...)
+		}
+	}
+	return names
+}
+
+// Resolver looks up the addresses of a host. *net.Resolver implements it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// ResolveNTPServers returns the addresses of all the NTP servers of the lease:
+// NTPServers, followed by the addresses of NTPServerFQDNs as looked up with r,
+// or net.DefaultResolver if r is nil.
+//
+// FQDNs that cannot be resolved are reported in the returned error, along
+// with the addresses that could be obtained.
+func (l *Lease) ResolveNTPServers(ctx context.Context, r Resolver) ([]net.IP, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	addrs := append([]net.IP(nil), l.NTPServers...)
+	var errs []string
+	for _, name := range l.NTPServerFQDNs() {
+		ipAddrs, err := r.LookupIPAddr(ctx, name)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		for _, a := range ipAddrs {
+			addrs = append(addrs, a.IP)
+		}
+	}
+	if len(errs) > 0 {
+		return addrs, fmt.Errorf("cannot resolve NTP servers: %s", strings.Join(errs, "; "))
+	}
+	return addrs, nil
+}
+
+// PrefixLease is a prefix delegated by a server in an IA_PD.
+type PrefixLease struct {
+	// IAID is the IAID of the IA_PD the prefix was delegated in.
+	IAID   [4]byte
+	Prefix net.IPNet
+
+	// PreferredLifetime and ValidLifetime are the lifetimes of the prefix.
+	// Infinite lifetimes are 0xffffffff seconds.
+	PreferredLifetime time.Duration
+	ValidLifetime     time.Duration
+
+	// Status is the status code of the prefix, or of its IA_PD if the
+	// prefix has none. It is StatusSuccess if neither has a status code.
+	Status iana.StatusCode
+
+	// Excluded is the sub-prefix excluded from Prefix by a PD Exclude
+	// option, or nil. It must not be assigned to any link but the one
+	// between the client and the delegating server.
+	Excluded *net.IPNet
+}
+
+// DelegatedPrefixes returns the prefixes of all IA_PD options of the message.
+func (m *Message) DelegatedPrefixes() []PrefixLease {
+	var prefixes []PrefixLease
+	for _, opt := range m.GetOption(OptionIAPD) {
+		iaPd, ok := opt.(*OptIAForPrefixDelegation)
+		if !ok {
+			continue
+		}
+		iaStatus := iana.StatusSuccess
+		if sc, ok := iaPd.GetOneOption(OptionStatusCode).(*OptStatusCode); ok {
+			iaStatus = sc.StatusCode
+		}
+		for _, o := range iaPd.Options.Get(OptionIAPrefix) {
+			p, ok := o.(*OptIAPrefix)
+			if !ok {
+				continue
+			}
+			status := iaStatus
+			if sc, ok := p.GetOneOption(OptionStatusCode).(*OptStatusCode); ok {
+				status = sc.StatusCode
+			}
+			pl := PrefixLease{
+				IAID: iaPd.IaId,
+				Prefix: net.IPNet{
+					IP:   p.IPv6Prefix(),
+					Mask: net.CIDRMask(int(p.PrefixLength()), 8*net.IPv6len),
+				},
+				PreferredLifetime: time.Duration(p.PreferredLifetime) * time.Second,
+				ValidLifetime:     time.Duration(p.ValidLifetime) * time.Second,
+				Status:            status,
+			}
+			if ex, ok := p.GetOneOption(OptionPDExclude).(*OptPDExclude); ok {
+				excluded, err := ex.ExcludedPrefix(pl.Prefix)
+				if err != nil {
+					log.Printf("IA_PD %x: ignoring PD Exclude of prefix %v: %v", iaPd.IaId, &pl.Prefix, err)
+				} else {
+					pl.Excluded = &excluded
+				}
+			}
+			prefixes = append(prefixes, pl)
+		}
+	}
+	return prefixes
+}
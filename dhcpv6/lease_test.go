@@ -0,0 +1,491 @@
+package dhcpv6
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestReply() *Message {
+	reply := &Message{MessageType: MessageTypeReply}
+	reply.AddOption(&OptClientId{Cid: Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}})
+	reply.AddOption(&OptServerId{Sid: Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{6, 5, 4, 3, 2, 1}}})
+	return reply
+}
+
+func TestNewLeaseFromReply(t *testing.T) {
+	reply := newTestReply()
+	reply.AddOption(&OptIANA{
+		IaId: [4]byte{1, 2, 3, 4},
+		T1:   1800,
+		T2:   2880,
+		Options: Options{&OptIAAddress{
+			IPv6Addr:          net.ParseIP("2001:db8::1"),
+			PreferredLifetime: 3600,
+			ValidLifetime:     7200,
+		}},
+	})
+	prefix := &OptIAPrefix{}
+	prefix.SetPrefixLength(56)
+	prefix.SetIPv6Prefix(net.ParseIP("2001:db8:1::"))
+	reply.AddOption(&OptIAForPrefixDelegation{
+		IaId:    [4]byte{1, 2, 3, 4},
+		Options: Options{prefix},
+	})
+	WithDNS(net.ParseIP("2001:db8::53"))(reply)
+	WithDomainSearchList("example.com")(reply)
+	reply.AddOption(&OptNTPServer{Suboptions: Options{
+		&OptionGeneric{OptionCode: NTPSuboptionSrvAddr, OptionData: net.ParseIP("2001:db8::123")},
+	}})
+
+	now := time.Now()
+	l, err := NewLeaseFromReply(reply, now)
+	require.NoError(t, err)
+	require.Equal(t, net.HardwareAddr{1, 2, 3, 4, 5, 6}, l.ClientID.LinkLayerAddr)
+	require.Equal(t, net.HardwareAddr{6, 5, 4, 3, 2, 1}, l.ServerID.LinkLayerAddr)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::1")}, l.Addresses())
+	require.Len(t, l.Prefixes(), 1)
+	require.Equal(t, "2001:db8:1::/56", l.Prefixes()[0].String())
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, l.DNS)
+	require.Equal(t, []string{"example.com"}, l.DomainSearch)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::123")}, l.NTPServers)
+	require.Equal(t, now, l.Acquired)
+	require.Equal(t, reply, l.Reply)
+}
+
+func TestNewLeaseFromReplyErrors(t *testing.T) {
+	_, err := NewLeaseFromReply(nil, time.Now())
+	require.Error(t, err)
+
+	_, err = NewLeaseFromReply(&Message{MessageType: MessageTypeAdvertise}, time.Now())
+	require.Error(t, err)
+
+	reply := newTestReply()
+	reply.Options.Del(OptionServerID)
+	_, err = NewLeaseFromReply(reply, time.Now())
+	require.Error(t, err, "a REPLY without Server ID is not a lease")
+
+	reply = newTestReply()
+	reply.AddOption(&OptStatusCode{StatusCode: iana.StatusNoAddrsAvail})
+	_, err = NewLeaseFromReply(reply, time.Now())
+	require.Error(t, err)
+}
+
+func TestLeaseToAddrConfig(t *testing.T) {
+	reply := newTestReply()
+	reply.AddOption(&OptIANA{
+		IaId: [4]byte{1, 2, 3, 4},
+		Options: Options{&OptIAAddress{
+			IPv6Addr:          net.ParseIP("2001:db8::1"),
+			PreferredLifetime: 3600,
+			ValidLifetime:     7200,
+		}},
+	})
+	prefix := &OptIAPrefix{PreferredLifetime: 1800, ValidLifetime: infiniteLifetime}
+	prefix.SetPrefixLength(56)
+	prefix.SetIPv6Prefix(net.ParseIP("2001:db8:1::"))
+	reply.AddOption(&OptIAForPrefixDelegation{
+		IaId:    [4]byte{1, 2, 3, 4},
+		Options: Options{prefix},
+	})
+
+	l, err := NewLeaseFromReply(reply, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []AddrConfig{
+		{
+			IP:                net.ParseIP("2001:db8::1"),
+			PrefixLen:         128,
+			PreferredLifetime: time.Hour,
+			ValidLifetime:     2 * time.Hour,
+		},
+		{
+			IP:                net.ParseIP("2001:db8:1::"),
+			PrefixLen:         56,
+			PreferredLifetime: 30 * time.Minute,
+			ValidLifetime:     infiniteLifetime * time.Second,
+		},
+	}, l.ToAddrConfig())
+
+	require.Empty(t, (&Lease{}).ToAddrConfig())
+}
+
+func TestLeaseNormalizeTimers(t *testing.T) {
+	ia := func(t1, t2 uint32, prefs ...uint32) *OptIANA {
+		opt := &OptIANA{T1: t1, T2: t2}
+		for _, pref := range prefs {
+			opt.AddOption(&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: pref, ValidLifetime: pref})
+		}
+		return opt
+	}
+	l := &Lease{IANA: []*OptIANA{
+		ia(1800, 2880, 3600),
+		ia(0, 0, 3600, 1000),
+		ia(2880, 1800, 3600),
+		ia(0, 0, infiniteLifetime),
+		ia(0, 0),
+	}}
+	l.NormalizeTimers()
+
+	got := make([][2]uint32, 0, len(l.IANA))
+	for _, opt := range l.IANA {
+		got = append(got, [2]uint32{opt.T1, opt.T2})
+	}
+	require.Equal(t, [][2]uint32{
+		{1800, 2880},
+		{500, 800},
+		{1800, 2880},
+		{infiniteLifetime, infiniteLifetime},
+		{0, 0},
+	}, got)
+}
+
+func TestDelegatedPrefixes(t *testing.T) {
+	_, p1, err := net.ParseCIDR("2001:db8:1::/48")
+	require.NoError(t, err)
+	_, p2, err := net.ParseCIDR("2001:db8:2::/56")
+	require.NoError(t, err)
+
+	reply := newTestReply()
+	WithIAPrefix(*p1, time.Hour, 2*time.Hour)(reply)
+	WithIAPrefix(*p2, time.Minute, time.Minute, &OptStatusCode{StatusCode: iana.StatusNoPrefixAvail})(reply)
+	reply.AddOption(&OptIAForPrefixDelegation{
+		IaId:    [4]byte{1, 2, 3, 4},
+		Options: Options{&OptStatusCode{StatusCode: iana.StatusNoBinding}, &OptIAPrefix{ipv6Prefix: net.IPv6zero}},
+	})
+
+	prefixes := reply.DelegatedPrefixes()
+	require.Len(t, prefixes, 3)
+	require.Equal(t, PrefixLease{
+		Prefix:            *p1,
+		PreferredLifetime: time.Hour,
+		ValidLifetime:     2 * time.Hour,
+		Status:            iana.StatusSuccess,
+	}, prefixes[0])
+	require.Equal(t, p2.String(), prefixes[1].Prefix.String())
+	require.Equal(t, iana.StatusNoPrefixAvail, prefixes[1].Status)
+	require.Equal(t, [4]byte{1, 2, 3, 4}, prefixes[2].IAID)
+	require.Equal(t, iana.StatusNoBinding, prefixes[2].Status)
+
+	// Prefixes survive a round trip through the wire format.
+	parsed, err := MessageFromBytes(reply.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, p1.String(), parsed.DelegatedPrefixes()[0].Prefix.String())
+}
+
+func TestLeaseNextAction(t *testing.T) {
+	acquired := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &Lease{
+		IANA: []*OptIANA{
+			{IaId: [4]byte{1}, T1: 1800, T2: 2880},
+			{IaId: [4]byte{2}, T1: 0, T2: 0},
+		},
+		IAPD: []*OptIAForPrefixDelegation{
+			{IaId: [4]byte{3}, T1: 3600, T2: 5760},
+		},
+		Acquired: acquired,
+	}
+
+	action, at := l.NextAction(acquired)
+	require.Equal(t, ActionRenew, action)
+	require.Equal(t, acquired.Add(1800*time.Second), at)
+
+	action, at = l.NextAction(acquired.Add(1800 * time.Second))
+	require.Equal(t, ActionRebind, action)
+	require.Equal(t, acquired.Add(2880*time.Second), at)
+
+	now := acquired.Add(time.Hour)
+	action, at = l.NextAction(now)
+	require.Equal(t, ActionRenew, action)
+	require.Equal(t, now, at)
+}
+
+func TestLeaseNextActionInfinite(t *testing.T) {
+	acquired := time.Now()
+	l := &Lease{
+		IANA:     []*OptIANA{{T1: 1800, T2: infiniteLifetime}},
+		Acquired: acquired,
+	}
+	action, at := l.NextAction(acquired.Add(time.Hour))
+	require.Equal(t, ActionRebind, action)
+	require.True(t, at.After(acquired.AddDate(100, 0, 0)))
+
+	l.IANA[0].T1 = infiniteLifetime
+	action, at = l.NextAction(acquired)
+	require.Equal(t, ActionRenew, action)
+	require.True(t, at.After(acquired.AddDate(100, 0, 0)))
+
+	// Without any timers, nothing is scheduled.
+	action, at = (&Lease{Acquired: acquired}).NextAction(acquired)
+	require.Equal(t, ActionRenew, action)
+	require.True(t, at.After(acquired.AddDate(100, 0, 0)))
+}
+
+func TestLeaseMissingOptions(t *testing.T) {
+	l, err := NewLeaseFromReply(newTestReply(), time.Now())
+	require.NoError(t, err)
+	WithDNS(net.ParseIP("2001:db8::53"))(l.Reply)
+
+	want := []OptionCode{OptionNTPServer, OptionDNSRecursiveNameServer, OptionDomainSearchList}
+	require.Equal(t, []OptionCode{OptionNTPServer, OptionDomainSearchList}, l.MissingOptions(want))
+	require.Empty(t, l.MissingOptions([]OptionCode{OptionDNSRecursiveNameServer}))
+	require.Equal(t, want, (&Lease{}).MissingOptions(want))
+}
+
+func TestLeaseMerge(t *testing.T) {
+	reply1 := newTestReply()
+	reply1.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 1}})
+	reply1.AddOption(&OptIAForPrefixDelegation{IaId: [4]byte{0, 0, 0, 2}})
+	lease, err := NewLeaseFromReply(reply1, time.Now())
+	require.NoError(t, err)
+
+	server2 := Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{7, 7, 7, 7, 7, 7}}
+	reply2 := newTestReply()
+	reply2.UpdateOption(&OptServerId{Sid: server2})
+	reply2.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 3}})
+	other, err := NewLeaseFromReply(reply2, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, lease.Merge(other))
+	require.Len(t, lease.IANA, 2)
+	require.Equal(t, map[[4]byte]Duid{
+		{0, 0, 0, 1}: lease.ServerID,
+		{0, 0, 0, 2}: lease.ServerID,
+		{0, 0, 0, 3}: server2,
+	}, lease.ServersByIAID())
+
+	split := lease.Split()
+	require.Len(t, split, 2)
+	require.Equal(t, lease.ServerID, split[0].ServerID)
+	require.Equal(t, []*OptIANA{reply1.GetOneOption(OptionIANA).(*OptIANA)}, split[0].IANA)
+	require.Len(t, split[0].IAPD, 1)
+	require.Equal(t, other, split[1])
+
+	_, err = NewRequestFromLease(lease)
+	require.Error(t, err)
+	req, err := NewRequestFromLease(split[1])
+	require.NoError(t, err)
+	require.Equal(t, server2, req.GetOneOption(OptionServerID).(*OptServerId).Sid)
+
+	// IAIDs must be unique.
+	require.Error(t, lease.Merge(other))
+	// Client IDs must match.
+	other.ClientID = server2
+	other.IANA = nil
+	require.Error(t, lease.Merge(other))
+	require.Error(t, lease.Merge(nil))
+}
+
+// fakeResolver resolves the host names of its map.
+type fakeResolver map[string][]net.IP
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: ip})
+	}
+	return addrs, nil
+}
+
+func TestLeaseResolveNTPServers(t *testing.T) {
+	fqdn := func(name string) Option {
+		return &OptionGeneric{
+			OptionCode: NTPSuboptionSrvFQDN,
+			OptionData: (&rfc1035label.Labels{Labels: []string{name}}).ToBytes(),
+		}
+	}
+	reply := newTestReply()
+	reply.AddOption(&OptNTPServer{Suboptions: Options{
+		&OptionGeneric{OptionCode: NTPSuboptionSrvAddr, OptionData: net.ParseIP("2001:db8::123")},
+		fqdn("ntp1.example.com"),
+	}})
+	reply.AddOption(&OptNTPServer{Suboptions: Options{fqdn("ntp2.example.com")}})
+	lease, err := NewLeaseFromReply(reply, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []string{"ntp1.example.com", "ntp2.example.com"}, lease.NTPServerFQDNs())
+
+	r := fakeResolver{
+		"ntp1.example.com": {net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+		"ntp2.example.com": {net.ParseIP("2001:db8::3")},
+	}
+	addrs, err := lease.ResolveNTPServers(context.Background(), r)
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{
+		net.ParseIP("2001:db8::123"),
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("2001:db8::3"),
+	}, addrs)
+
+	// Addresses are still returned when some FQDNs cannot be resolved.
+	delete(r, "ntp1.example.com")
+	addrs, err = lease.ResolveNTPServers(context.Background(), r)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "ntp1.example.com")
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::123"), net.ParseIP("2001:db8::3")}, addrs)
+
+	require.Nil(t, (&Lease{}).NTPServerFQDNs())
+}
+
+func TestLeaseExpiry(t *testing.T) {
+	acquired := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := &Lease{
+		IANA: []*OptIANA{{Options: Options{
+			&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 3600, ValidLifetime: 7200},
+		}}},
+		IAPD: []*OptIAForPrefixDelegation{{Options: Options{
+			&OptIAPrefix{PreferredLifetime: 1800, ValidLifetime: 5400},
+		}}},
+		Acquired: acquired,
+	}
+	require.Equal(t, acquired.Add(5400*time.Second), l.Expiry())
+
+	l.IAPD = nil
+	l.IANA[0].Options[0].(*OptIAAddress).ValidLifetime = infiniteLifetime
+	require.True(t, l.Expiry().After(acquired.AddDate(100, 0, 0)))
+}
+
+// fakeClock is a Clock whose time only changes with Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	}
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var waiters []fakeWaiter
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = waiters
+}
+
+func TestLeaseWatchExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := &Lease{
+		IANA: []*OptIANA{{Options: Options{
+			&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 1800, ValidLifetime: 3600},
+		}}},
+		Acquired: clock.Now(),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	ch := l.WatchExpiryWithClock(clock, stop)
+
+	clock.Advance(3599 * time.Second)
+	select {
+	case <-ch:
+		t.Fatal("lease expiry reported early")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case at, ok := <-ch:
+		require.True(t, ok)
+		require.Equal(t, l.Expiry(), at)
+	case <-time.After(5 * time.Second):
+		t.Fatal("lease expiry not reported")
+	}
+
+	// Stopping closes the channel without reporting the expiry, even once
+	// it is reached.
+	l.Acquired = clock.Now()
+	stop2 := make(chan struct{})
+	ch = l.WatchExpiryWithClock(clock, stop2)
+	clock.Advance(time.Hour / 2)
+	close(stop2)
+	_, ok := <-ch
+	require.False(t, ok)
+	clock.Advance(time.Hour)
+	_, ok = <-ch
+	require.False(t, ok)
+}
+
+func TestLeaseWatchExpiryInfinite(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := &Lease{
+		IANA: []*OptIANA{{Options: Options{
+			&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: infiniteLifetime, ValidLifetime: infiniteLifetime},
+		}}},
+		Acquired: clock.Now(),
+	}
+
+	// Without stop, the channel never fires.
+	ch := l.WatchExpiryWithClock(clock, nil)
+	clock.Advance(100 * 365 * 24 * time.Hour)
+	select {
+	case <-ch:
+		t.Fatal("infinite lease reported as expired")
+	default:
+	}
+
+	stop := make(chan struct{})
+	ch = l.WatchExpiryWithClock(clock, stop)
+	close(stop)
+	_, ok := <-ch
+	require.False(t, ok)
+}
+
+func TestDelegatedPrefixesExcluded(t *testing.T) {
+	_, delegated, err := net.ParseCIDR("2001:db8:1234::/48")
+	require.NoError(t, err)
+	_, excluded, err := net.ParseCIDR("2001:db8:1234:ab00::/56")
+	require.NoError(t, err)
+	pdExclude, err := NewOptPDExclude(*delegated, *excluded)
+	require.NoError(t, err)
+
+	reply := newTestReply()
+	WithIAPrefix(*delegated, time.Hour, time.Hour, pdExclude)(reply)
+	// An invalid PD Exclude is ignored.
+	WithIAPrefix(*excluded, time.Hour, time.Hour, &OptPDExclude{PrefixLength: 48, SubnetID: []byte{1}})(reply)
+
+	parsed, err := MessageFromBytes(reply.ToBytes())
+	require.NoError(t, err)
+	prefixes := parsed.DelegatedPrefixes()
+	require.Len(t, prefixes, 2)
+	require.NotNil(t, prefixes[0].Excluded)
+	require.Equal(t, excluded.String(), prefixes[0].Excluded.String())
+	require.Nil(t, prefixes[1].Excluded)
+}
@@ -0,0 +1,118 @@
+package dhcpv6
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// leaseFileVersion is the version of the format written by Lease.Save.
+// Version 2 adds the leases merged with Lease.Merge; files of version 1 are
+// still read.
+const leaseFileVersion = 2
+
+// leaseFile is the format of the files written by Lease.Save. The lease is
+// stored as the Reply it was extracted from, so that fields added to Lease
+// later on can be extracted from leases saved earlier.
+type leaseFile struct {
+	Version  int       `json:"version"`
+	Acquired time.Time `json:"acquired"`
+	Reply    []byte    `json:"reply"`
+
+	// Merged are the leases merged into the lease, as returned by
+	// Lease.Split.
+	Merged []mergedLeaseFile `json:"merged,omitempty"`
+}
+
+// mergedLeaseFile is the format of a lease merged into the lease of a
+// leaseFile.
+type mergedLeaseFile struct {
+	Acquired time.Time `json:"acquired"`
+	Reply    []byte    `json:"reply"`
+}
+
+// Save writes the lease to the file at path, e.g. for a client to load it with
+// LoadLease after a restart and try to keep its addresses. The file is
+// replaced atomically and only readable by its owner.
+//
+// The lease, as well as the leases merged into it with Merge, must have their
+// Reply set, as done by NewLeaseFromReply.
+func (l *Lease) Save(path string) error {
+	leases := l.Split()
+	for _, sl := range leases {
+		if sl.Reply == nil {
+			return errors.New("cannot save a lease without REPLY")
+		}
+	}
+	lf := leaseFile{
+		Version:  leaseFileVersion,
+		Acquired: l.Acquired,
+		Reply:    l.Reply.ToBytes(),
+	}
+	for _, m := range leases[1:] {
+		lf.Merged = append(lf.Merged, mergedLeaseFile{
+			Acquired: m.Acquired,
+			Reply:    m.Reply.ToBytes(),
+		})
+	}
+	data, err := json.Marshal(lf)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadLease reads a lease written by Lease.Save from the file at path.
+func LoadLease(path string) (*Lease, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var lf leaseFile
+	if err := json.Unmarshal(data, &lf); err != nil {
+		return nil, fmt.Errorf("invalid lease file %s: %v", path, err)
+	}
+	if lf.Version < 1 || lf.Version > leaseFileVersion {
+		return nil, fmt.Errorf("unsupported lease file version %d in %s", lf.Version, path)
+	}
+	l, err := leaseFromFile(lf.Reply, lf.Acquired)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lease file %s: %v", path, err)
+	}
+	for _, m := range lf.Merged {
+		ml, err := leaseFromFile(m.Reply, m.Acquired)
+		if err != nil {
+			return nil, fmt.Errorf("invalid merged lease in lease file %s: %v", path, err)
+		}
+		if err := l.Merge(ml); err != nil {
+			return nil, fmt.Errorf("invalid merged lease in lease file %s: %v", path, err)
+		}
+	}
+	return l, nil
+}
+
+// leaseFromFile extracts a lease from the REPLY stored in a lease file.
+func leaseFromFile(b []byte, acquired time.Time) (*Lease, error) {
+	reply, err := MessageFromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REPLY: %v", err)
+	}
+	return NewLeaseFromReply(reply, acquired)
+}
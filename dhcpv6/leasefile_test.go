@@ -0,0 +1,142 @@
+package dhcpv6
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaseSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lease.json")
+
+	reply := newTestReply()
+	reply.AddOption(&OptIANA{
+		IaId: [4]byte{1, 2, 3, 4},
+		T1:   1800,
+		T2:   2880,
+		Options: Options{&OptIAAddress{
+			IPv6Addr:          net.ParseIP("2001:db8::1"),
+			PreferredLifetime: 3600,
+			ValidLifetime:     7200,
+		}},
+	})
+	WithDNS(net.ParseIP("2001:db8::53"))(reply)
+	acquired := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+	l, err := NewLeaseFromReply(reply, acquired)
+	require.NoError(t, err)
+
+	require.NoError(t, l.Save(path))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	loaded, err := LoadLease(path)
+	require.NoError(t, err)
+	require.True(t, acquired.Equal(loaded.Acquired))
+	require.Equal(t, l.ServerID, loaded.ServerID)
+	require.Equal(t, l.Addresses(), loaded.Addresses())
+	require.Equal(t, l.DNS, loaded.DNS)
+	require.Equal(t, l.Reply.ToBytes(), loaded.Reply.ToBytes())
+
+	// Saving again replaces the file.
+	l.Acquired = acquired.Add(time.Hour)
+	require.NoError(t, l.Save(path))
+	loaded, err = LoadLease(path)
+	require.NoError(t, err)
+	require.True(t, l.Acquired.Equal(loaded.Acquired))
+
+	require.Error(t, (&Lease{}).Save(path))
+}
+
+func TestLeaseSaveLoadMerged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lease.json")
+
+	reply1 := newTestReply()
+	reply1.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 1}})
+	acquired := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+	l, err := NewLeaseFromReply(reply1, acquired)
+	require.NoError(t, err)
+
+	server2 := Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{7, 7, 7, 7, 7, 7}}
+	reply2 := newTestReply()
+	reply2.UpdateOption(&OptServerId{Sid: server2})
+	reply2.AddOption(&OptIAForPrefixDelegation{IaId: [4]byte{0, 0, 0, 2}})
+	other, err := NewLeaseFromReply(reply2, acquired.Add(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, l.Merge(other))
+
+	require.NoError(t, l.Save(path))
+	loaded, err := LoadLease(path)
+	require.NoError(t, err)
+	require.Len(t, loaded.IANA, 1)
+	require.Len(t, loaded.IAPD, 1)
+	require.Equal(t, l.ServersByIAID(), loaded.ServersByIAID())
+	split := loaded.Split()
+	require.Len(t, split, 2)
+	require.True(t, acquired.Equal(split[0].Acquired))
+	require.True(t, other.Acquired.Equal(split[1].Acquired))
+	require.Equal(t, reply2.ToBytes(), split[1].Reply.ToBytes())
+
+	// The merged leases must have their Reply set too.
+	other.Reply = nil
+	require.Error(t, l.Save(path))
+}
+
+func TestLoadLeaseVersion1(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lease.json")
+
+	reply := newTestReply()
+	reply.AddOption(&OptIANA{IaId: [4]byte{0, 0, 0, 1}})
+	data, err := json.Marshal(map[string]interface{}{
+		"version":  1,
+		"acquired": time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC),
+		"reply":    reply.ToBytes(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	loaded, err := LoadLease(path)
+	require.NoError(t, err)
+	require.Len(t, loaded.IANA, 1)
+	require.Len(t, loaded.Split(), 1)
+}
+
+func TestLoadLeaseErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lease")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lease.json")
+
+	_, err = LoadLease(path)
+	require.Error(t, err)
+
+	for _, data := range []string{
+		"not json",
+		`{"acquired": "2019-04-01T12:00:00Z", "reply": ""}`,
+		`{"version": 3, "acquired": "2019-04-01T12:00:00Z", "reply": ""}`,
+		`{"version": 1, "acquired": "2019-04-01T12:00:00Z", "reply": "BwAAAQ=="}`,
+		`{"version": 2, "acquired": "2019-04-01T12:00:00Z", "reply": "BwAAAAABAAoAAwABAQIDBAUGAAIACgADAAEGBQQDAgEAAwAMAAAAAQAAAAAAAAAA", "merged": [{"acquired": "2019-04-01T12:00:00Z", "reply": "BwAAAQ=="}]}`,
+		// The merged lease has the IAID of the lease.
+		`{"version": 2, "acquired": "2019-04-01T12:00:00Z", "reply": "BwAAAAABAAoAAwABAQIDBAUGAAIACgADAAEGBQQDAgEAAwAMAAAAAQAAAAAAAAAA", "merged": [{"acquired": "2019-04-01T12:00:00Z", "reply": "BwAAAAABAAoAAwABAQIDBAUGAAIACgADAAEGBQQDAgEAAwAMAAAAAQAAAAAAAAAA"}]}`,
+	} {
+		require.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+		_, err = LoadLease(path)
+		require.Error(t, err, data)
+	}
+}
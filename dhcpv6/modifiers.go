@@ -3,6 +3,7 @@ package dhcpv6
 import (
 	"log"
 	"net"
+	"time"
 
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/insomniacslk/dhcp/rfc1035label"
@@ -53,14 +54,24 @@ func WithUserClass(uc []byte) Modifier {
 	}
 }
 
-// WithArchType adds an arch type option to the packet
-func WithArchType(at iana.Arch) Modifier {
+// WithArchType adds an arch type option with the given arch types to the
+// packet
+func WithArchType(ats ...iana.Arch) Modifier {
 	return func(d DHCPv6) {
-		ao := OptClientArchType{ArchTypes: []iana.Arch{at}}
+		ao := OptClientArchType{ArchTypes: ats}
 		d.AddOption(&ao)
 	}
 }
 
+// WithNetworkInterfaceID adds a network interface identifier option, as sent
+// by PXE clients, to the packet
+func WithNetworkInterfaceID(typ, major, minor uint8) Modifier {
+	return func(d DHCPv6) {
+		nii := OptNetworkInterfaceId{type_: typ, major: major, minor: minor}
+		d.AddOption(&nii)
+	}
+}
+
 // WithIANA adds or updates an OptIANA option with the provided IAAddress
 // options
 func WithIANA(addrs ...OptIAAddress) Modifier {
@@ -77,6 +88,41 @@ func WithIANA(addrs ...OptIAAddress) Modifier {
 	}
 }
 
+// WithoutIANA removes the OptIANA options from the packet, e.g. from a Solicit
+// built by NewSolicitWithCID, for a client that only requests delegated
+// prefixes.
+func WithoutIANA() Modifier {
+	return func(d DHCPv6) {
+		msg, ok := d.(*Message)
+		if !ok {
+			log.Printf("WithoutIANA: not a Message")
+			return
+		}
+		msg.Options.Del(OptionIANA)
+	}
+}
+
+// WithIAPrefix adds an OptIAPrefix for the given prefix and lifetimes to the
+// OptIAForPrefixDelegation option, adding the latter if not present. See
+// NewOptIAPrefix for the validation performed; invalid prefixes are logged and
+// not added.
+func WithIAPrefix(prefix net.IPNet, preferred, valid time.Duration, opts ...Option) Modifier {
+	return func(d DHCPv6) {
+		iaPrefix, err := NewOptIAPrefix(prefix, preferred, valid, opts...)
+		if err != nil {
+			log.Printf("WithIAPrefix: %v", err)
+			return
+		}
+		opt := d.GetOneOption(OptionIAPD)
+		if opt == nil {
+			opt = &OptIAForPrefixDelegation{}
+		}
+		iaPd := opt.(*OptIAForPrefixDelegation)
+		iaPd.Options.Add(iaPrefix)
+		d.UpdateOption(iaPd)
+	}
+}
+
 // WithDNS adds or updates an OptDNSRecursiveNameServer
 func WithDNS(dnses ...net.IP) Modifier {
 	return func(d DHCPv6) {
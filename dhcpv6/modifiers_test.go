@@ -4,6 +4,7 @@ import (
 	"log"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/insomniacslk/dhcp/iana"
 	"github.com/stretchr/testify/require"
@@ -64,6 +65,36 @@ func TestWithIANA(t *testing.T) {
 	require.Equal(t, OptionIANA, d.Options[0].Code())
 }
 
+func TestWithoutIANA(t *testing.T) {
+	m, err := NewSolicitWithCID(Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}},
+		WithoutIANA(),
+		WithIAPrefix(net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(56, 128)}, 0, 0))
+	require.NoError(t, err)
+	require.Empty(t, m.GetOption(OptionIANA))
+	require.Len(t, m.GetOption(OptionIAPD), 1)
+
+	// Relay messages are left alone.
+	r := &RelayMessage{}
+	r.AddOption(&OptIANA{})
+	WithoutIANA()(r)
+	require.Len(t, r.GetOption(OptionIANA), 1)
+}
+
+func TestWithIAPrefix(t *testing.T) {
+	var d Message
+	_, prefix, err := net.ParseCIDR("2001:db8:1::/48")
+	require.NoError(t, err)
+	WithIAPrefix(*prefix, time.Hour, 2*time.Hour)(&d)
+	WithIAPrefix(*prefix, time.Hour, 2*time.Hour)(&d)
+	require.Equal(t, 1, len(d.Options))
+	iaPd := d.Options[0].(*OptIAForPrefixDelegation)
+	require.Len(t, iaPd.Options.Get(OptionIAPrefix), 2)
+
+	// Invalid prefixes are not added.
+	WithIAPrefix(net.IPNet{IP: net.ParseIP("2001:db8:1::1"), Mask: net.CIDRMask(48, 128)}, time.Hour, time.Hour)(&d)
+	require.Len(t, iaPd.Options.Get(OptionIAPrefix), 2)
+}
+
 func TestWithDNS(t *testing.T) {
 	var d Message
 	WithDNS([]net.IP{
@@ -95,3 +126,25 @@ func TestWithDomainSearchList(t *testing.T) {
 	require.Equal(t, "slackware.it", labels[0])
 	require.Equal(t, "dhcp.slackware.it", labels[1])
 }
+
+func TestWithArchType(t *testing.T) {
+	m, err := NewMessage(WithArchType(iana.EFI_X86_64, iana.INTEL_X86PC))
+	require.NoError(t, err)
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	opt, ok := parsed.GetOneOption(OptionClientArchType).(*OptClientArchType)
+	require.True(t, ok)
+	require.Equal(t, []iana.Arch{iana.EFI_X86_64, iana.INTEL_X86PC}, opt.ArchTypes)
+}
+
+func TestWithNetworkInterfaceID(t *testing.T) {
+	m, err := NewMessage(WithNetworkInterfaceID(NII_UNDI_EFI_GEN_II, 3, 10))
+	require.NoError(t, err)
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	opt, ok := parsed.GetOneOption(OptionNII).(*OptNetworkInterfaceId)
+	require.True(t, ok)
+	require.Equal(t, uint8(NII_UNDI_EFI_GEN_II), opt.Type())
+	require.Equal(t, uint8(3), opt.Major())
+	require.Equal(t, uint8(10), opt.Minor())
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// Attribution identifies the downstream client a transaction is performed on
+// behalf of, e.g. when the Client is used by a relay or a proxy to forward
+// the requests of other clients.
+type Attribution struct {
+	// Interface is the name of the interface the downstream client's
+	// request was received on.
+	Interface string
+
+	// ClientMAC is the hardware address of the downstream client.
+	ClientMAC net.HardwareAddr
+}
+
+func (a Attribution) String() string {
+	return fmt.Sprintf("Attribution{Interface=%s, ClientMAC=%s}", a.Interface, a.ClientMAC)
+}
+
+// AttributionKey is the context key under which the Attribution of a
+// transaction is stored. Hooks receiving the context of a transaction, such as
+// the one configured with WithOutgoingContextHook, retrieve it with
+//
+//	a, ok := ctx.Value(AttributionKey{}).(Attribution)
+//
+// or, equivalently, with AttributionFromContext.
+type AttributionKey struct{}
+
+// ContextWithAttribution returns a copy of ctx carrying a.
+func ContextWithAttribution(ctx context.Context, a Attribution) context.Context {
+	return context.WithValue(ctx, AttributionKey{}, a)
+}
+
+// AttributionFromContext returns the Attribution carried by ctx, if any.
+func AttributionFromContext(ctx context.Context) (Attribution, bool) {
+	a, ok := ctx.Value(AttributionKey{}).(Attribution)
+	return a, ok
+}
+
+// SendAndReadWithCtx is like SendAndRead, but performs the exchange on behalf
+// of the downstream client identified by a. a is stored in the context of the
+// transaction, where hooks can retrieve it with AttributionFromContext, and is
+// set in the TraceEvents of the exchange.
+func (c *Client) SendAndReadWithCtx(ctx context.Context, a Attribution, dest *net.UDPAddr, p *dhcpv6.Message, match Matcher) (*dhcpv6.Message, error) {
+	return c.SendAndRead(ContextWithAttribution(ctx, a), dest, p, match)
+}
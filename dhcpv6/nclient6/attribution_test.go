@@ -0,0 +1,81 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributionFromContext(t *testing.T) {
+	_, ok := AttributionFromContext(context.Background())
+	require.False(t, ok)
+
+	want := Attribution{Interface: "eth1", ClientMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	got, ok := AttributionFromContext(ContextWithAttribution(context.Background(), want))
+	require.True(t, ok)
+	require.Equal(t, want, got)
+}
+
+func TestSendAndReadWithCtx(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	reply := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})
+
+	var (
+		mu   sync.Mutex
+		seen []Attribution
+	)
+	hook := func(ctx context.Context, m *dhcpv6.Message) {
+		a, ok := AttributionFromContext(ctx)
+		require.True(t, ok)
+		mu.Lock()
+		seen = append(seen, a)
+		mu.Unlock()
+	}
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{reply}}, WithOutgoingContextHook(hook))
+	defer mc.Close()
+
+	a := Attribution{Interface: "eth1", ClientMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	rcvd, err := mc.SendAndReadWithCtx(context.Background(), a, AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, reply))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []Attribution{a}, seen)
+}
+
+func TestAttributionTrace(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, fakeServer)
+
+	var events []TraceEvent
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second),
+		WithTrace(func(e TraceEvent) { events = append(events, e) }))
+	defer mc.Close()
+
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	adv.AddOption(&dhcpv6.OptUnicast{ServerAddr: net.ParseIP("2001:db8::547")})
+
+	a := Attribution{Interface: "eth1", ClientMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	_, err = mc.Request(ContextWithAttribution(context.Background(), a), adv)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, TraceUnicastAccepted, events[0].Type)
+	require.Equal(t, &a, events[0].Attribution)
+	require.Contains(t, events[0].String(), "for Attribution{Interface=eth1")
+}
@@ -0,0 +1,2363 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+// Package nclient6 is a small, minimum-functionality client for DHCPv6.
+//
+// It only supports the 4-way DHCPv6 Solicit-Advertise-Request-Reply handshake.
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+const (
+	defaultTimeout   = 5 * time.Second
+	defaultRetries   = 3
+	defaultBufferCap = 5
+	maxMessageSize   = 1500
+
+	// defaultMaxTimeout is SOL_MAX_RT, as defined by RFC 3315, Section 5.5.
+	defaultMaxTimeout = 120 * time.Second
+)
+
+// readBufferPool holds the read buffers of receive loops configured with
+// WithReadBufferPool.
+var readBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, maxMessageSize)
+		return &b
+	},
+}
+
+var (
+	// AllDHCPRelayAgentsAndServers is the link-local multicast address of
+	// all DHCPv6 relay agents and servers, as defined by RFC 3315.
+	AllDHCPRelayAgentsAndServers = &net.UDPAddr{
+		IP:   net.ParseIP("ff02::1:2"),
+		Port: dhcpv6.DefaultServerPort,
+	}
+
+	// AllDHCPServers is the site-local multicast address of all DHCPv6
+	// servers, as defined by RFC 3315.
+	AllDHCPServers = &net.UDPAddr{
+		IP:   net.ParseIP("ff05::1:3"),
+		Port: dhcpv6.DefaultServerPort,
+	}
+)
+
+var (
+	// ErrNoResponse is returned when no response packet is received.
+	ErrNoResponse = errors.New("no matching response packet received")
+
+	// ErrTooManyPending is returned when a message cannot be sent because
+	// the number of pending transactions configured by WithMaxPending is
+	// reached.
+	ErrTooManyPending = errors.New("too many pending transactions")
+
+	// ErrWindowIncomplete is returned by SolicitAll along with the
+	// Advertises received so far when its context is done before the end
+	// of the window.
+	ErrWindowIncomplete = errors.New("solicit window ended early")
+
+	// ErrSendOnly is returned when waiting for a response with a Client
+	// configured with WithSendOnly.
+	ErrSendOnly = errors.New("client is send-only")
+
+	// ErrClosed is returned when sending a message with a Client that was
+	// closed, e.g. after the idle timeout configured with WithIdleTimeout.
+	ErrClosed = errors.New("client is closed")
+)
+
+// Received is a message distributed to a pending transaction, along with the
+// address it was received from.
+type Received struct {
+	Message *dhcpv6.Message
+	Source  net.Addr
+}
+
+// pendingCh is a channel associated with a pending TransactionID.
+type pendingCh struct {
+	// SendAndRead closes done to indicate that it wishes for no more
+	// messages for this particular XID.
+	done <-chan struct{}
+
+	// ch is used by the receive loop to distribute DHCP messages.
+	ch chan<- Received
+
+	// match, if set, filters the messages distributed on ch.
+	match Matcher
+
+	// expected is true for entries registered with Expect, and cancel
+	// removes the entry.
+	expected bool
+	cancel   func()
+
+	// observe, if set, is called with each message distributed on ch and
+	// the address it was received from, before it is distributed.
+	observe func(msg *dhcpv6.Message, peer net.Addr)
+
+	// relayed, if set, marks entries registered by ForwardRelay. They
+	// receive the inner messages of Relay-Reply messages instead of plain
+	// messages, relayed being called with each of them and its Relay-Reply
+	// before it is distributed.
+	relayed func(msg *dhcpv6.Message, relay *dhcpv6.RelayMessage)
+}
+
+// Client is a DHCPv6 client.
+type Client struct {
+	ifaceHWAddr net.HardwareAddr
+	timeout     time.Duration
+	retry       int
+
+	// ifaceName is the name of the interface the Client is bound to, if
+	// known. It is the zone of link-local server addresses.
+	ifaceName string
+
+	// duidType and hwType are the DUID type and hardware type of
+	// clientDUID.
+	duidType dhcpv6.DuidType
+	hwType   iana.HWType
+
+	// clientDUID is the Client ID used in the messages built by the
+	// Client's helpers. It is computed once so that it stays stable for the
+	// lifetime of the Client.
+	clientDUID dhcpv6.Duid
+
+	connMu sync.Mutex
+	// conn is the transport messages are sent and received on. It is
+	// replaced by Reconnect.
+	conn Transport
+
+	// newConn, if set, opens a new connection for Reconnect.
+	newConn func() (Transport, error)
+
+	// trafficClass is the Traffic Class set on connections opened by the
+	// Client, or -1 to leave the system default. See WithTrafficClass.
+	trafficClass int
+
+	// sendLimiter, if set, limits the rate of the packets written by the
+	// Client. See WithSendRateLimit.
+	sendLimiter *tokenBucket
+	sendRate    int
+
+	// serverIDOptional are the types of the messages in response to which
+	// Replies without a Server ID are accepted. See WithServerIDOptional.
+	serverIDOptional map[dhcpv6.MessageType]bool
+
+	// sendOnly disables the receive loop. See WithSendOnly.
+	sendOnly bool
+
+	// poolReadBuffers makes the receive loop reuse its read buffers. See
+	// WithReadBufferPool.
+	poolReadBuffers bool
+
+	// metrics, if set, receives measurements of the exchanges. See
+	// WithMetrics.
+	metrics Metrics
+
+	// xidGen, if set, generates the transaction IDs of the messages built by
+	// the Client's helpers. See WithXIDGenerator.
+	xidGen func() dhcpv6.TransactionID
+
+	// clock measures retransmission timeouts, Solicit windows, elapsed
+	// times and lease acquisition times. See WithClock.
+	clock dhcpv6.Clock
+
+	// maxTimeout is the ceiling of the retransmission timeout (MRT).
+	maxTimeout time.Duration
+
+	// operationTimeout bounds the duration of a whole exchange, or 0. See
+	// WithOperationTimeout.
+	operationTimeout time.Duration
+
+	// bufferCap is the channel capacity for each TransactionID.
+	bufferCap int
+
+	// maxPending is the maximum number of pending transactions, or 0 for
+	// no limit. See WithMaxPending.
+	maxPending int
+
+	// idleTimeout is the time after which the Client closes itself when it
+	// has no pending transaction, or 0. See WithIdleTimeout.
+	idleTimeout time.Duration
+
+	// idleArm hands idleLoop the channel of the idle timer, which closes
+	// the Client once it fires, or nil to disarm it. It is nil without an
+	// idle timeout. idleSince is when the Client last became idle, and
+	// idleClosing is set when the timer fires, so that no transaction
+	// starts meanwhile. Both are guarded by pendingMu.
+	idleArm     chan (<-chan time.Time)
+	idleSince   time.Time
+	idleClosing bool
+
+	// dests overrides the destination address of messages of a given
+	// type. See defaultDest.
+	dests map[dhcpv6.MessageType]*net.UDPAddr
+
+	// ensureClientID makes send add a Client ID option to client messages
+	// that lack one. See WithEnsureClientID.
+	ensureClientID bool
+
+	// addressSelector, if set, selects the addresses to keep out of those
+	// granted in a Reply. See WithAddressSelector.
+	addressSelector func(candidates []net.IP) []net.IP
+
+	// dadChecker, if set, checks whether the addresses granted in a Reply
+	// are in use, and dadRerequests is the number of times Request is
+	// retried when some were. See WithDADChecker and WithDADRerequests.
+	dadChecker    DADChecker
+	dadRerequests int
+
+	// normalizeTimers makes lease extraction fix inconsistent IA_NA timers.
+	// See WithTimerNormalization.
+	normalizeTimers bool
+
+	// progress, if set, is called before each transmission attempt.
+	progress func(attempt int, max int)
+
+	// passiveHandler, if set, receives every message read by receiveLoop.
+	passiveHandler func(*dhcpv6.Message, net.Addr)
+
+	// trace, if set, receives the Client's trace events. See WithTrace.
+	trace func(TraceEvent)
+
+	// deprecationWarn, if set, receives warnings about the deprecated
+	// options of the messages sent, each option being reported once in
+	// deprecationWarned. See WithDeprecationWarnings.
+	deprecationWarn   func(msg string)
+	deprecationMu     sync.Mutex
+	deprecationWarned map[dhcpv6.OptionCode]bool
+
+	// outgoingHook, if set, is called on every message before it is sent.
+	// See WithOutgoingHook and WithOutgoingContextHook.
+	outgoingHook func(context.Context, *dhcpv6.Message)
+
+	// duplicateHandler, if set, receives the messages received for a
+	// completed transaction. See WithDuplicateHandler.
+	duplicateHandler func(*dhcpv6.Message)
+
+	advertiseMu sync.Mutex
+	// lastAdvertise is the last Advertise received by Solicit, from
+	// lastAdvertiseSource.
+	lastAdvertise       *dhcpv6.Message
+	lastAdvertiseSource net.Addr
+
+	leasesMu sync.Mutex
+	// leases are the leases obtained by the Client, until released by
+	// ReleaseAll.
+	leases []*dhcpv6.Lease
+
+	// closed is an atomic bool set to 1 when done is closed.
+	closed uint32
+
+	// done is closed to unblock the receive loop.
+	done chan struct{}
+
+	// wg protects any spawned goroutines, namely the receiveLoop.
+	wg sync.WaitGroup
+
+	pendingMu sync.Mutex
+	// pending stores the distribution channels for each pending
+	// TransactionID. receiveLoop uses this map to determine which channel
+	// to send a new DHCP message to.
+	pending map[dhcpv6.TransactionID]*pendingCh
+
+	// completed are the most recently completed TransactionIDs, tracked
+	// only with a duplicateHandler.
+	completed []dhcpv6.TransactionID
+}
+
+// maxCompleted is the number of completed transactions the Client recognizes
+// duplicate messages for.
+const maxCompleted = 16
+
+// New returns a client bound to the DHCPv6 client port of the given
+// interface.
+//
+// The returned Client can Reconnect to the interface, unless a connection was
+// given with WithConn.
+func New(ifaceName string, ifaceHWAddr net.HardwareAddr, opts ...ClientOpt) (*Client, error) {
+	c := newClient(nil, ifaceHWAddr, opts...)
+	c.ifaceName = ifaceName
+	if err := c.checkDUID(); err != nil {
+		return nil, err
+	}
+
+	// Do this after so that a caller can still use a WithConn to override
+	// the connection.
+	if c.conn == nil {
+		c.newConn = func() (Transport, error) {
+			conn, err := NewIPv6UDPConn(ifaceName, dhcpv6.DefaultClientPort)
+			if err != nil || c.trafficClass < 0 {
+				return conn, err
+			}
+			if err := setTrafficClass(conn, c.trafficClass); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return conn, nil
+		}
+		pc, err := c.newConn()
+		if err != nil {
+			return nil, err
+		}
+		c.conn = pc
+	}
+	c.start()
+	c.startIdle()
+	return c, nil
+}
+
+// NewWithConn creates a new DHCP client that sends and receives packets on the
+// given connection.
+func NewWithConn(conn net.PacketConn, ifaceHWAddr net.HardwareAddr, opts ...ClientOpt) *Client {
+	return NewWithTransport(conn, ifaceHWAddr, opts...)
+}
+
+// NewWithTransport creates a new DHCP client that sends and receives packets
+// on the given transport.
+func NewWithTransport(t Transport, ifaceHWAddr net.HardwareAddr, opts ...ClientOpt) *Client {
+	c := newClient(t, ifaceHWAddr, opts...)
+	if udpConn, ok := c.conn.(*net.UDPConn); ok && c.ifaceName != "" {
+		if err := setMulticastInterface(udpConn, c.ifaceName); err != nil {
+			log.Printf("error setting multicast interface: %v", err)
+		}
+	}
+	c.start()
+	c.startIdle()
+	return c
+}
+
+// newClient returns a configured Client that is not receiving yet.
+func newClient(conn Transport, ifaceHWAddr net.HardwareAddr, opts ...ClientOpt) *Client {
+	c := &Client{
+		ifaceHWAddr: ifaceHWAddr,
+		timeout:     defaultTimeout,
+		retry:       defaultRetries,
+		maxTimeout:  defaultMaxTimeout,
+		bufferCap:   defaultBufferCap,
+		conn:        conn,
+		dests:       make(map[dhcpv6.MessageType]*net.UDPAddr),
+		duidType:    dhcpv6.DUID_LLT,
+		hwType:      iana.HWTypeEthernet,
+
+		trafficClass: -1,
+		clock:        dhcpv6.RealClock,
+
+		serverIDOptional: map[dhcpv6.MessageType]bool{
+			dhcpv6.MessageTypeInformationRequest: true,
+		},
+
+		done:    make(chan struct{}),
+		pending: make(map[dhcpv6.TransactionID]*pendingCh),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.sendRate > 0 {
+		c.sendLimiter = newTokenBucket(c.sendRate, c.clock)
+	}
+	// A zero DUID type means no DUID was configured with WithClientDUID.
+	if c.clientDUID.Type == 0 {
+		c.clientDUID = dhcpv6.Duid{
+			Type:          c.duidType,
+			HwType:        c.hwType,
+			LinkLayerAddr: ifaceHWAddr,
+		}
+		if c.duidType == dhcpv6.DUID_LLT {
+			c.clientDUID.Time = dhcpv6.GetTime()
+		}
+	}
+	return c
+}
+
+// checkDUID returns an error if the Client's DUID is derived from a link-layer
+// address but has none, which would make for a malformed Client ID.
+func (c *Client) checkDUID() error {
+	switch c.clientDUID.Type {
+	case dhcpv6.DUID_LLT, dhcpv6.DUID_LL:
+		if len(c.clientDUID.LinkLayerAddr) == 0 {
+			return errors.New("no hardware address to build the client DUID from; use WithClientDUID")
+		}
+	}
+	return nil
+}
+
+// start starts the receive loop on the current connection, unless the Client
+// is send-only.
+func (c *Client) start() {
+	if c.sendOnly {
+		return
+	}
+	c.wg.Add(1)
+	go c.receiveLoop(c.conn)
+}
+
+// startIdle starts the idle timer, if the Client has an idle timeout. It must
+// only be called once the Client is fully set up, as the timer may close it.
+func (c *Client) startIdle() {
+	if c.idleTimeout <= 0 {
+		return
+	}
+	c.idleArm = make(chan (<-chan time.Time))
+	go c.idleLoop()
+	c.pendingMu.Lock()
+	c.idle()
+	c.pendingMu.Unlock()
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	// Make sure not to close done twice.
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return nil
+	}
+	var err error
+	c.connMu.Lock()
+	if c.conn != nil {
+		err = c.conn.Close()
+	}
+	c.connMu.Unlock()
+
+	// Closing c.done sets off a chain reaction:
+	//
+	// Any SendAndRead unblocks trying to receive more messages, which
+	// means rem() gets called.
+	//
+	// rem() should be unblocking receiveLoop if it is blocked.
+	//
+	// receiveLoop should then exit gracefully.
+	close(c.done)
+
+	// Wait for receiveLoop to stop.
+	c.wg.Wait()
+
+	// Nothing else removes the entries registered with Expect.
+	c.pendingMu.Lock()
+	var cancels []func()
+	for _, p := range c.pending {
+		if p.expected {
+			cancels = append(cancels, p.cancel)
+		}
+	}
+	c.pendingMu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return err
+}
+
+func isErrClosing(err error) bool {
+	if err == io.EOF {
+		return true
+	}
+	// Unfortunately, the epoll-connection-closed error is internal to the
+	// net library.
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// Reconnect replaces the Client's connection with a new one on the same
+// interface, e.g. after the interface went down and the connection died. The
+// Client ID, destination addresses and pending transactions are preserved.
+//
+// Reconnect is only supported by clients created with New without WithConn.
+// If opening the new connection fails, the Client is left without a working
+// connection and Reconnect may be called again.
+func (c *Client) Reconnect() error {
+	if c.newConn == nil {
+		return errors.New("client has no interface to reconnect to")
+	}
+
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if atomic.LoadUint32(&c.closed) == 1 {
+		return ErrClosed
+	}
+
+	// Close the old connection first, as the new one binds the same
+	// address, and wait for its receive loop to stop.
+	c.conn.Close()
+	c.wg.Wait()
+
+	conn, err := c.newConn()
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.start()
+	return nil
+}
+
+// idleLoop runs the idle timer, measured with the Client's clock, until the
+// Client is closed.
+func (c *Client) idleLoop() {
+	var fire <-chan time.Time
+	for {
+		select {
+		case fire = <-c.idleArm:
+		case <-fire:
+			fire = nil
+			// Close waits for the transactions, which may be
+			// arming the timer.
+			go c.closeIfIdle()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// closeIfIdle closes the Client if it has had no pending transaction for the
+// idle timeout.
+func (c *Client) closeIfIdle() {
+	c.pendingMu.Lock()
+	if len(c.pending) > 0 || c.clock.Now().Sub(c.idleSince) < c.idleTimeout {
+		c.pendingMu.Unlock()
+		return
+	}
+	c.idleClosing = true
+	c.pendingMu.Unlock()
+	c.Close()
+}
+
+// setIdleTimer arms the idle timer with fire, or disarms it if fire is nil.
+func (c *Client) setIdleTimer(fire <-chan time.Time) {
+	select {
+	case c.idleArm <- fire:
+	case <-c.done:
+	}
+}
+
+// idle restarts the idle timer if there is no pending transaction left.
+// c.pendingMu must be held.
+func (c *Client) idle() {
+	if c.idleArm != nil && len(c.pending) == 0 && atomic.LoadUint32(&c.closed) == 0 {
+		c.idleSince = c.clock.Now()
+		c.setIdleTimer(c.clock.After(c.idleTimeout))
+	}
+}
+
+// readPollInterval is the read deadline receiveLoop sets on Transports that
+// support it, to check whether the Client was closed.
+const readPollInterval = time.Second
+
+// readDeadliner is implemented by Transports that support read deadlines,
+// such as net.PacketConns.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// isTimeout returns whether err is a timeout, as returned by ReadFrom once
+// the read deadline is exceeded.
+func isTimeout(err error) bool {
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
+
+// getReadBuffer returns a buffer for a single read. It must be handed back to
+// putReadBuffer once the packet is parsed.
+func (c *Client) getReadBuffer() *[]byte {
+	if !c.poolReadBuffers {
+		b := make([]byte, maxMessageSize)
+		return &b
+	}
+	return readBufferPool.Get().(*[]byte)
+}
+
+// putReadBuffer returns a buffer obtained from getReadBuffer to the pool, if
+// the Client uses one.
+func (c *Client) putReadBuffer(bp *[]byte) {
+	if c.poolReadBuffers {
+		readBufferPool.Put(bp)
+	}
+}
+
+func (c *Client) receiveLoop(conn Transport) {
+	defer c.wg.Done()
+	// On some platforms, closing a connection does not unblock a pending
+	// ReadFrom. If the Transport supports read deadlines, reads time out
+	// periodically so that the loop notices the Client was closed.
+	deadliner, poll := conn.(readDeadliner)
+	for {
+		if poll && deadliner.SetReadDeadline(time.Now().Add(readPollInterval)) != nil {
+			poll = false
+		}
+		bp := c.getReadBuffer()
+		b := *bp
+		n, peer, err := conn.ReadFrom(b)
+		if err != nil {
+			c.putReadBuffer(bp)
+			if poll && isTimeout(err) {
+				select {
+				case <-c.done:
+					return
+				default:
+					continue
+				}
+			}
+			if !isErrClosing(err) {
+				log.Printf("error reading from UDP connection: %v", err)
+			}
+			return
+		}
+
+		// Parsed messages never reference b, so the buffer can be
+		// reused as soon as parsing is done.
+		var relay *dhcpv6.RelayMessage
+		msg, err := dhcpv6.MessageFromBytes(b[:n])
+		if err != nil && n > 0 && dhcpv6.MessageType(b[0]) == dhcpv6.MessageTypeRelayReply {
+			// Relay-Reply messages are dispatched by the
+			// Transaction ID of their inner message.
+			if relay, err = dhcpv6.RelayMessageFromBytes(b[:n]); err == nil {
+				msg, err = relay.GetInnerMessage()
+			}
+		}
+		var passive *dhcpv6.Message
+		if c.passiveHandler != nil {
+			// Parse again so the handler gets its own copy, which
+			// it may modify without affecting msg. Relay messages
+			// of any type are passed as the message they relay.
+			if d, err := dhcpv6.FromBytes(b[:n]); err == nil {
+				passive, _ = d.GetInnerMessage()
+			}
+		}
+		c.putReadBuffer(bp)
+		if passive != nil {
+			c.passiveHandler(passive, peer)
+		}
+		if err != nil {
+			// Not a valid DHCP packet; keep listening.
+			continue
+		}
+
+		var duplicate bool
+		c.pendingMu.Lock()
+		p, ok := c.pending[msg.TransactionID]
+		if ok && (p.relayed != nil) == (relay != nil) && (p.match == nil || p.match(msg)) {
+			if p.observe != nil {
+				p.observe(msg, peer)
+			}
+			if p.relayed != nil {
+				p.relayed(msg, relay)
+			}
+			select {
+			case <-p.done:
+				close(p.ch)
+				delete(c.pending, msg.TransactionID)
+				c.complete(msg.TransactionID)
+				c.idle()
+				duplicate = true
+
+			case <-c.done:
+
+			// This send may block.
+			case p.ch <- Received{Message: msg, Source: peer}:
+			}
+		} else if !ok {
+			duplicate = c.isCompleted(msg.TransactionID)
+		}
+		c.pendingMu.Unlock()
+
+		if duplicate && c.duplicateHandler != nil {
+			c.duplicateHandler(msg)
+		}
+	}
+}
+
+// complete records xid as a completed transaction, to recognize duplicate
+// messages for it. c.pendingMu must be held.
+func (c *Client) complete(xid dhcpv6.TransactionID) {
+	if c.duplicateHandler == nil {
+		return
+	}
+	c.completed = append(c.completed, xid)
+	if len(c.completed) > maxCompleted {
+		c.completed = c.completed[1:]
+	}
+}
+
+// isCompleted returns whether xid is a recently completed transaction.
+// c.pendingMu must be held.
+func (c *Client) isCompleted(xid dhcpv6.TransactionID) bool {
+	for _, x := range c.completed {
+		if x == xid {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientOpt is a function that configures the Client.
+type ClientOpt func(*Client)
+
+// WithTimeout configures the retransmission timeout.
+//
+// Default is 5 seconds.
+func WithTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithMaxTimeout configures the maximum retransmission timeout (MRT). The
+// timeout doubles after each attempt until it reaches MRT, after which it
+// stays at MRT with a random jitter of up to 10%, as per RFC 3315, Section 14.
+// A zero or negative value disables the ceiling.
+//
+// Default is 120 seconds.
+func WithMaxTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.maxTimeout = d
+	}
+}
+
+// WithOperationTimeout configures the maximum duration of a whole exchange,
+// such as a SendAndRead, including all of its retransmissions. Once it is
+// elapsed, the exchange fails with context.DeadlineExceeded, even in the middle
+// of an attempt. A zero or negative value disables it.
+//
+// The Client's timing knobs are:
+//
+//	Option                Bounds                       Default
+//	WithTimeout           the first attempt            5 seconds
+//	WithMaxTimeout        each later attempt, which    120 seconds
+//	                      doubles the previous one
+//	WithRetry             the number of attempts       3
+//	WithOperationTimeout  the exchange as a whole      none
+//
+// A context deadline passed to an exchange applies as well.
+func WithOperationTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.operationTimeout = d
+	}
+}
+
+// WithBufferCap configures the capacity of the channel buffering the responses
+// received for each pending transaction.
+//
+// The receive loop blocks while the channel of a transaction is full, which
+// delays the delivery of responses to all other transactions. A small buffer
+// can thus slow the Client down when many responses arrive for a single
+// transaction, e.g. when many servers answer a Solicit.
+//
+// Default is 5.
+func WithBufferCap(n int) ClientOpt {
+	return func(c *Client) {
+		c.bufferCap = n
+	}
+}
+
+// WithMaxPending configures the maximum number of transactions that may be
+// pending at the same time. Once it is reached, sending a new message fails
+// with ErrTooManyPending until a transaction completes. A zero or negative
+// value means no limit.
+//
+// Default is no limit.
+func WithMaxPending(n int) ClientOpt {
+	return func(c *Client) {
+		c.maxPending = n
+	}
+}
+
+// WithIdleTimeout configures the Client to close itself once it has had no
+// pending transaction for d, freeing its connection and receive loop, e.g. for
+// processes that only occasionally use DHCPv6. Each transaction restarts the
+// timer once it completes. Sending messages with a closed Client fails with
+// ErrClosed.
+//
+// Default is to never close the Client.
+func WithIdleTimeout(d time.Duration) ClientOpt {
+	return func(c *Client) {
+		c.idleTimeout = d
+	}
+}
+
+// WithRetry configures the number of retransmissions to attempt. A negative
+// number retransmits until the context is done.
+//
+// Default is 3.
+func WithRetry(r int) ClientOpt {
+	return func(c *Client) {
+		c.retry = r
+	}
+}
+
+// WithProgress configures a callback invoked before each transmission attempt
+// of a transaction, with the 1-based attempt number and the maximum number of
+// attempts as configured by WithRetry, or 0 if the number of attempts is
+// unlimited. It can be used to report progress to users while the Client is
+// retrying.
+//
+// The callback may be called concurrently for concurrent transactions.
+func WithProgress(f func(attempt int, max int)) ClientOpt {
+	return func(c *Client) {
+		c.progress = f
+	}
+}
+
+// WithAddressSelector configures a policy selecting which of the addresses
+// granted in a Reply the Client keeps. Request removes the other addresses
+// from the Reply it returns and declines them with a Decline message.
+//
+// The selector is passed all IA_NA addresses of the Reply and returns those to
+// keep.
+func WithAddressSelector(f func(candidates []net.IP) []net.IP) ClientOpt {
+	return func(c *Client) {
+		c.addressSelector = f
+	}
+}
+
+// WithTimerNormalization configures the Client to fix the T1 and T2 timers of
+// the leases it returns when the server sent zero or inconsistent values, so
+// that a misconfigured server cannot make the client renew immediately or
+// never. See dhcpv6.Lease.NormalizeTimers.
+func WithTimerNormalization() ClientOpt {
+	return func(c *Client) {
+		c.normalizeTimers = true
+	}
+}
+
+// WithDUIDLL configures the Client to identify itself with a DUID-LL derived
+// from the interface hardware address only, instead of the default DUID-LLT
+// which also embeds the time the Client was created.
+//
+// A DUID-LL is stable across restarts without having to persist it, at the
+// cost of changing whenever the hardware address changes, in which case
+// servers see a new client.
+func WithDUIDLL() ClientOpt {
+	return func(c *Client) {
+		c.duidType = dhcpv6.DUID_LL
+	}
+}
+
+// WithClientDUID configures the DUID the Client uses as Client ID, instead of
+// the one derived from the interface hardware address.
+func WithClientDUID(duid dhcpv6.Duid) ClientOpt {
+	return func(c *Client) {
+		c.clientDUID = duid
+	}
+}
+
+// WithHardwareType configures the hardware type of the interface hardware
+// address, as used in the Client's DUID. Unknown hardware types are ignored.
+//
+// Default is Ethernet.
+func WithHardwareType(htype iana.HWType) ClientOpt {
+	return func(c *Client) {
+		if !htype.IsKnown() {
+			log.Printf("ignoring unknown hardware type %d", htype)
+			return
+		}
+		c.hwType = htype
+	}
+}
+
+// WithTrafficClass configures the Traffic Class, e.g. a DSCP marking, of the
+// packets sent by the Client. Values outside of 0-255 are ignored.
+//
+// It only applies to the connection opened by New, not to one configured with
+// WithConn or NewWithConn.
+func WithTrafficClass(tc int) ClientOpt {
+	return func(c *Client) {
+		if tc < 0 || tc > 255 {
+			log.Printf("ignoring invalid traffic class %d", tc)
+			return
+		}
+		c.trafficClass = tc
+	}
+}
+
+// WithSendRateLimit limits the rate of the packets sent by the Client,
+// including retransmissions, to perSecond packets per second. Sending waits
+// for the limit, or until the context of the exchange is done. A zero or
+// negative value disables the limit.
+//
+// Default is no limit.
+func WithSendRateLimit(perSecond int) ClientOpt {
+	return func(c *Client) {
+		c.sendRate = perSecond
+	}
+}
+
+// WithServerIDOptional configures whether the Client's helpers, such as
+// Request or InformationRequest, accept Replies without a Server ID option in
+// response to messages of type t.
+//
+// RFC 8415, Section 16.10 requires clients to discard such Replies, which the
+// Client does by default, except for Replies to Information-Requests: some
+// servers omit the Server ID in stateless exchanges, and these Replies carry
+// no binding that would need to be attributed to a server.
+func WithServerIDOptional(t dhcpv6.MessageType, optional bool) ClientOpt {
+	return func(c *Client) {
+		c.serverIDOptional[t] = optional
+	}
+}
+
+// WithSendOnly configures the Client not to receive any message, e.g. for load
+// testing tools that send Solicits without processing the replies. The
+// receive loop is not started, and only Send can be used: all methods waiting
+// for a response return ErrSendOnly.
+func WithSendOnly() ClientOpt {
+	return func(c *Client) {
+		c.sendOnly = true
+	}
+}
+
+// WithReadBufferPool configures the Client to take its read buffers from a
+// pool shared by all Clients, and to return them once a packet is parsed,
+// instead of allocating a new buffer per packet. This reduces the garbage
+// produced by passive monitors or load testers receiving many packets.
+func WithReadBufferPool() ClientOpt {
+	return func(c *Client) {
+		c.poolReadBuffers = true
+	}
+}
+
+// WithXIDGenerator configures the function generating the transaction IDs of
+// the messages built by the Client's helpers, such as Solicit or Request, e.g.
+// to make them predictable in tests, or to partition them between clients.
+// The generator must never return the all-zero transaction ID: the helpers
+// return an error if it does.
+//
+// Default is dhcpv6.GenerateTransactionID.
+func WithXIDGenerator(gen func() dhcpv6.TransactionID) ClientOpt {
+	return func(c *Client) {
+		c.xidGen = gen
+	}
+}
+
+// WithClock configures the source of time used for retransmissions, Solicit
+// windows, Elapsed Time options and lease acquisition times. It is meant for
+// tests, to exercise timers deterministically without sleeping; the operation
+// timeout and contexts still use the real time.
+//
+// Default is dhcpv6.RealClock.
+func WithClock(clock dhcpv6.Clock) ClientOpt {
+	return func(c *Client) {
+		c.clock = clock
+	}
+}
+
+// WithConn configures the packet connection to use.
+func WithConn(conn net.PacketConn) ClientOpt {
+	return WithTransport(conn)
+}
+
+// WithTransport configures the transport to use.
+func WithTransport(t Transport) ClientOpt {
+	return func(c *Client) {
+		c.conn = t
+	}
+}
+
+// WithInterface configures the name of the interface the Client's connection
+// is bound to. It is used as the zone of link-local destination addresses,
+// and NewWithConn sends multicast messages on it if the connection is a UDP
+// connection.
+//
+// New sets it to the interface it is given; this is only useful with
+// NewWithConn.
+func WithInterface(ifaceName string) ClientOpt {
+	return func(c *Client) {
+		c.ifaceName = ifaceName
+	}
+}
+
+// WithDestForType configures the address that messages of type t are sent to
+// by the Client's helpers.
+//
+// Per RFC 3315, Section 13, Solicit, Confirm, Rebind and Information-request
+// messages are always multicast, so a unicast address configured for one of
+// those types is ignored. See defaultDest.
+func WithDestForType(t dhcpv6.MessageType, addr *net.UDPAddr) ClientOpt {
+	return func(c *Client) {
+		c.dests[t] = addr
+	}
+}
+
+// WithEnsureClientID configures the Client to add its Client ID option to any
+// outgoing client message that does not already carry one, so that messages
+// built without the New* helpers are not dropped by servers.
+//
+// Messages that are not sent by clients, such as Reply or Relay-forward, are
+// left untouched.
+func WithEnsureClientID() ClientOpt {
+	return func(c *Client) {
+		c.ensureClientID = true
+	}
+}
+
+// WithOutgoingHook configures a function called on every message right before
+// it is serialized and sent, including retransmissions. It may modify the
+// message, e.g. to add site-specific options or to work around the quirks of a
+// server, but must not change its Transaction ID.
+//
+// As the same message is passed again for each retransmission, the hook
+// should replace options with UpdateOption rather than add them.
+func WithOutgoingHook(f func(*dhcpv6.Message)) ClientOpt {
+	return func(c *Client) {
+		c.outgoingHook = func(_ context.Context, msg *dhcpv6.Message) { f(msg) }
+	}
+}
+
+// WithOutgoingContextHook is like WithOutgoingHook, but f also receives the
+// context of the exchange the message belongs to, e.g. to retrieve its
+// Attribution with AttributionFromContext. Messages sent with Send get a
+// background context.
+func WithOutgoingContextHook(f func(context.Context, *dhcpv6.Message)) ClientOpt {
+	return func(c *Client) {
+		c.outgoingHook = f
+	}
+}
+
+// WithDuplicateHandler configures a handler that receives the messages
+// received for a transaction that already completed, e.g. a second Reply to a
+// Request, which are dropped otherwise. Duplicates are typically caused by a
+// server answering twice or by relay loops, which this helps diagnose.
+//
+// Only the messages for the 16 most recently completed transactions are
+// recognized as duplicates. The handler is called synchronously from the
+// receive loop, so it must not block.
+func WithDuplicateHandler(h func(*dhcpv6.Message)) ClientOpt {
+	return func(c *Client) {
+		c.duplicateHandler = h
+	}
+}
+
+// WithPassiveHandler configures a handler that receives a copy of every valid
+// DHCPv6 message read by the Client, along with its source address, whether or
+// not it matches a pending transaction. This allows passive monitoring of the
+// DHCPv6 traffic on the segment.
+//
+// Relay-Forward and Relay-Reply messages are passed as the innermost message
+// they relay; the source address remains that of the relay message.
+//
+// The handler is called synchronously from the receive loop before the
+// message is distributed to its transaction, so it must not block.
+func WithPassiveHandler(h func(*dhcpv6.Message, net.Addr)) ClientOpt {
+	return func(c *Client) {
+		c.passiveHandler = h
+	}
+}
+
+// isClientMessage returns whether messages of type t are sent by clients and
+// thus carry a Client ID option. See RFC 3315, Section 15.
+func isClientMessage(t dhcpv6.MessageType) bool {
+	switch t {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeRequest,
+		dhcpv6.MessageTypeConfirm, dhcpv6.MessageTypeRenew,
+		dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeRelease,
+		dhcpv6.MessageTypeDecline, dhcpv6.MessageTypeInformationRequest:
+		return true
+	}
+	return false
+}
+
+// isMulticastOnly returns whether messages of type t must always be sent to a
+// multicast address, regardless of any Server Unicast option received. See
+// RFC 3315, Sections 17.1.2, 18.1.2, 18.1.4 and 18.1.5.
+func isMulticastOnly(t dhcpv6.MessageType) bool {
+	switch t {
+	case dhcpv6.MessageTypeSolicit, dhcpv6.MessageTypeConfirm,
+		dhcpv6.MessageTypeRebind, dhcpv6.MessageTypeInformationRequest:
+		return true
+	}
+	return false
+}
+
+// defaultDest returns the address that messages of type t are sent to.
+//
+// Unless configured otherwise with WithDestForType, all messages are sent to
+// All_DHCP_Relay_Agents_and_Servers as required by RFC 3315, Section 13.
+func (c *Client) defaultDest(t dhcpv6.MessageType) *net.UDPAddr {
+	addr, ok := c.dests[t]
+	if !ok || addr == nil {
+		return AllDHCPRelayAgentsAndServers
+	}
+	if isMulticastOnly(t) && !addr.IP.IsMulticast() {
+		return AllDHCPRelayAgentsAndServers
+	}
+	return addr
+}
+
+// unicastDest returns the address advertised in the Server Unicast option of
+// advertise, or nil if there is none or if it is not usable by the Client, in
+// which case messages must be sent by multicast as usual.
+//
+// Only unicast addresses of global scope are used, as well as link-local
+// addresses if the Client knows its interface to scope them to.
+func (c *Client) unicastDest(ctx context.Context, advertise *dhcpv6.Message) *net.UDPAddr {
+	opt, ok := advertise.GetOneOption(dhcpv6.OptionUnicast).(*dhcpv6.OptUnicast)
+	if !ok {
+		return nil
+	}
+	addr := &net.UDPAddr{IP: opt.ServerAddr, Port: dhcpv6.DefaultServerPort}
+	var err error
+	switch ip := opt.ServerAddr; {
+	case ip.To16() == nil || ip.To4() != nil:
+		err = fmt.Errorf("not an IPv6 address")
+	case ip.IsLinkLocalUnicast():
+		if c.ifaceName == "" {
+			err = fmt.Errorf("link-local address without a known interface")
+		}
+		addr.Zone = c.ifaceName
+	case !ip.IsGlobalUnicast():
+		err = fmt.Errorf("not a unicast address of global scope")
+	}
+	if err != nil {
+		c.traceEvent(ctx, TraceEvent{Type: TraceUnicastRejected, Message: advertise, Source: c.advertiseSource(advertise), Addr: addr, Err: err})
+		return nil
+	}
+	c.traceEvent(ctx, TraceEvent{Type: TraceUnicastAccepted, Message: advertise, Source: c.advertiseSource(advertise), Addr: addr})
+	return addr
+}
+
+// Matcher matches DHCP packets.
+type Matcher func(*dhcpv6.Message) bool
+
+var (
+	// MatchAll matches all packets. It is equivalent to a nil Matcher.
+	MatchAll Matcher = func(*dhcpv6.Message) bool { return true }
+
+	// MatchNone matches no packet.
+	MatchNone Matcher = func(*dhcpv6.Message) bool { return false }
+)
+
+// IsMessageType returns a matcher that checks for the message type.
+//
+// If t is MessageTypeNone, all packets are matched, like MatchAll. Use
+// MatchNone to match no packet.
+func IsMessageType(t dhcpv6.MessageType) Matcher {
+	return func(p *dhcpv6.Message) bool {
+		return p.MessageType == t || t == dhcpv6.MessageTypeNone
+	}
+}
+
+// IsAdvertise returns a matcher that checks for Advertise messages.
+func IsAdvertise() Matcher {
+	return IsMessageType(dhcpv6.MessageTypeAdvertise)
+}
+
+// IsReply returns a matcher that checks for Reply messages.
+func IsReply() Matcher {
+	return IsMessageType(dhcpv6.MessageTypeReply)
+}
+
+// isReplyTo returns a matcher that checks for valid Replies to messages of
+// type t, which must carry a Server ID unless configured otherwise with
+// WithServerIDOptional.
+func (c *Client) isReplyTo(t dhcpv6.MessageType) Matcher {
+	if c.serverIDOptional[t] {
+		return IsReply()
+	}
+	return And(IsReply(), func(p *dhcpv6.Message) bool {
+		return p.GetOneOption(dhcpv6.OptionServerID) != nil
+	})
+}
+
+// IsReconfigure returns a matcher that checks for Reconfigure messages.
+func IsReconfigure() Matcher {
+	return IsMessageType(dhcpv6.MessageTypeReconfigure)
+}
+
+// Not returns a matcher that matches the packets m does not match.
+func Not(m Matcher) Matcher {
+	return func(p *dhcpv6.Message) bool {
+		return !m(p)
+	}
+}
+
+// And returns a matcher that matches the packets matched by all of ms. With
+// no matchers, all packets are matched.
+func And(ms ...Matcher) Matcher {
+	return func(p *dhcpv6.Message) bool {
+		for _, m := range ms {
+			if !m(p) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a matcher that matches the packets matched by any of ms. With
+// no matchers, no packet is matched.
+func Or(ms ...Matcher) Matcher {
+	return func(p *dhcpv6.Message) bool {
+		for _, m := range ms {
+			if m(p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HasStatus returns a matcher that checks whether a Status Code option with the
+// given code is present at the top level of the message or within any of its
+// IA_NA and IA_PD options, including their addresses and prefixes.
+func HasStatus(code iana.StatusCode) Matcher {
+	return func(p *dhcpv6.Message) bool {
+		return hasStatus(p.Options, code)
+	}
+}
+
+// HasDelegatedPrefix returns a matcher that checks whether the message
+// carries an IA_PD option with at least one IA Prefix. IA_PDs with a
+// NoPrefixAvail status, either directly or within one of their prefixes, are
+// not taken into account, and neither is any IA_PD of a message with a
+// top-level NoPrefixAvail status.
+func HasDelegatedPrefix() Matcher {
+	return func(p *dhcpv6.Message) bool {
+		for _, opt := range p.Options {
+			if s, ok := opt.(*dhcpv6.OptStatusCode); ok && s.StatusCode == iana.StatusNoPrefixAvail {
+				return false
+			}
+		}
+		for _, opt := range p.Options {
+			iapd, ok := opt.(*dhcpv6.OptIAForPrefixDelegation)
+			if !ok || hasStatus(iapd.Options, iana.StatusNoPrefixAvail) {
+				continue
+			}
+			if iapd.GetOneOption(dhcpv6.OptionIAPrefix) != nil {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func hasStatus(opts dhcpv6.Options, code iana.StatusCode) bool {
+	for _, opt := range opts {
+		var found bool
+		switch o := opt.(type) {
+		case *dhcpv6.OptStatusCode:
+			found = o.StatusCode == code
+		case *dhcpv6.OptIANA:
+			found = hasStatus(o.Options, code)
+		case *dhcpv6.OptIAAddress:
+			found = hasStatus(o.Options, code)
+		case *dhcpv6.OptIAForPrefixDelegation:
+			found = hasStatus(o.Options, code)
+		case *dhcpv6.OptIAPrefix:
+			found = hasStatus(o.Options, code)
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// setXID sets the transaction ID of msg, built by one of the Client's
+// helpers, with the configured generator, if any.
+func (c *Client) setXID(msg *dhcpv6.Message) error {
+	if c.xidGen == nil {
+		return nil
+	}
+	xid, err := c.generateXID()
+	if err != nil {
+		return err
+	}
+	msg.TransactionID = xid
+	return nil
+}
+
+// generateXID returns a new transaction ID from the configured generator, or
+// a random one if there is none.
+func (c *Client) generateXID() (dhcpv6.TransactionID, error) {
+	if c.xidGen == nil {
+		return dhcpv6.GenerateTransactionID()
+	}
+	xid := c.xidGen()
+	if xid == (dhcpv6.TransactionID{}) {
+		return xid, errors.New("transaction ID generator returned the all-zero transaction ID")
+	}
+	return xid, nil
+}
+
+// duid returns the DUID used as Client ID in the messages built by the
+// Client's helpers.
+func (c *Client) duid() dhcpv6.Duid {
+	return c.clientDUID
+}
+
+// Solicit sends a Solicit message and returns the first valid Advertise
+// received.
+func (c *Client) Solicit(ctx context.Context, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	solicit, err := c.newSolicit(modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.sendSolicit(ctx, solicit)
+}
+
+// newSolicit builds the Solicit message sent by Solicit.
+func (c *Client) newSolicit(modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	solicit, err := dhcpv6.NewSolicitWithCID(c.duid(), modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setXID(solicit); err != nil {
+		return nil, err
+	}
+	return solicit, nil
+}
+
+// sendSolicit sends solicit and returns the first valid Advertise received.
+func (c *Client) sendSolicit(ctx context.Context, solicit *dhcpv6.Message) (*dhcpv6.Message, error) {
+	t, err := c.SendAndReadAsync(ctx, c.defaultDest(solicit.MessageType), solicit, IsAdvertise())
+	if err != nil {
+		return nil, err
+	}
+	advertise, err := t.Result()
+	if err != nil {
+		return nil, err
+	}
+	c.advertiseMu.Lock()
+	c.lastAdvertise, c.lastAdvertiseSource = advertise, t.Source()
+	c.advertiseMu.Unlock()
+	return advertise, nil
+}
+
+// LastAdvertise returns the last Advertise received by Solicit, or nil if
+// there was none.
+//
+// If a Request times out, e.g. on a lossy link, it can be sent again for the
+// same Advertise without soliciting servers anew:
+//
+//	reply, err := c.Request(ctx, c.LastAdvertise())
+func (c *Client) LastAdvertise() *dhcpv6.Message {
+	c.advertiseMu.Lock()
+	defer c.advertiseMu.Unlock()
+	return c.lastAdvertise
+}
+
+// advertiseSource returns the address advertise was received from if it is the
+// last Advertise received by Solicit, and nil otherwise.
+func (c *Client) advertiseSource(advertise *dhcpv6.Message) net.Addr {
+	c.advertiseMu.Lock()
+	defer c.advertiseMu.Unlock()
+	if advertise != c.lastAdvertise {
+		return nil
+	}
+	return c.lastAdvertiseSource
+}
+
+// SolicitAll sends a Solicit message and returns the valid Advertises received
+// within window, at most one per server: duplicate Advertises from a server,
+// as received when several relays forward the Solicit, are dropped. An
+// Advertise without a Server ID is invalid and dropped.
+//
+// The Solicit is not retransmitted. ErrNoResponse is returned if no Advertise
+// was received within window.
+//
+// If ctx is done before the end of the window, the Advertises received so far
+// are returned along with ErrWindowIncomplete, so that the caller may still
+// pick one of them. If none was received, ctx.Err() is returned.
+func (c *Client) SolicitAll(ctx context.Context, window time.Duration, modifiers ...dhcpv6.Modifier) ([]*dhcpv6.Message, error) {
+	return c.solicitAll(ctx, window, nil, modifiers...)
+}
+
+// solicitAll implements SolicitAll, calling observe, if set, with each message
+// received in response to the Solicit and its source address.
+func (c *Client) solicitAll(ctx context.Context, window time.Duration, observe func(*dhcpv6.Message, net.Addr), modifiers ...dhcpv6.Modifier) ([]*dhcpv6.Message, error) {
+	solicit, err := dhcpv6.NewSolicitWithCID(c.duid(), modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setXID(solicit); err != nil {
+		return nil, err
+	}
+	(&dhcpv6.ElapsedTimer{Clock: c.clock}).Stamp(solicit)
+	ch, rem, err := c.sendPending(ctx, c.defaultDest(solicit.MessageType), solicit, &pendingCh{observe: observe})
+	if err != nil {
+		return nil, err
+	}
+	defer rem()
+
+	windowEnd := c.clock.After(window)
+	var advertises []*dhcpv6.Message
+	for {
+		select {
+		case <-ctx.Done():
+			advertises = dedupeAdvertises(advertises)
+			if len(advertises) == 0 {
+				return nil, ctx.Err()
+			}
+			return advertises, ErrWindowIncomplete
+		case <-windowEnd:
+			advertises = dedupeAdvertises(advertises)
+			if len(advertises) == 0 {
+				return nil, ErrNoResponse
+			}
+			return advertises, nil
+		case p := <-ch:
+			if p.Message.MessageType == dhcpv6.MessageTypeAdvertise {
+				advertises = append(advertises, p.Message)
+			}
+		}
+	}
+}
+
+// dedupeAdvertises returns the first Advertise of each server in advertises,
+// identified by its Server ID. Advertises without a Server ID are dropped.
+func dedupeAdvertises(advertises []*dhcpv6.Message) []*dhcpv6.Message {
+	seen := make(map[string]bool)
+	var deduped []*dhcpv6.Message
+	for _, adv := range advertises {
+		sid, ok := adv.GetOneOption(dhcpv6.OptionServerID).(*dhcpv6.OptServerId)
+		if !ok {
+			continue
+		}
+		key := string(sid.Sid.ToBytes())
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, adv)
+	}
+	return deduped
+}
+
+// Request requests the addresses offered by advertise and returns the Reply
+// received.
+//
+// If advertise carries a Server Unicast option with a usable address, and no
+// destination was configured for Requests with WithDestForType, the Request is
+// unicast to that address.
+func (c *Client) Request(ctx context.Context, advertise *dhcpv6.Message, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	return c.request(ctx, advertise, false, modifiers...)
+}
+
+// request implements Request, checking with dhcpv6.VerifyReply that the
+// Reply answers all the IAs requested if verify is set.
+func (c *Client) request(ctx context.Context, advertise *dhcpv6.Message, verify bool, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	for attempt := 0; ; attempt++ {
+		reply, err := c.requestOnce(ctx, advertise, verify, modifiers...)
+		if err != nil {
+			return nil, err
+		}
+		if c.dadChecker == nil || !c.checkAddresses(ctx, reply) || attempt >= c.dadRerequests {
+			return reply, nil
+		}
+	}
+}
+
+// requestOnce sends a single Request, see request.
+func (c *Client) requestOnce(ctx context.Context, advertise *dhcpv6.Message, verify bool, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	request, err := dhcpv6.NewRequestFromAdvertise(advertise, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setXID(request); err != nil {
+		return nil, err
+	}
+	dest := c.defaultDest(request.MessageType)
+	if _, ok := c.dests[request.MessageType]; !ok {
+		if addr := c.unicastDest(ctx, advertise); addr != nil {
+			dest = addr
+		}
+	}
+	reply, err := c.SendAndRead(ctx, dest, request, c.isReplyTo(request.MessageType))
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		if err := dhcpv6.VerifyReply(request, reply); err != nil {
+			return nil, err
+		}
+	}
+	if c.addressSelector != nil {
+		c.selectAddresses(ctx, reply)
+	}
+	return reply, nil
+}
+
+// selectAddresses removes the IA_NA addresses of reply not chosen by the
+// address selector, and declines them.
+func (c *Client) selectAddresses(ctx context.Context, reply *dhcpv6.Message) {
+	var candidates []net.IP
+	for _, opt := range reply.GetOption(dhcpv6.OptionIANA) {
+		for _, addr := range opt.(*dhcpv6.OptIANA).Options.Get(dhcpv6.OptionIAAddr) {
+			candidates = append(candidates, addr.(*dhcpv6.OptIAAddress).IPv6Addr)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	selected := c.addressSelector(candidates)
+	c.declineAddresses(ctx, reply, func(ip net.IP) bool {
+		for _, s := range selected {
+			if s.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// declineAddresses removes the IA_NA addresses of reply for which keep
+// returns false, and declines them. It returns whether any address was
+// declined.
+func (c *Client) declineAddresses(ctx context.Context, reply *dhcpv6.Message, keep func(net.IP) bool) bool {
+	var declined []dhcpv6.Option
+	for _, opt := range reply.GetOption(dhcpv6.OptionIANA) {
+		iaNa := opt.(*dhcpv6.OptIANA)
+		kept := make(dhcpv6.Options, 0, len(iaNa.Options))
+		decline := &dhcpv6.OptIANA{IaId: iaNa.IaId}
+		for _, o := range iaNa.Options {
+			if addr, ok := o.(*dhcpv6.OptIAAddress); ok && !keep(addr.IPv6Addr) {
+				decline.AddOption(addr)
+				continue
+			}
+			kept = append(kept, o)
+		}
+		iaNa.Options = kept
+		if len(decline.Options) > 0 {
+			declined = append(declined, decline)
+		}
+	}
+	if len(declined) == 0 {
+		return false
+	}
+
+	msg, err := dhcpv6.NewMessage()
+	if err == nil {
+		err = c.setXID(msg)
+	}
+	if err != nil {
+		log.Printf("error declining addresses: %v", err)
+		return true
+	}
+	msg.MessageType = dhcpv6.MessageTypeDecline
+	cid, sid := reply.GetOneOption(dhcpv6.OptionClientID), reply.GetOneOption(dhcpv6.OptionServerID)
+	if cid == nil || sid == nil {
+		log.Printf("error declining addresses: Client ID and Server ID are required in REPLY")
+		return true
+	}
+	msg.AddOption(cid)
+	msg.AddOption(sid)
+	msg.AddOption(&dhcpv6.OptElapsedTime{})
+	for _, ia := range declined {
+		msg.AddOption(ia)
+	}
+	// The kept addresses are usable whether or not the server
+	// acknowledges the Decline, so failures are only logged.
+	if _, err := c.SendAndRead(ctx, c.defaultDest(msg.MessageType), msg, c.isReplyTo(msg.MessageType)); err != nil {
+		log.Printf("error declining addresses: %v", err)
+	}
+	return true
+}
+
+// defaultIAID is the IAID of the identity associations in messages built by
+// the Client's helpers.
+var defaultIAID = [4]byte{0xfa, 0xce, 0xb0, 0x0c}
+
+// SolicitConfig describes what SolicitFull asks servers for.
+type SolicitConfig struct {
+	// WantAddress requests a non-temporary address (IA_NA).
+	WantAddress bool
+
+	// WantPD requests a delegated prefix (IA_PD).
+	WantPD bool
+
+	// RequestedOptions are requested in the Option Request option, in
+	// addition to DNS servers, domain search list and NTP servers.
+	RequestedOptions []dhcpv6.OptionCode
+}
+
+// withOptionRequest replaces the Option Request option with one requesting
+// DNS servers, domain search list, NTP servers and the given codes.
+func withOptionRequest(codes []dhcpv6.OptionCode) dhcpv6.Modifier {
+	oro := []dhcpv6.OptionCode{
+		dhcpv6.OptionDNSRecursiveNameServer,
+		dhcpv6.OptionDomainSearchList,
+		dhcpv6.OptionNTPServer,
+	}
+	for _, code := range codes {
+		found := false
+		for _, c := range oro {
+			if c == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			oro = append(oro, code)
+		}
+	}
+	return func(d dhcpv6.DHCPv6) {
+		opt := &dhcpv6.OptRequestedOption{}
+		opt.SetRequestedOptions(oro)
+		d.UpdateOption(opt)
+	}
+}
+
+// SolicitFull performs the 4-way Solicit-Advertise-Request-Reply handshake,
+// requesting an address and/or a delegated prefix as configured along with
+// DNS, domain search and NTP configuration, and returns the resulting lease.
+func (c *Client) SolicitFull(ctx context.Context, cfg SolicitConfig) (*dhcpv6.Lease, error) {
+	if !cfg.WantAddress && !cfg.WantPD {
+		return nil, errors.New("SolicitConfig must request an address, a prefix, or both")
+	}
+	oro := withOptionRequest(cfg.RequestedOptions)
+	modifiers := []dhcpv6.Modifier{oro}
+	if !cfg.WantAddress {
+		modifiers = append(modifiers, dhcpv6.WithoutIANA())
+	}
+	if cfg.WantPD {
+		modifiers = append(modifiers, withIAPD)
+	}
+
+	advertise, err := c.Solicit(ctx, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.RequestLease(ctx, advertise, oro)
+}
+
+// withIAPD adds an IA_PD to the message.
+func withIAPD(d dhcpv6.DHCPv6) {
+	d.AddOption(&dhcpv6.OptIAForPrefixDelegation{IaId: defaultIAID})
+}
+
+// SolicitPD is like Solicit, but only requests a delegated prefix, as done by
+// requesting routers per RFC 3633: the Solicit carries an IA_PD and no IA_NA.
+//
+// modifiers are applied after the IA_PD is added, so that e.g.
+// dhcpv6.WithIAPrefix can add a prefix hint to it.
+func (c *Client) SolicitPD(ctx context.Context, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	return c.Solicit(ctx, append([]dhcpv6.Modifier{dhcpv6.WithoutIANA(), withIAPD}, modifiers...)...)
+}
+
+// RequestLease is like Request, but returns the lease extracted from the
+// Reply. An error is returned if the Reply has a failure status, or if it
+// ignores some of the IAs requested, in which case the error is a
+// *dhcpv6.IgnoredIAsError.
+func (c *Client) RequestLease(ctx context.Context, advertise *dhcpv6.Message, modifiers ...dhcpv6.Modifier) (*dhcpv6.Lease, error) {
+	reply, err := c.request(ctx, advertise, true, modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.newLease(reply)
+}
+
+// Restart starts over from a Solicit once the prior lease can no longer be
+// used, e.g. after it expired or a server answered NotOnLink, and returns the
+// new lease. The Solicit carries the Client ID and the IAIDs of the IA_NAs
+// and IA_PDs of prior rather than the Client's own, so that servers see the
+// same client and identity associations as before.
+func (c *Client) Restart(ctx context.Context, prior *dhcpv6.Lease) (*dhcpv6.Lease, error) {
+	if prior == nil {
+		return nil, errors.New("prior lease cannot be nil")
+	}
+	if err := prior.ClientID.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Client ID in prior lease: %v", err)
+	}
+	if len(prior.IANA) == 0 && len(prior.IAPD) == 0 {
+		return nil, errors.New("prior lease has no IA_NA or IA_PD")
+	}
+	withPrior := func(d dhcpv6.DHCPv6) {
+		msg, ok := d.(*dhcpv6.Message)
+		if !ok {
+			return
+		}
+		msg.UpdateOption(&dhcpv6.OptClientId{Cid: prior.ClientID})
+		msg.Options.Del(dhcpv6.OptionIANA)
+		for _, ia := range prior.IANA {
+			msg.AddOption(&dhcpv6.OptIANA{IaId: ia.IaId})
+		}
+		for _, ia := range prior.IAPD {
+			msg.AddOption(&dhcpv6.OptIAForPrefixDelegation{IaId: ia.IaId})
+		}
+	}
+	advertise, err := c.Solicit(ctx, withPrior)
+	if err != nil {
+		return nil, err
+	}
+	return c.RequestLease(ctx, advertise)
+}
+
+// newLease extracts a lease from reply.
+func (c *Client) newLease(reply *dhcpv6.Message) (*dhcpv6.Lease, error) {
+	lease, err := dhcpv6.NewLeaseFromReply(reply, c.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	if c.normalizeTimers {
+		lease.NormalizeTimers()
+	}
+	c.leasesMu.Lock()
+	c.leases = append(c.leases, lease)
+	c.leasesMu.Unlock()
+	return lease, nil
+}
+
+// IAReleaseError reports that an IA_NA or IA_PD could not be released.
+type IAReleaseError struct {
+	// Code is dhcpv6.OptionIANA or dhcpv6.OptionIAPD.
+	Code dhcpv6.OptionCode
+	IAID [4]byte
+
+	// Server is the DUID of the server the Release was sent to.
+	Server dhcpv6.Duid
+
+	// Err is the status of the IA in the Reply, or the error of the whole
+	// Release, e.g. if the server did not answer.
+	Err error
+}
+
+func (e *IAReleaseError) Error() string {
+	kind := "IA_NA"
+	if e.Code == dhcpv6.OptionIAPD {
+		kind = "IA_PD"
+	}
+	return fmt.Sprintf("server %s: %s %x: %v", e.Server.String(), kind, e.IAID, e.Err)
+}
+
+// Unwrap returns the cause of the failure.
+func (e *IAReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// ReleaseError is returned by ReleaseAll when some IAs could not be released,
+// with one IAReleaseError per IA.
+type ReleaseError []*IAReleaseError
+
+func (e ReleaseError) Error() string {
+	errs := make([]string, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err.Error())
+	}
+	return "error releasing leases: " + strings.Join(errs, "; ")
+}
+
+// ReleaseAll releases all the leases obtained with SolicitFull and
+// RequestLease, sending a single Release per client and server with all the
+// IA_NAs and IA_PDs the server granted to that client, and checks that each
+// IA was released successfully.
+//
+// The leases are forgotten whether or not their release succeeded. The
+// failures of all Releases and IAs are reported together in a ReleaseError.
+func (c *Client) ReleaseAll(ctx context.Context) error {
+	c.leasesMu.Lock()
+	leases := c.leases
+	c.leases = nil
+	c.leasesMu.Unlock()
+
+	// Group the IAs by client and server, in the order leases were
+	// obtained. A lease may hold IAs of several servers if it was merged
+	// with another, and leases obtained with Restart keep the Client ID of
+	// the prior lease.
+	type binding struct {
+		cid, sid dhcpv6.Duid
+		ias      []dhcpv6.Option
+	}
+	var bindings []*binding
+	byKey := make(map[string]*binding)
+	// Split may return copies of the leases merged into each other, but
+	// the IAs themselves are shared, so that each is released once.
+	seen := make(map[dhcpv6.Option]bool)
+	add := func(b *binding, ia dhcpv6.Option) {
+		if !seen[ia] {
+			seen[ia] = true
+			b.ias = append(b.ias, ia)
+		}
+	}
+	for _, whole := range leases {
+		for _, l := range whole.Split() {
+			key := string(l.ClientID.ToBytes()) + "/" + string(l.ServerID.ToBytes())
+			b, ok := byKey[key]
+			if !ok {
+				b = &binding{cid: l.ClientID, sid: l.ServerID}
+				byKey[key] = b
+				bindings = append(bindings, b)
+			}
+			for _, ia := range l.IANA {
+				add(b, ia)
+			}
+			for _, ia := range l.IAPD {
+				add(b, ia)
+			}
+		}
+	}
+
+	var errs ReleaseError
+	for _, b := range bindings {
+		errs = append(errs, c.release(ctx, b.cid, b.sid, b.ias)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// statusError returns an error if opts contain a Status Code option other than
+// Success.
+func statusError(opts dhcpv6.Options) error {
+	sc, ok := opts.GetOne(dhcpv6.OptionStatusCode).(*dhcpv6.OptStatusCode)
+	if !ok || sc.StatusCode == iana.StatusSuccess {
+		return nil
+	}
+	if len(sc.StatusMessage) == 0 {
+		return errors.New(sc.StatusCode.String())
+	}
+	return fmt.Errorf("%s: %s", sc.StatusCode, sc.StatusMessage)
+}
+
+// iaID returns the IAID of an IA_NA or IA_PD.
+func iaID(ia dhcpv6.Option) [4]byte {
+	switch ia := ia.(type) {
+	case *dhcpv6.OptIANA:
+		return ia.IaId
+	case *dhcpv6.OptIAForPrefixDelegation:
+		return ia.IaId
+	}
+	return [4]byte{}
+}
+
+// release sends a Release of ias, bound to the client identified by cid, to
+// the server identified by sid, and checks the status of each IA in the Reply.
+// It returns an error for each IA that was not released.
+func (c *Client) release(ctx context.Context, cid, sid dhcpv6.Duid, ias []dhcpv6.Option) []*IAReleaseError {
+	if len(ias) == 0 {
+		return nil
+	}
+	// failAll reports the failure of the whole Release for each IA.
+	failAll := func(err error) []*IAReleaseError {
+		errs := make([]*IAReleaseError, 0, len(ias))
+		for _, ia := range ias {
+			errs = append(errs, &IAReleaseError{Code: ia.Code(), IAID: iaID(ia), Server: sid, Err: err})
+		}
+		return errs
+	}
+
+	msg, err := dhcpv6.NewMessage()
+	if err != nil {
+		return failAll(err)
+	}
+	if err := c.setXID(msg); err != nil {
+		return failAll(err)
+	}
+	msg.MessageType = dhcpv6.MessageTypeRelease
+	msg.AddOption(&dhcpv6.OptClientId{Cid: cid})
+	msg.AddOption(&dhcpv6.OptServerId{Sid: sid})
+	msg.AddOption(&dhcpv6.OptElapsedTime{})
+	for _, ia := range ias {
+		msg.AddOption(dhcpv6.ZeroIATimers(ia))
+	}
+	reply, err := c.SendAndRead(ctx, c.defaultDest(msg.MessageType), msg, c.isReplyTo(msg.MessageType))
+	if err != nil {
+		return failAll(err)
+	}
+	if err := statusError(reply.Options); err != nil {
+		return failAll(err)
+	}
+
+	var errs []*IAReleaseError
+	for _, opt := range reply.Options {
+		var (
+			id     [4]byte
+			status dhcpv6.Options
+		)
+		switch ia := opt.(type) {
+		case *dhcpv6.OptIANA:
+			id, status = ia.IaId, ia.Options
+		case *dhcpv6.OptIAForPrefixDelegation:
+			id, status = ia.IaId, ia.Options
+		default:
+			continue
+		}
+		if err := statusError(status); err != nil {
+			errs = append(errs, &IAReleaseError{Code: opt.Code(), IAID: id, Server: sid, Err: err})
+		}
+	}
+	return errs
+}
+
+// InformationRequest sends an Information-request message, to obtain
+// configuration parameters without any address as described by RFC 3315,
+// Section 18.1.5, and returns the Reply.
+//
+// DNS servers, domain search list and NTP servers are requested; modifiers
+// may request more options with dhcpv6.WithRequestedOptions.
+func (c *Client) InformationRequest(ctx context.Context, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	msg, err := c.newInformationRequest(modifiers...)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendAndRead(ctx, c.defaultDest(msg.MessageType), msg, c.isReplyTo(msg.MessageType))
+}
+
+// newInformationRequest builds the Information-request message sent by
+// InformationRequest.
+func (c *Client) newInformationRequest(modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, error) {
+	msg, err := dhcpv6.NewMessage()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.setXID(msg); err != nil {
+		return nil, err
+	}
+	msg.MessageType = dhcpv6.MessageTypeInformationRequest
+	msg.AddOption(&dhcpv6.OptClientId{Cid: c.duid()})
+	withOptionRequest(nil)(msg)
+	msg.AddOption(&dhcpv6.OptElapsedTime{})
+	for _, mod := range modifiers {
+		mod(msg)
+	}
+	return msg, nil
+}
+
+// DHCPv4Query sends msg to a DHCPv4-over-DHCPv6 server, encapsulated in a
+// DHCPv4-query message as defined by RFC 7341, and returns the DHCPv4 message
+// of the matching DHCPv4-response.
+//
+// The query is multicast, with all flags cleared. Responses are matched on
+// their flags field, which RFC 7341 requires to be zero, and on the
+// transaction ID of the DHCPv4 message they carry. Hence only one query may be
+// pending at a time.
+func (c *Client) DHCPv4Query(ctx context.Context, msg *dhcpv4.DHCPv4) (*dhcpv4.DHCPv4, error) {
+	if msg == nil {
+		return nil, errors.New("DHCPv4 message cannot be nil")
+	}
+	query := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeDHCPv4Query}
+	query.AddOption(&dhcpv6.OptDHCPv4Msg{Msg: msg})
+
+	match := And(IsMessageType(dhcpv6.MessageTypeDHCPv4Response), func(p *dhcpv6.Message) bool {
+		opt, ok := p.GetOneOption(dhcpv6.OptionDHCPv4Msg).(*dhcpv6.OptDHCPv4Msg)
+		return ok && opt.Msg.TransactionID == msg.TransactionID
+	})
+	resp, err := c.SendAndRead(ctx, c.defaultDest(query.MessageType), query, match)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetOneOption(dhcpv6.OptionDHCPv4Msg).(*dhcpv6.OptDHCPv4Msg).Msg, nil
+}
+
+// send sends p to destination and returns a response channel.
+//
+// Responses will be matched by transaction ID.
+//
+// The returned lambda function must be called after all desired responses have
+// been received in order to return the Transaction ID to the usable pool.
+func (c *Client) send(ctx context.Context, dest *net.UDPAddr, msg *dhcpv6.Message) (resp <-chan Received, cancel func(), err error) {
+	return c.sendPending(ctx, dest, msg, &pendingCh{})
+}
+
+// sendPending is like send, registering the transaction with the settings of
+// p. See register.
+func (c *Client) sendPending(ctx context.Context, dest *net.UDPAddr, msg *dhcpv6.Message, p *pendingCh) (resp <-chan Received, cancel func(), err error) {
+	ch, cancel, err := c.register(msg.TransactionID, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.writeMessage(ctx, dest, msg); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ch, cancel, nil
+}
+
+// Send sends msg to dest without waiting for a response, e.g. to generate load
+// on a server. The Client's options, such as WithEnsureClientID or
+// WithOutgoingHook, apply to msg.
+//
+// Send is the only way to send messages with a Client configured with
+// WithSendOnly.
+func (c *Client) Send(dest *net.UDPAddr, msg *dhcpv6.Message) error {
+	return c.writeMessage(context.Background(), dest, msg)
+}
+
+// writeMessage completes msg according to the Client's options and writes it
+// to dest.
+func (c *Client) writeMessage(ctx context.Context, dest *net.UDPAddr, msg *dhcpv6.Message) error {
+	if c.ensureClientID && isClientMessage(msg.MessageType) && msg.GetOneOption(dhcpv6.OptionClientID) == nil {
+		msg.AddOption(&dhcpv6.OptClientId{Cid: c.duid()})
+	}
+	if c.outgoingHook != nil {
+		c.outgoingHook(ctx, msg)
+	}
+	if c.deprecationWarn != nil {
+		c.warnDeprecated(msg)
+	}
+
+	b, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return c.write(ctx, dest, b)
+}
+
+// sendRaw is like send, but sends b as is, responses being matched by xid.
+func (c *Client) sendRaw(ctx context.Context, dest *net.UDPAddr, xid dhcpv6.TransactionID, b []byte) (resp <-chan Received, cancel func(), err error) {
+	ch, cancel, err := c.register(xid, &pendingCh{})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.write(ctx, dest, b); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ch, cancel, nil
+}
+
+// write writes b to dest on the Client's connection, waiting first for the
+// rate limit configured with WithSendRateLimit, if any.
+func (c *Client) write(ctx context.Context, dest *net.UDPAddr, b []byte) error {
+	if atomic.LoadUint32(&c.closed) == 1 {
+		return ErrClosed
+	}
+	if c.sendLimiter != nil {
+		if err := c.sendLimiter.wait(ctx, c.done); err != nil {
+			return err
+		}
+	}
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+	if n, err := conn.WriteTo(b, c.zoned(dest)); err != nil {
+		return fmt.Errorf("error writing packet to connection: %v", err)
+	} else if n != len(b) {
+		return fmt.Errorf("short write to connection: wrote %d of %d bytes", n, len(b))
+	}
+	if c.metrics != nil && len(b) > 0 {
+		c.metrics.MessageSent(dhcpv6.MessageType(b[0]))
+	}
+	return nil
+}
+
+// zoned returns dest with its zone set to the Client's interface if it is a
+// link-local address without one, as such addresses cannot be written to
+// otherwise. dest itself is not modified.
+func (c *Client) zoned(dest *net.UDPAddr) *net.UDPAddr {
+	if dest == nil || dest.Zone != "" || c.ifaceName == "" {
+		return dest
+	}
+	if !dest.IP.IsLinkLocalUnicast() && !dest.IP.IsLinkLocalMulticast() {
+		return dest
+	}
+	zoned := *dest
+	zoned.Zone = c.ifaceName
+	return &zoned
+}
+
+// register adds p as the pending entry for xid, and returns its channel along
+// with the function removing it. The match, expected and observe settings of p
+// are kept, the rest is filled in by register.
+func (c *Client) register(xid dhcpv6.TransactionID, p *pendingCh) (<-chan Received, func(), error) {
+	if c.sendOnly {
+		return nil, nil, ErrSendOnly
+	}
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if c.idleClosing || atomic.LoadUint32(&c.closed) == 1 {
+		return nil, nil, ErrClosed
+	}
+	if _, ok := c.pending[xid]; ok {
+		return nil, nil, fmt.Errorf("transaction ID %s already in use", xid)
+	}
+	if c.maxPending > 0 && len(c.pending) >= c.maxPending {
+		return nil, nil, ErrTooManyPending
+	}
+
+	ch := make(chan Received, c.bufferCap)
+	done := make(chan struct{})
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			// Why can't we just close ch here?
+			//
+			// Because receiveLoop may potentially be blocked trying
+			// to send on ch. We gotta unblock it first, and then we
+			// can take the lock and remove the XID from the pending
+			// transaction map.
+			close(done)
+
+			c.pendingMu.Lock()
+			if p, ok := c.pending[xid]; ok && p.ch == ch {
+				close(p.ch)
+				delete(c.pending, xid)
+				c.complete(xid)
+				c.idle()
+			}
+			c.pendingMu.Unlock()
+		})
+	}
+	p.done, p.ch, p.cancel = done, ch, cancel
+	c.pending[xid] = p
+	if c.idleArm != nil {
+		c.setIdleTimer(nil)
+	}
+	return ch, cancel, nil
+}
+
+// Expect registers a pending transaction for xid without sending anything,
+// and returns the channel on which the messages received for it and matching
+// match are distributed with their source address, along with a function to call once no more messages
+// are wanted.
+//
+// This lets a caller transmit a message out of band, e.g. through its own
+// socket, and still have the Client's receive loop deliver its responses. The
+// caller must keep receiving from the channel, or call the returned function,
+// as the receive loop delivers messages to all transactions in turn.
+//
+// The channel is closed by the returned function, or when the Client is
+// closed.
+func (c *Client) Expect(xid dhcpv6.TransactionID, match Matcher) (<-chan Received, func(), error) {
+	return c.register(xid, &pendingCh{match: match, expected: true})
+}
+
+// This error should never be visible to users.
+// It is used only to increase the timeout in retryFn.
+var errDeadlineExceeded = errors.New("INTERNAL ERROR: deadline exceeded")
+
+// Transaction is a pending exchange started with SendAndReadAsync.
+type Transaction struct {
+	cancel context.CancelFunc
+
+	// attempts is the number of times the message was sent, accessed
+	// atomically.
+	attempts int32
+
+	// done is closed once response, source and err are set.
+	done     chan struct{}
+	response *dhcpv6.Message
+	source   net.Addr
+	err      error
+}
+
+// Result waits for the transaction to complete and returns the first response
+// matching the transaction, or an error.
+//
+// If the transaction was cancelled with Cancel, the error is
+// context.Canceled.
+func (t *Transaction) Result() (*dhcpv6.Message, error) {
+	<-t.done
+	return t.response, t.err
+}
+
+// Source waits for the transaction to complete and returns the address its
+// response was received from, e.g. to learn the address of the server that
+// sent an Advertise, or nil if there was no response.
+func (t *Transaction) Source() net.Addr {
+	<-t.done
+	return t.source
+}
+
+// Attempts returns the number of times the message of the transaction was sent
+// so far, including retransmissions.
+func (t *Transaction) Attempts() int {
+	return int(atomic.LoadInt32(&t.attempts))
+}
+
+// Cancel aborts the transaction, stopping any further retransmission. It is
+// safe to call Cancel on a transaction that already completed.
+func (t *Transaction) Cancel() {
+	t.cancel()
+}
+
+// SendAndReadAsync sends a packet p to a destination dest like SendAndRead,
+// but returns without waiting for a response. The returned Transaction can be
+// used to wait for the response or to abort the exchange.
+func (c *Client) SendAndReadAsync(ctx context.Context, dest *net.UDPAddr, p *dhcpv6.Message, match Matcher) (*Transaction, error) {
+	if match == nil {
+		match = MatchAll
+	}
+	// The first transmission happens synchronously, so that errors such as
+	// a Transaction ID already in use are reported to the caller.
+	timer := &dhcpv6.ElapsedTimer{Clock: c.clock}
+	timer.Stamp(p)
+	start := c.clock.Now()
+	ch, rem, err := c.send(ctx, dest, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var cancel context.CancelFunc
+	if c.operationTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.operationTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	t := &Transaction{
+		cancel:   cancel,
+		attempts: 1,
+		done:     make(chan struct{}),
+	}
+	transmit := func() (<-chan Received, func(), error) {
+		timer.Stamp(p)
+		ch, rem, err := c.send(ctx, dest, p)
+		if err == nil {
+			atomic.AddInt32(&t.attempts, 1)
+			if c.metrics != nil {
+				c.metrics.Retransmission(p.MessageType)
+			}
+		}
+		return ch, rem, err
+	}
+	go func() {
+		defer close(t.done)
+		defer cancel()
+		t.response, t.source, t.err = c.sendAndRead(ctx, match, transmit, ch, rem)
+		c.observeExchange(p.MessageType, start, t.err)
+	}()
+	return t, nil
+}
+
+// SendAndRead sends a packet p to a destination dest and waits for the first
+// response matching `match` as well as its Transaction ID.
+//
+// If match is nil or MatchAll, the first packet matching the Transaction ID is
+// returned.
+func (c *Client) SendAndRead(ctx context.Context, dest *net.UDPAddr, p *dhcpv6.Message, match Matcher) (*dhcpv6.Message, error) {
+	t, err := c.SendAndReadAsync(ctx, dest, p, match)
+	if err != nil {
+		return nil, err
+	}
+	return t.Result()
+}
+
+// SendRaw sends the bytes b as is to dest, e.g. to reproduce a malformed
+// message from a capture, and waits for the first response matching `match`
+// as well as the Transaction ID found in the first 4 bytes of b. b is
+// retransmitted like the messages sent by SendAndRead.
+//
+// The Client's own options, such as WithEnsureClientID or WithOutgoingHook,
+// do not apply to b.
+func (c *Client) SendRaw(ctx context.Context, dest *net.UDPAddr, b []byte, match Matcher) (*dhcpv6.Message, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("raw message too short: got %d bytes, want at least 4", len(b))
+	}
+	if match == nil {
+		match = MatchAll
+	}
+	var xid dhcpv6.TransactionID
+	copy(xid[:], b[1:4])
+	t := dhcpv6.MessageType(b[0])
+	start := c.clock.Now()
+	ch, rem, err := c.sendRaw(ctx, dest, xid, b)
+	if err != nil {
+		return nil, err
+	}
+	transmit := func() (<-chan Received, func(), error) {
+		ch, rem, err := c.sendRaw(ctx, dest, xid, b)
+		if err == nil && c.metrics != nil {
+			c.metrics.Retransmission(t)
+		}
+		return ch, rem, err
+	}
+
+	if c.operationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+	}
+	resp, _, err := c.sendAndRead(ctx, match, transmit, ch, rem)
+	c.observeExchange(t, start, err)
+	return resp, err
+}
+
+// sendAndRead waits for a response, retransmitting with transmit as
+// configured, and returns it along with its source address. ch and rem are the
+// result of the already performed first transmission.
+func (c *Client) sendAndRead(ctx context.Context, match Matcher, transmit func() (<-chan Received, func(), error), ch <-chan Received, rem func()) (*dhcpv6.Message, net.Addr, error) {
+	var response Received
+	err := c.retryFn(func(timeout time.Duration) error {
+		if ch == nil {
+			var err error
+			ch, rem, err = transmit()
+			if err != nil {
+				return err
+			}
+		}
+		defer func() {
+			rem()
+			ch = nil
+		}()
+
+		for {
+			select {
+			case <-c.done:
+				return ErrNoResponse
+
+			case <-c.clock.After(timeout):
+				return errDeadlineExceeded
+
+			case <-ctx.Done():
+				return ctx.Err()
+
+			case packet := <-ch:
+				if match(packet.Message) {
+					response = packet
+					return nil
+				}
+			}
+		}
+	})
+	if ch != nil {
+		// fn never ran, e.g. with WithRetry(0): the first transmission
+		// is still registered.
+		rem()
+	}
+	if err == errDeadlineExceeded {
+		return nil, nil, ErrNoResponse
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return response.Message, response.Source, nil
+}
+
+func (c *Client) retryFn(fn func(timeout time.Duration) error) error {
+	timeout := c.timeout
+
+	// Each retry takes the amount of timeout at worst.
+	for i := 0; i < c.retry || c.retry < 0; i++ {
+		if c.progress != nil {
+			max := c.retry
+			if max < 0 {
+				max = 0
+			}
+			c.progress(i+1, max)
+		}
+		switch err := fn(timeout); err {
+		case nil:
+			// Got it!
+			return nil
+
+		case errDeadlineExceeded:
+			// Double timeout, then retry.
+			timeout *= 2
+			if c.maxTimeout > 0 && timeout > c.maxTimeout {
+				// RT = MRT + RAND*MRT, RAND in [-0.1, 0.1].
+				jitter := (rand.Float64()*0.2 - 0.1) * float64(c.maxTimeout)
+				timeout = c.maxTimeout + time.Duration(jitter)
+			}
+
+		default:
+			return err
+		}
+	}
+
+	return errDeadlineExceeded
+}
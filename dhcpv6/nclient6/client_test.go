@@ -0,0 +1,2408 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hugelgupf/socketpair"
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+type handler struct {
+	mu       sync.Mutex
+	received []*dhcpv6.Message
+
+	// Each received packet can have more than one response (in theory,
+	// from different servers sending different Advertise, for example).
+	responses [][]*dhcpv6.Message
+}
+
+func (h *handler) handle(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.received = append(h.received, m)
+
+	if len(h.responses) > 0 {
+		for _, resp := range h.responses[0] {
+			conn.WriteTo(resp.ToBytes(), peer)
+		}
+		h.responses = h.responses[1:]
+	}
+}
+
+// serve reads messages from conn and passes them to handle until conn is
+// closed.
+func serve(conn net.PacketConn, handle func(net.PacketConn, net.Addr, *dhcpv6.Message)) {
+	for {
+		b := make([]byte, maxMessageSize)
+		n, peer, err := conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		m, err := dhcpv6.MessageFromBytes(b[:n])
+		if err != nil {
+			continue
+		}
+		handle(conn, peer, m)
+	}
+}
+
+// fullWriteConn is a PacketConn reporting that all of each packet was written
+// when the write succeeded.
+type fullWriteConn struct {
+	net.PacketConn
+}
+
+func (c fullWriteConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if _, err := c.PacketConn.WriteTo(b, addr); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// packetSocketPair returns a pair of connected PacketConns like
+// socketpair.PacketSocketPair, whose WriteTo reports 0 bytes written even on
+// success: the Client would take it for a short write.
+func packetSocketPair() (net.PacketConn, net.PacketConn, error) {
+	clientConn, serverConn, err := socketpair.PacketSocketPair()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fullWriteConn{clientConn}, fullWriteConn{serverConn}, nil
+}
+
+func serveAndClient(ctx context.Context, responses [][]*dhcpv6.Message, opts ...ClientOpt) (*Client, net.PacketConn) {
+	// Fake PacketConn connection.
+	clientConn, serverConn, err := packetSocketPair()
+	if err != nil {
+		panic(err)
+	}
+
+	o := []ClientOpt{WithRetry(1), WithTimeout(2 * time.Second)}
+	o = append(o, opts...)
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, o...)
+
+	h := &handler{responses: responses}
+	go serve(serverConn, h.handle)
+
+	return mc, serverConn
+}
+
+func ComparePacket(got *dhcpv6.Message, want *dhcpv6.Message) error {
+	if got == nil && got == want {
+		return nil
+	}
+	if (want == nil || got == nil) && (got != want) {
+		return fmt.Errorf("packet got %v, want %v", got, want)
+	}
+	if bytes.Compare(got.ToBytes(), want.ToBytes()) != 0 {
+		return fmt.Errorf("packet got %v, want %v", got, want)
+	}
+	return nil
+}
+
+func pktsExpected(got []*dhcpv6.Message, want []*dhcpv6.Message) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("got %d packets, want %d packets", len(got), len(want))
+	}
+
+	for i := range got {
+		if err := ComparePacket(got[i], want[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPacket(t dhcpv6.MessageType, xid dhcpv6.TransactionID) *dhcpv6.Message {
+	return &dhcpv6.Message{
+		MessageType:   t,
+		TransactionID: xid,
+	}
+}
+
+func TestSendAndRead(t *testing.T) {
+	for _, tt := range []struct {
+		desc   string
+		send   *dhcpv6.Message
+		server []*dhcpv6.Message
+
+		// If want is nil, we assume server[0] contains what is wanted.
+		want    *dhcpv6.Message
+		wantErr error
+	}{
+		{
+			desc: "two response packets",
+			send: newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33}),
+			server: []*dhcpv6.Message{
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+			},
+			want: newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+		},
+		{
+			desc: "one response packet",
+			send: newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33}),
+			server: []*dhcpv6.Message{
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+			},
+			want: newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+		},
+		{
+			desc: "one response packet, one invalid XID",
+			send: newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33}),
+			server: []*dhcpv6.Message{
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x77, 0x33, 0x33}),
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+			},
+			want: newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+		},
+		{
+			desc: "discard wrong XID",
+			send: newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33}),
+			server: []*dhcpv6.Message{
+				newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0, 0, 0}),
+			},
+			want:    nil, // Explicitly empty.
+			wantErr: ErrNoResponse,
+		},
+		{
+			desc:    "no response, timeout",
+			send:    newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33}),
+			wantErr: ErrNoResponse,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			// Both server and client only get 2 seconds.
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			mc, _ := serveAndClient(ctx, [][]*dhcpv6.Message{tt.server},
+				// Use an unbuffered channel to make sure we
+				// have no deadlocks.
+				WithBufferCap(0))
+			defer mc.Close()
+
+			rcvd, err := mc.SendAndRead(context.Background(), AllDHCPServers, tt.send, nil)
+			if err != tt.wantErr {
+				t.Error(err)
+			}
+
+			if err := ComparePacket(rcvd, tt.want); err != nil {
+				t.Errorf("got unexpected packets: %v", err)
+			}
+		})
+	}
+}
+
+func TestParallelSendAndRead(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+
+	// Both the server and client only get 2 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	mc, _ := serveAndClient(ctx, [][]*dhcpv6.Message{},
+		WithTimeout(10*time.Second),
+		// Use an unbuffered channel to make sure nothing blocks.
+		WithBufferCap(0))
+	defer mc.Close()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil); err != ErrNoResponse {
+			t.Errorf("SendAndRead(%v) = %v, want %v", pkt, err, ErrNoResponse)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		time.Sleep(4 * time.Second)
+
+		if err := mc.Close(); err != nil {
+			t.Errorf("closing failed: %v", err)
+		}
+	}()
+
+	wg.Wait()
+}
+
+func TestReuseXID(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+
+	// Both the server and client only get 2 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	mc, _ := serveAndClient(ctx, [][]*dhcpv6.Message{})
+	defer mc.Close()
+
+	if _, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil); err != ErrNoResponse {
+		t.Errorf("SendAndRead(%v) = %v, want %v", pkt, err, ErrNoResponse)
+	}
+
+	if _, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil); err != ErrNoResponse {
+		t.Errorf("SendAndRead(%v) = %v, want %v", pkt, err, ErrNoResponse)
+	}
+}
+
+func TestSimpleSendAndReadDiscardGarbage(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+
+	responses := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})
+
+	// Both the server and client only get 2 seconds.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	mc, udpConn := serveAndClient(ctx, [][]*dhcpv6.Message{{responses}})
+	defer mc.Close()
+
+	// Too short for valid DHCPv6 packet.
+	udpConn.WriteTo([]byte{0x01}, nil)
+	udpConn.WriteTo([]byte{0x01, 0x2}, nil)
+
+	rcvd, err := mc.SendAndRead(ctx, AllDHCPServers, pkt, nil)
+	if err != nil {
+		t.Errorf("SendAndRead(%v) = %v, want nil", pkt, err)
+	}
+
+	if err := ComparePacket(rcvd, responses); err != nil {
+		t.Errorf("got unexpected packets: %v", err)
+	}
+}
+
+func TestMultipleSendAndRead(t *testing.T) {
+	for _, tt := range []struct {
+		desc    string
+		send    []*dhcpv6.Message
+		server  [][]*dhcpv6.Message
+		wantErr []error
+	}{
+		{
+			desc: "two requests, two responses",
+			send: []*dhcpv6.Message{
+				newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33}),
+				newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x44, 0x44, 0x44}),
+			},
+			server: [][]*dhcpv6.Message{
+				[]*dhcpv6.Message{ // Response for first packet.
+					newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33}),
+				},
+				[]*dhcpv6.Message{ // Response for second packet.
+					newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x44, 0x44, 0x44}),
+				},
+			},
+			wantErr: []error{
+				nil,
+				nil,
+			},
+		},
+	} {
+		// Both server and client only get 2 seconds.
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		mc, _ := serveAndClient(ctx, tt.server)
+		defer mc.Close()
+
+		for i, send := range tt.send {
+			ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			rcvd, err := mc.SendAndRead(ctx, AllDHCPServers, send, nil)
+
+			if wantErr := tt.wantErr[i]; err != wantErr {
+				t.Errorf("SendAndReadOne(%v): got %v, want %v", send, err, wantErr)
+			}
+			if err := pktsExpected([]*dhcpv6.Message{rcvd}, tt.server[i]); err != nil {
+				t.Errorf("got unexpected packets: %v", err)
+			}
+		}
+	}
+}
+
+func TestSendAndReadAsync(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	resp := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})
+
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{resp}})
+	defer mc.Close()
+
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+
+	rcvd, err := tr.Result()
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, resp))
+
+	// Cancelling a completed transaction does not change its result.
+	tr.Cancel()
+	rcvd, err = tr.Result()
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, resp))
+}
+
+func TestSendAndReadAsyncCancel(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+
+	// Silent server, and a timeout long enough that only Cancel can
+	// complete the transaction in time.
+	mc, _ := serveAndClient(context.Background(), nil, WithTimeout(time.Minute))
+	defer mc.Close()
+
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+
+	// The XID is pending until the transaction is cancelled.
+	_, err = mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.Error(t, err)
+
+	start := time.Now()
+	tr.Cancel()
+	_, err = tr.Result()
+	require.Equal(t, context.Canceled, err)
+	require.True(t, time.Since(start) < time.Second)
+}
+
+func TestTransactionAttempts(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	resp := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})
+
+	// The server only answers the third transmission.
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{nil, nil, {resp}},
+		WithRetry(5), WithTimeout(10*time.Millisecond))
+	defer mc.Close()
+
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	_, err = tr.Result()
+	require.NoError(t, err)
+	require.Equal(t, 3, tr.Attempts())
+
+	// Without any answer, all retries are used up.
+	tr, err = mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	_, err = tr.Result()
+	require.Equal(t, ErrNoResponse, err)
+	require.Equal(t, 5, tr.Attempts())
+}
+
+// fakeClock is a dhcpv6.Clock whose time only changes with Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+	} else {
+		c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	}
+	return ch
+}
+
+func (c *fakeClock) numWaiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// Advance moves the clock forward by d, firing the timers that are due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var waiters []fakeWaiter
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiters = append(waiters, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = waiters
+}
+
+func TestClock(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x34, 0x34, 0x34})
+	pkt.AddOption(&dhcpv6.OptElapsedTime{})
+	resp := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x34, 0x34, 0x34})
+
+	var (
+		mu      sync.Mutex
+		elapsed []uint16
+	)
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	// The server only answers the third transmission, which the fake clock
+	// lets happen without waiting for the real timeouts.
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{nil, nil, {resp}},
+		WithRetry(3), WithTimeout(time.Second), WithClock(clock),
+		WithOutgoingHook(func(m *dhcpv6.Message) {
+			mu.Lock()
+			defer mu.Unlock()
+			elapsed = append(elapsed, m.GetOneOption(dhcpv6.OptionElapsedTime).(*dhcpv6.OptElapsedTime).ElapsedTime)
+		}))
+	defer mc.Close()
+
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	for _, timeout := range []time.Duration{time.Second, 2 * time.Second} {
+		require.Eventually(t, func() bool { return clock.numWaiters() == 1 }, 5*time.Second, time.Millisecond)
+		clock.Advance(timeout)
+	}
+	_, err = tr.Result()
+	require.NoError(t, err)
+	require.Equal(t, 3, tr.Attempts())
+
+	// Elapsed times are in hundredths of a second.
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []uint16{0, 100, 300}, elapsed)
+}
+
+func TestSendOnly(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, WithSendOnly(), WithEnsureClientID())
+	defer mc.Close()
+
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x35, 0x35, 0x35})
+	require.NoError(t, mc.Send(AllDHCPServers, pkt))
+	b := make([]byte, maxMessageSize)
+	n, _, err := serverConn.ReadFrom(b)
+	require.NoError(t, err)
+	got, err := dhcpv6.MessageFromBytes(b[:n])
+	require.NoError(t, err)
+	require.Equal(t, pkt.TransactionID, got.TransactionID)
+	require.NotNil(t, got.GetOneOption(dhcpv6.OptionClientID))
+
+	_, err = mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.Equal(t, ErrSendOnly, err)
+	_, err = mc.Solicit(context.Background())
+	require.Equal(t, ErrSendOnly, err)
+}
+
+func TestIdleTimeout(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, fakeServer)
+
+	const idle = time.Minute
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second), WithClock(clock), WithIdleTimeout(idle))
+	defer mc.Close()
+
+	// Transactions keep the Client open.
+	for i := 0; i < 3; i++ {
+		_, err = mc.Solicit(context.Background())
+		require.NoError(t, err)
+		clock.Advance(idle / 2)
+	}
+	require.Equal(t, uint32(0), atomic.LoadUint32(&mc.closed))
+
+	clock.Advance(idle / 2)
+	require.Eventually(t, func() bool { return atomic.LoadUint32(&mc.closed) == 1 }, 2*time.Second, 10*time.Millisecond)
+	_, err = mc.Solicit(context.Background())
+	require.Equal(t, ErrClosed, err)
+	require.Equal(t, ErrClosed, mc.Send(AllDHCPServers, newPacket(dhcpv6.MessageTypeSolicit, [3]byte{1, 2, 3})))
+}
+
+func TestIdleTimeoutNewFails(t *testing.T) {
+	// No idle timer is armed for a Client that New fails to create, which
+	// would otherwise close it without a connection.
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	_, err := New("lo", nil, WithClock(clock), WithIdleTimeout(10*time.Millisecond))
+	require.Error(t, err)
+	require.Equal(t, 0, clock.numWaiters())
+	clock.Advance(time.Second)
+
+	_, err = New("lo", nil, WithIdleTimeout(10*time.Millisecond))
+	require.Error(t, err)
+	time.Sleep(50 * time.Millisecond)
+}
+
+// countingMetrics counts the calls to its Metrics methods.
+type countingMetrics struct {
+	mu              sync.Mutex
+	sent            map[dhcpv6.MessageType]int
+	retransmissions int
+	responses       int
+	timeouts        int
+}
+
+func (m *countingMetrics) MessageSent(t dhcpv6.MessageType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sent == nil {
+		m.sent = make(map[dhcpv6.MessageType]int)
+	}
+	m.sent[t]++
+}
+
+func (m *countingMetrics) Retransmission(t dhcpv6.MessageType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retransmissions++
+}
+
+func (m *countingMetrics) ResponseReceived(t dhcpv6.MessageType, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.responses++
+}
+
+func (m *countingMetrics) Timeout(t dhcpv6.MessageType) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeouts++
+}
+
+func TestMetrics(t *testing.T) {
+	m := &countingMetrics{}
+	xid := dhcpv6.TransactionID{0x36, 0x36, 0x36}
+	// The first Solicit is not answered.
+	mc, serverConn := serveAndClient(context.Background(), [][]*dhcpv6.Message{
+		{},
+		{newPacket(dhcpv6.MessageTypeAdvertise, xid)},
+	}, WithRetry(2), WithTimeout(50*time.Millisecond), WithMetrics(m))
+	defer mc.Close()
+	defer serverConn.Close()
+
+	_, err := mc.SendAndRead(context.Background(), AllDHCPServers, newPacket(dhcpv6.MessageTypeSolicit, xid), nil)
+	require.NoError(t, err)
+	_, err = mc.SendAndRead(context.Background(), AllDHCPServers, newPacket(dhcpv6.MessageTypeRequest, xid), nil)
+	require.Equal(t, ErrNoResponse, err)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	require.Equal(t, map[dhcpv6.MessageType]int{
+		dhcpv6.MessageTypeSolicit: 2,
+		dhcpv6.MessageTypeRequest: 2,
+	}, m.sent)
+	require.Equal(t, 2, m.retransmissions)
+	require.Equal(t, 1, m.responses)
+	require.Equal(t, 1, m.timeouts)
+}
+
+func TestSendRateLimit(t *testing.T) {
+	const (
+		rate = 50
+		n    = 11
+	)
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, WithSendRateLimit(rate))
+	defer mc.Close()
+
+	received := make(chan time.Time, n)
+	go func() {
+		b := make([]byte, maxMessageSize)
+		for {
+			if _, _, err := serverConn.ReadFrom(b); err != nil {
+				return
+			}
+			received <- time.Now()
+		}
+	}()
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		require.NoError(t, mc.Send(AllDHCPServers, newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x36, 0x36, byte(i)})))
+	}
+	var last time.Time
+	for i := 0; i < n; i++ {
+		last = <-received
+	}
+	// The first packet is sent right away, the others at the limited rate.
+	require.True(t, last.Sub(start) >= (n-1)*time.Second/rate, "sent %d packets in %v", n, last.Sub(start))
+
+	// Waiting for the limit stops when the context is done.
+	slow, _ := serveAndClient(context.Background(), nil, WithSendRateLimit(1))
+	defer slow.Close()
+	require.NoError(t, slow.Send(AllDHCPServers, newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x36, 0x36, n})))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = slow.SendAndRead(ctx, AllDHCPServers, newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x36, 0x36, n + 1}), nil)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestMaxPending(t *testing.T) {
+	const n = 3
+
+	// Silent server: every transaction stays pending until cancelled.
+	mc, _ := serveAndClient(context.Background(), nil, WithTimeout(time.Minute), WithMaxPending(n))
+	defer mc.Close()
+
+	var trs []*Transaction
+	for i := 0; i < n; i++ {
+		pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x44, 0x44, byte(i)})
+		tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+		require.NoError(t, err)
+		defer tr.Cancel()
+		trs = append(trs, tr)
+	}
+
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x44, 0x44, n})
+	_, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.Equal(t, ErrTooManyPending, err)
+
+	// Completing a transaction frees up a slot.
+	trs[0].Cancel()
+	_, _ = trs[0].Result()
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	tr.Cancel()
+}
+
+func TestMatchers(t *testing.T) {
+	advertise := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	reply := newPacket(dhcpv6.MessageTypeReply, [3]byte{1, 1, 1})
+	reconfigure := newPacket(dhcpv6.MessageTypeReconfigure, [3]byte{1, 1, 1})
+
+	for _, tt := range []struct {
+		name  string
+		match Matcher
+		want  []bool // advertise, reply, reconfigure
+	}{
+		{"IsAdvertise", IsAdvertise(), []bool{true, false, false}},
+		{"IsReply", IsReply(), []bool{false, true, false}},
+		{"IsReconfigure", IsReconfigure(), []bool{false, false, true}},
+		{"Not", Not(IsReply()), []bool{true, false, true}},
+		{"Or", Or(IsAdvertise(), IsReply()), []bool{true, true, false}},
+		{"And", And(Not(IsAdvertise()), Not(IsReconfigure())), []bool{false, true, false}},
+		{"AndContradiction", And(IsAdvertise(), IsReply()), []bool{false, false, false}},
+		{"EmptyAnd", And(), []bool{true, true, true}},
+		{"EmptyOr", Or(), []bool{false, false, false}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []bool{tt.match(advertise), tt.match(reply), tt.match(reconfigure)}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDHCPv4Query(t *testing.T) {
+	discover, err := dhcpv4.NewDiscovery(net.HardwareAddr{1, 2, 3, 4, 5, 6})
+	require.NoError(t, err)
+	offer, err := dhcpv4.NewReplyFromRequest(discover, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer))
+	require.NoError(t, err)
+	other, err := dhcpv4.NewReplyFromRequest(discover, dhcpv4.WithMessageType(dhcpv4.MessageTypeOffer))
+	require.NoError(t, err)
+	other.TransactionID[0]++
+
+	newResponse := func(m *dhcpv4.DHCPv4) *dhcpv6.Message {
+		resp := &dhcpv6.Message{MessageType: dhcpv6.MessageTypeDHCPv4Response}
+		resp.AddOption(&dhcpv6.OptDHCPv4Msg{Msg: m})
+		return resp
+	}
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{
+		// Response to some other DHCPv4 transaction.
+		newResponse(other),
+		newResponse(offer),
+	}})
+	defer mc.Close()
+
+	rcvd, err := mc.DHCPv4Query(context.Background(), discover)
+	require.NoError(t, err)
+	require.Equal(t, discover.TransactionID, rcvd.TransactionID)
+	require.Equal(t, dhcpv4.MessageTypeOffer, rcvd.MessageType())
+}
+
+func TestRequestServerUnicast(t *testing.T) {
+	for _, tt := range []struct {
+		addr string
+		want TraceEventType
+	}{
+		{addr: "2001:db8::547", want: TraceUnicastAccepted},
+		// Link-local, but the Client does not know its interface.
+		{addr: "fe80::547", want: TraceUnicastRejected},
+		{addr: "ff02::1:2", want: TraceUnicastRejected},
+		{addr: "::1", want: TraceUnicastRejected},
+		{addr: "::ffff:192.0.2.1", want: TraceUnicastRejected},
+	} {
+		t.Run(tt.addr, func(t *testing.T) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(t, err)
+			defer serverConn.Close()
+			go serve(serverConn, fakeServer)
+
+			var events []TraceEvent
+			mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+				WithRetry(1), WithTimeout(2*time.Second),
+				WithTrace(func(e TraceEvent) { events = append(events, e) }))
+			defer mc.Close()
+
+			adv, err := mc.Solicit(context.Background())
+			require.NoError(t, err)
+			adv.AddOption(&dhcpv6.OptUnicast{ServerAddr: net.ParseIP(tt.addr)})
+
+			_, err = mc.Request(context.Background(), adv)
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			require.Equal(t, tt.want, events[0].Type)
+			require.Equal(t, adv, events[0].Message)
+			require.True(t, net.ParseIP(tt.addr).Equal(events[0].Addr.(*net.UDPAddr).IP))
+			if tt.want == TraceUnicastRejected {
+				require.Error(t, events[0].Err)
+			}
+		})
+	}
+}
+
+func TestReplyWithoutServerID(t *testing.T) {
+	// The server omits the Server ID in all Replies.
+	respond := func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType == dhcpv6.MessageTypeSolicit {
+			fakeServer(conn, peer, m)
+			return
+		}
+		resp, err := dhcpv6.NewReplyFromMessage(m)
+		if err != nil {
+			return
+		}
+		conn.WriteTo(resp.ToBytes(), peer)
+	}
+
+	for _, tt := range []struct {
+		name     string
+		opts     []ClientOpt
+		wantInfo bool
+		wantReq  bool
+	}{
+		{name: "default", wantInfo: true},
+		{
+			name:    "optional in Replies to Requests",
+			opts:    []ClientOpt{WithServerIDOptional(dhcpv6.MessageTypeRequest, true)},
+			wantReq: true, wantInfo: true,
+		},
+		{
+			name: "required in Replies to Information-Requests",
+			opts: []ClientOpt{WithServerIDOptional(dhcpv6.MessageTypeInformationRequest, false)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(t, err)
+			defer serverConn.Close()
+			go serve(serverConn, respond)
+
+			opts := append([]ClientOpt{WithRetry(1), WithTimeout(50 * time.Millisecond)}, tt.opts...)
+			mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, opts...)
+			defer mc.Close()
+
+			reply, err := mc.InformationRequest(context.Background())
+			if tt.wantInfo {
+				require.NoError(t, err)
+				require.Nil(t, reply.GetOneOption(dhcpv6.OptionServerID))
+			} else {
+				require.Equal(t, ErrNoResponse, err)
+			}
+
+			adv, err := mc.Solicit(context.Background())
+			require.NoError(t, err)
+			_, err = mc.Request(context.Background(), adv)
+			if tt.wantReq {
+				require.NoError(t, err)
+			} else {
+				require.Equal(t, ErrNoResponse, err)
+			}
+		})
+	}
+}
+
+func TestXIDGenerator(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, fakeServer)
+
+	var n uint8
+	gen := func() dhcpv6.TransactionID {
+		n++
+		return dhcpv6.TransactionID{0xaa, 0, n}
+	}
+	var sent []dhcpv6.TransactionID
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second), WithXIDGenerator(gen),
+		WithOutgoingHook(func(m *dhcpv6.Message) { sent = append(sent, m.TransactionID) }))
+	defer mc.Close()
+
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.TransactionID{0xaa, 0, 1}, adv.TransactionID)
+	reply, err := mc.Request(context.Background(), adv)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.TransactionID{0xaa, 0, 2}, reply.TransactionID)
+	require.Equal(t, []dhcpv6.TransactionID{{0xaa, 0, 1}, {0xaa, 0, 2}}, sent)
+
+	WithXIDGenerator(func() dhcpv6.TransactionID { return dhcpv6.TransactionID{} })(mc)
+	_, err = mc.Solicit(context.Background())
+	require.Error(t, err)
+}
+
+func TestRequestLease(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, fakeServer)
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	lease, err := mc.RequestLease(context.Background(), adv)
+	require.NoError(t, err)
+	require.Equal(t, mc.duid(), lease.ClientID)
+	require.Equal(t, net.HardwareAddr{1, 2, 3, 4, 5, 6}, lease.ServerID.LinkLayerAddr)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")}, lease.Addresses())
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, lease.DNS)
+	require.Equal(t, []string{"example.com"}, lease.DomainSearch)
+	require.Equal(t, dhcpv6.MessageTypeReply, lease.Reply.MessageType)
+}
+
+func TestRequestInconsistentServerTimers(t *testing.T) {
+	// Some servers send T1 and T2 above the preferred lifetime of the
+	// addresses, which the client must not echo back.
+	badIA := func(d dhcpv6.DHCPv6) {
+		d.AddOption(&dhcpv6.OptIANA{
+			IaId: [4]byte{0, 0, 0, 1},
+			T1:   3600,
+			T2:   5400,
+			Options: dhcpv6.Options{&dhcpv6.OptIAAddress{
+				IPv6Addr:          net.ParseIP("2001:db8::1"),
+				PreferredLifetime: 1800,
+				ValidLifetime:     3600,
+			}},
+		})
+	}
+	serverID := dhcpv6.WithServerID(dhcpv6.Duid{
+		Type:          dhcpv6.DUID_LL,
+		HwType:        iana.HWTypeEthernet,
+		LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6},
+	})
+
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	h := &handler{}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		h.handle(conn, peer, m)
+		if m.MessageType != dhcpv6.MessageTypeRequest {
+			fakeServer(conn, peer, m)
+			return
+		}
+		if reply, err := dhcpv6.NewReplyFromMessage(m, serverID, badIA); err == nil {
+			conn.WriteTo(reply.ToBytes(), peer)
+		}
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	adv := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 2, 3})
+	adv.AddOption(&dhcpv6.OptClientId{Cid: mc.duid()})
+	serverID(adv)
+	badIA(adv)
+	lease, err := mc.RequestLease(context.Background(), adv)
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::1")}, lease.Addresses())
+	require.NoError(t, mc.ReleaseAll(context.Background()))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	require.Len(t, h.received, 2)
+	for _, m := range h.received {
+		ia := m.GetOneOption(dhcpv6.OptionIANA).(*dhcpv6.OptIANA)
+		require.Equal(t, uint32(0), ia.T1, "%s", m.MessageType)
+		require.Equal(t, uint32(0), ia.T2, "%s", m.MessageType)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	h := &handler{}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		h.handle(conn, peer, m)
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	prior := &dhcpv6.Lease{
+		ClientID: dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 1, 1, 1, 1, 1}},
+		IANA:     []*dhcpv6.OptIANA{{IaId: [4]byte{0, 0, 0, 1}}},
+		IAPD:     []*dhcpv6.OptIAForPrefixDelegation{{IaId: [4]byte{0, 0, 0, 2}}},
+	}
+	lease, err := mc.Restart(context.Background(), prior)
+	require.NoError(t, err)
+	require.Equal(t, prior.ClientID, lease.ClientID)
+	require.Len(t, lease.IANA, 1)
+	require.Equal(t, [4]byte{0, 0, 0, 1}, lease.IANA[0].IaId)
+	require.Len(t, lease.IAPD, 1)
+	require.Equal(t, [4]byte{0, 0, 0, 2}, lease.IAPD[0].IaId)
+
+	h.mu.Lock()
+	solicit := h.received[0]
+	h.mu.Unlock()
+	require.Equal(t, dhcpv6.MessageTypeSolicit, solicit.MessageType)
+	require.Equal(t, prior.ClientID, solicit.GetOneOption(dhcpv6.OptionClientID).(*dhcpv6.OptClientId).Cid)
+
+	_, err = mc.Restart(context.Background(), nil)
+	require.Error(t, err)
+	_, err = mc.Restart(context.Background(), &dhcpv6.Lease{ClientID: prior.ClientID})
+	require.Error(t, err)
+	_, err = mc.Restart(context.Background(), &dhcpv6.Lease{IANA: prior.IANA})
+	require.Error(t, err)
+}
+
+func TestRestartReleaseAll(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	h := &handler{}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		h.handle(conn, peer, m)
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	// One lease under the Client's own DUID, and one recovered under a
+	// foreign DUID from the same server.
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	_, err = mc.RequestLease(context.Background(), adv)
+	require.NoError(t, err)
+	prior := &dhcpv6.Lease{
+		ClientID: dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 1, 1, 1, 1, 1}},
+		IANA:     []*dhcpv6.OptIANA{{IaId: [4]byte{0, 0, 0, 1}}},
+	}
+	require.NotEqual(t, mc.duid(), prior.ClientID)
+	_, err = mc.Restart(context.Background(), prior)
+	require.NoError(t, err)
+
+	require.NoError(t, mc.ReleaseAll(context.Background()))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var released []dhcpv6.Duid
+	for _, m := range h.received {
+		if m.MessageType == dhcpv6.MessageTypeRelease {
+			released = append(released, m.GetOneOption(dhcpv6.OptionClientID).(*dhcpv6.OptClientId).Cid)
+		}
+	}
+	require.Equal(t, []dhcpv6.Duid{mc.duid(), prior.ClientID}, released)
+}
+
+func TestRequestLeaseIgnoredIA(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	// The server only answers the first IA_NA of the Request.
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType == dhcpv6.MessageTypeRequest {
+			ias := m.GetOption(dhcpv6.OptionIANA)
+			m.Options.Del(dhcpv6.OptionIANA)
+			m.AddOption(ias[0])
+		}
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	adv.AddOption(&dhcpv6.OptIANA{IaId: [4]byte{0, 0, 0, 7}})
+	_, err = mc.RequestLease(context.Background(), adv)
+	require.Error(t, err)
+	ignored, ok := err.(*dhcpv6.IgnoredIAsError)
+	require.True(t, ok)
+	require.Equal(t, [][4]byte{{0, 0, 0, 7}}, ignored.IANA)
+
+	// Request itself does not check the IAs.
+	_, err = mc.Request(context.Background(), adv)
+	require.NoError(t, err)
+}
+
+func TestMatchAllNone(t *testing.T) {
+	reply := newPacket(dhcpv6.MessageTypeReply, [3]byte{1, 1, 1})
+	require.True(t, MatchAll(reply))
+	require.False(t, MatchNone(reply))
+	// MessageTypeNone matches everything, not nothing.
+	require.True(t, IsMessageType(dhcpv6.MessageTypeNone)(reply))
+
+	for _, tt := range []struct {
+		desc  string
+		match Matcher
+		want  error
+	}{
+		{desc: "nil", match: nil},
+		{desc: "MatchAll", match: MatchAll},
+		{desc: "MatchNone", match: MatchNone, want: ErrNoResponse},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{1, 1, 1})
+			mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{reply}},
+				WithTimeout(100*time.Millisecond))
+			defer mc.Close()
+
+			rcvd, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, tt.match)
+			require.Equal(t, tt.want, err)
+			if tt.want == nil {
+				require.NoError(t, ComparePacket(rcvd, reply))
+			}
+		})
+	}
+}
+
+func TestSolicitAll(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	serverA := dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}
+	serverB := dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{6, 5, 4, 3, 2, 1}}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType != dhcpv6.MessageTypeSolicit {
+			return
+		}
+		// Server A's Advertise arrives twice, through two relays.
+		for _, sid := range []dhcpv6.Duid{serverA, serverA, serverB} {
+			adv, err := dhcpv6.NewAdvertiseFromSolicit(m, dhcpv6.WithServerID(sid))
+			if err != nil {
+				return
+			}
+			conn.WriteTo(adv.ToBytes(), peer)
+		}
+		// An Advertise without Server ID is invalid.
+		adv, err := dhcpv6.NewAdvertiseFromSolicit(m)
+		if err == nil {
+			adv.Options.Del(dhcpv6.OptionServerID)
+			conn.WriteTo(adv.ToBytes(), peer)
+		}
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf})
+	defer mc.Close()
+
+	advs, err := mc.SolicitAll(context.Background(), 200*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, advs, 2)
+	var sids []dhcpv6.Duid
+	for _, adv := range advs {
+		sids = append(sids, adv.GetOneOption(dhcpv6.OptionServerID).(*dhcpv6.OptServerId).Sid)
+	}
+	require.Equal(t, []dhcpv6.Duid{serverA, serverB}, sids)
+}
+
+func TestSolicitAllNoResponse(t *testing.T) {
+	mc, _ := serveAndClient(context.Background(), nil)
+	defer mc.Close()
+
+	_, err := mc.SolicitAll(context.Background(), 50*time.Millisecond)
+	require.Equal(t, ErrNoResponse, err)
+}
+
+func TestSolicitAllIncomplete(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, fakeServer)
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf})
+	defer mc.Close()
+
+	// The context ends long before the window does: the Advertise received
+	// meanwhile is returned nonetheless.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	advs, err := mc.SolicitAll(ctx, time.Minute)
+	require.Equal(t, ErrWindowIncomplete, err)
+	require.Len(t, advs, 1)
+
+	// Without any Advertise, the context's error is returned.
+	mc2, _ := serveAndClient(context.Background(), nil)
+	defer mc2.Close()
+	ctx2, cancel2 := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel2()
+	_, err = mc2.SolicitAll(ctx2, time.Minute)
+	require.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestOperationTimeout(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+
+	// Without an overall deadline, the silent server would keep the
+	// exchange busy for 3 attempts of a second.
+	var attempts int
+	mc, _ := serveAndClient(context.Background(), nil,
+		WithRetry(3), WithTimeout(time.Second),
+		WithOperationTimeout(1500*time.Millisecond),
+		WithProgress(func(attempt, max int) { attempts = attempt }))
+	defer mc.Close()
+
+	start := time.Now()
+	_, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	elapsed := time.Since(start)
+	require.Equal(t, context.DeadlineExceeded, err)
+	// The second attempt was aborted midway.
+	require.Equal(t, 2, attempts)
+	require.True(t, elapsed >= 1500*time.Millisecond && elapsed < 2500*time.Millisecond, "elapsed %v", elapsed)
+}
+
+func BenchmarkAdvertiseBurst(b *testing.B) {
+	const burst = 100
+	for _, bufferCap := range []int{0, 1, 10, 100} {
+		b.Run(fmt.Sprintf("cap=%d", bufferCap), func(b *testing.B) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(b, err)
+			defer serverConn.Close()
+			go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+				adv := newPacket(dhcpv6.MessageTypeAdvertise, m.TransactionID).ToBytes()
+				for i := 0; i < burst; i++ {
+					conn.WriteTo(adv, peer)
+				}
+			})
+
+			mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+				WithRetry(1), WithTimeout(10*time.Second), WithBufferCap(bufferCap))
+			defer mc.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{byte(i >> 16), byte(i >> 8), byte(i)})
+				var n int
+				// Consume the whole burst.
+				match := func(*dhcpv6.Message) bool {
+					n++
+					return n == burst
+				}
+				if _, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, match); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReadBufferPool(t *testing.T) {
+	const burst = 10
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		for i := 0; i < burst; i++ {
+			adv := newPacket(dhcpv6.MessageTypeAdvertise, m.TransactionID)
+			adv.AddOption(&dhcpv6.OptServerId{Sid: dhcpv6.Duid{
+				Type:          dhcpv6.DUID_LL,
+				HwType:        iana.HWTypeEthernet,
+				LinkLayerAddr: net.HardwareAddr{0, 0, 0, 0, 0, byte(i)},
+			}})
+			adv.AddOption(&dhcpv6.OptionGeneric{OptionCode: 0xfe00, OptionData: []byte{byte(i)}})
+			conn.WriteTo(adv.ToBytes(), peer)
+		}
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second), WithReadBufferPool())
+	defer mc.Close()
+
+	var msgs []*dhcpv6.Message
+	match := func(m *dhcpv6.Message) bool {
+		msgs = append(msgs, m)
+		return len(msgs) == burst
+	}
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	_, err = mc.SendAndRead(context.Background(), AllDHCPServers, pkt, match)
+	require.NoError(t, err)
+
+	// The buffers were reused for the following packets, which must not
+	// have overwritten the messages parsed earlier.
+	for i, m := range msgs {
+		sid := m.GetOneOption(dhcpv6.OptionServerID).(*dhcpv6.OptServerId)
+		require.Equal(t, net.HardwareAddr{0, 0, 0, 0, 0, byte(i)}, sid.Sid.LinkLayerAddr)
+		require.Equal(t, []byte{byte(i)}, m.GetOneOption(0xfe00).ToBytes())
+	}
+}
+
+func BenchmarkReadBufferPool(b *testing.B) {
+	const burst = 100
+	for _, pool := range []bool{false, true} {
+		b.Run(fmt.Sprintf("pool=%t", pool), func(b *testing.B) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(b, err)
+			defer serverConn.Close()
+			go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+				adv := newPacket(dhcpv6.MessageTypeAdvertise, m.TransactionID).ToBytes()
+				for i := 0; i < burst; i++ {
+					conn.WriteTo(adv, peer)
+				}
+			})
+
+			opts := []ClientOpt{WithRetry(1), WithTimeout(10 * time.Second), WithBufferCap(burst)}
+			if pool {
+				opts = append(opts, WithReadBufferPool())
+			}
+			mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, opts...)
+			defer mc.Close()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{byte(i >> 16), byte(i >> 8), byte(i)})
+				var n int
+				match := func(*dhcpv6.Message) bool {
+					n++
+					return n == burst
+				}
+				if _, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, match); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestHasStatus(t *testing.T) {
+	noStatus := newPacket(dhcpv6.MessageTypeReply, [3]byte{1, 1, 1})
+
+	topLevel := newPacket(dhcpv6.MessageTypeReply, [3]byte{1, 1, 1})
+	topLevel.AddOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoAddrsAvail})
+
+	inIANA := newPacket(dhcpv6.MessageTypeReply, [3]byte{1, 1, 1})
+	inIANA.AddOption(&dhcpv6.OptIANA{Options: dhcpv6.Options{
+		&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoAddrsAvail},
+	}})
+
+	inIAPrefix := newPacket(dhcpv6.MessageTypeReply, [3]byte{1, 1, 1})
+	inIAPrefix.AddOption(&dhcpv6.OptIAForPrefixDelegation{Options: dhcpv6.Options{
+		&dhcpv6.OptIAPrefix{Options: dhcpv6.Options{
+			&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoPrefixAvail},
+		}},
+	}})
+
+	noAddrs := HasStatus(iana.StatusNoAddrsAvail)
+	require.False(t, noAddrs(noStatus))
+	require.True(t, noAddrs(topLevel))
+	require.True(t, noAddrs(inIANA))
+	require.False(t, noAddrs(inIAPrefix))
+	require.True(t, HasStatus(iana.StatusNoPrefixAvail)(inIAPrefix))
+	require.False(t, HasStatus(iana.StatusSuccess)(topLevel))
+}
+
+func TestHasDelegatedPrefix(t *testing.T) {
+	pfx := net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(56, 128)}
+	prefix, err := dhcpv6.NewOptIAPrefix(pfx, time.Hour, 2*time.Hour)
+	require.NoError(t, err)
+	failed, err := dhcpv6.NewOptIAPrefix(pfx, 0, 0, &dhcpv6.OptStatusCode{StatusCode: iana.StatusNoPrefixAvail})
+	require.NoError(t, err)
+
+	delegated := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	delegated.AddOption(&dhcpv6.OptIAForPrefixDelegation{Options: dhcpv6.Options{prefix}})
+
+	noIAPD := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	noIAPD.AddOption(&dhcpv6.OptIANA{})
+
+	emptyIAPD := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	emptyIAPD.AddOption(&dhcpv6.OptIAForPrefixDelegation{})
+
+	noPrefixAvail := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	noPrefixAvail.AddOption(&dhcpv6.OptIAForPrefixDelegation{Options: dhcpv6.Options{
+		&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoPrefixAvail},
+	}})
+
+	nested := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	nested.AddOption(&dhcpv6.OptIAForPrefixDelegation{Options: dhcpv6.Options{failed}})
+
+	topLevel := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	topLevel.AddOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoPrefixAvail})
+	topLevel.AddOption(&dhcpv6.OptIAForPrefixDelegation{Options: dhcpv6.Options{prefix}})
+
+	// One IA_PD without prefixes does not prevent another from matching.
+	mixed := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{1, 1, 1})
+	mixed.AddOption(&dhcpv6.OptIAForPrefixDelegation{IaId: [4]byte{1}, Options: dhcpv6.Options{
+		&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoPrefixAvail},
+	}})
+	mixed.AddOption(&dhcpv6.OptIAForPrefixDelegation{IaId: [4]byte{2}, Options: dhcpv6.Options{prefix}})
+
+	m := HasDelegatedPrefix()
+	require.True(t, m(delegated))
+	require.False(t, m(noIAPD))
+	require.False(t, m(emptyIAPD))
+	require.False(t, m(noPrefixAvail))
+	require.False(t, m(nested))
+	require.False(t, m(topLevel))
+	require.True(t, m(mixed))
+}
+
+func TestSendAndReadHasStatus(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeRequest, [3]byte{0x33, 0x33, 0x33})
+	failure := newPacket(dhcpv6.MessageTypeReply, [3]byte{0x33, 0x33, 0x33})
+	failure.AddOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusUnspecFail})
+	success := newPacket(dhcpv6.MessageTypeReply, [3]byte{0x33, 0x33, 0x33})
+	success.AddOption(&dhcpv6.OptStatusCode{StatusCode: iana.StatusSuccess})
+
+	// The failure Reply is skipped, and the Success one received after
+	// retransmitting.
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{failure}, {success}},
+		WithRetry(2), WithTimeout(100*time.Millisecond))
+	defer mc.Close()
+
+	rcvd, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, HasStatus(iana.StatusSuccess))
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, success))
+}
+
+func TestExpect(t *testing.T) {
+	xid := dhcpv6.TransactionID{0x55, 0x55, 0x55}
+	mc, serverConn := serveAndClient(context.Background(), nil)
+	defer mc.Close()
+
+	ch, cancel, err := mc.Expect(xid, IsReply())
+	require.NoError(t, err)
+	_, _, err = mc.Expect(xid, nil)
+	require.Error(t, err)
+
+	// Whatever was sent out of band, the responses are distributed by the
+	// Client.
+	reply := newPacket(dhcpv6.MessageTypeReply, xid)
+	for _, m := range []*dhcpv6.Message{newPacket(dhcpv6.MessageTypeAdvertise, xid), reply} {
+		_, err := serverConn.WriteTo(m.ToBytes(), nil)
+		require.NoError(t, err)
+	}
+	select {
+	case rcvd := <-ch:
+		require.NoError(t, ComparePacket(rcvd.Message, reply))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+
+	cancel()
+	_, ok := <-ch
+	require.False(t, ok)
+	_, cancel, err = mc.Expect(xid, nil)
+	require.NoError(t, err)
+	cancel()
+}
+
+func TestExpectClose(t *testing.T) {
+	xid := dhcpv6.TransactionID{0x55, 0x55, 0x55}
+	mc, serverConn := serveAndClient(context.Background(), nil, WithBufferCap(1))
+
+	ch, cancel, err := mc.Expect(xid, nil)
+	require.NoError(t, err)
+	defer cancel()
+
+	// Nobody receives: the receive loop ends up blocked on ch.
+	for i := 0; i < 3; i++ {
+		_, err := serverConn.WriteTo(newPacket(dhcpv6.MessageTypeReply, xid).ToBytes(), nil)
+		require.NoError(t, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		mc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked")
+	}
+	for range ch {
+	}
+}
+
+// destConn records the destination of the packets written to it.
+type destConn struct {
+	net.PacketConn
+
+	mu    sync.Mutex
+	dests []net.Addr
+}
+
+func (c *destConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	c.dests = append(c.dests, addr)
+	c.mu.Unlock()
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+func TestLinkLocalZone(t *testing.T) {
+	for _, tt := range []struct {
+		dest     *net.UDPAddr
+		wantZone string
+	}{
+		{dest: &net.UDPAddr{IP: net.ParseIP("fe80::547"), Port: 547}, wantZone: "eth0"},
+		{dest: &net.UDPAddr{IP: net.ParseIP("fe80::547"), Port: 547, Zone: "eth1"}, wantZone: "eth1"},
+		{dest: AllDHCPRelayAgentsAndServers, wantZone: "eth0"},
+		{dest: AllDHCPServers, wantZone: ""},
+		{dest: &net.UDPAddr{IP: net.ParseIP("2001:db8::547"), Port: 547}, wantZone: ""},
+	} {
+		t.Run(tt.dest.String(), func(t *testing.T) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(t, err)
+			defer serverConn.Close()
+			h := &handler{responses: [][]*dhcpv6.Message{
+				{newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})},
+			}}
+			go serve(serverConn, h.handle)
+
+			conn := &destConn{PacketConn: clientConn}
+			mc := NewWithConn(conn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+				WithInterface("eth0"), WithRetry(1), WithTimeout(2*time.Second))
+			defer mc.Close()
+
+			orig := *tt.dest
+			pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+			_, err = mc.SendAndRead(context.Background(), tt.dest, pkt, IsAdvertise())
+			require.NoError(t, err)
+
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			require.Len(t, conn.dests, 1)
+			got := conn.dests[0].(*net.UDPAddr)
+			require.Equal(t, tt.wantZone, got.Zone)
+			require.True(t, got.IP.Equal(tt.dest.IP))
+			// The caller's address is left untouched.
+			require.Equal(t, orig, *tt.dest)
+		})
+	}
+}
+
+// shortWriteConn is a PacketConn reporting that only half of each packet was
+// written.
+type shortWriteConn struct {
+	net.PacketConn
+}
+
+func (c shortWriteConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.PacketConn.WriteTo(b[:len(b)/2], addr)
+}
+
+func TestShortWrite(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	mc := NewWithConn(shortWriteConn{clientConn}, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf})
+	defer mc.Close()
+
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	_, err = mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "short write")
+
+	// The Transaction ID is released.
+	mc.pendingMu.Lock()
+	require.Empty(t, mc.pending)
+	mc.pendingMu.Unlock()
+}
+
+func TestNoRetry(t *testing.T) {
+	mc, serverConn := serveAndClient(context.Background(), nil, WithRetry(0))
+	defer mc.Close()
+	defer serverConn.Close()
+
+	// The Transaction ID is released even though nothing waited for a
+	// response, so that it can be used again.
+	for i := 0; i < 2; i++ {
+		pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+		_, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+		require.Equal(t, ErrNoResponse, err)
+	}
+	mc.pendingMu.Lock()
+	require.Empty(t, mc.pending)
+	mc.pendingMu.Unlock()
+}
+
+func TestOutgoingHook(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	var calls int
+	hook := func(m *dhcpv6.Message) {
+		calls++
+		m.UpdateOption(&dhcpv6.OptVendorClass{EnterpriseNumber: 32473, Data: [][]byte{[]byte(fmt.Sprintf("attempt-%d", calls))}})
+	}
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(2), WithTimeout(50*time.Millisecond), WithOutgoingHook(hook))
+	defer mc.Close()
+
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	defer tr.Cancel()
+
+	for i := 1; i <= 2; i++ {
+		b := make([]byte, maxMessageSize)
+		n, _, err := serverConn.ReadFrom(b)
+		require.NoError(t, err)
+		m, err := dhcpv6.MessageFromBytes(b[:n])
+		require.NoError(t, err)
+		vcs := m.GetOption(dhcpv6.OptionVendorClass)
+		require.Len(t, vcs, 1)
+		require.Equal(t, [][]byte{[]byte(fmt.Sprintf("attempt-%d", i))}, vcs[0].(*dhcpv6.OptVendorClass).Data)
+	}
+}
+
+func TestReleaseAll(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	h := &handler{}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		h.handle(conn, peer, m)
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	_, err = mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true, WantPD: true})
+	require.NoError(t, err)
+	require.NoError(t, mc.ReleaseAll(context.Background()))
+
+	h.mu.Lock()
+	release := h.received[len(h.received)-1]
+	h.mu.Unlock()
+	require.Equal(t, dhcpv6.MessageTypeRelease, release.MessageType)
+	require.Len(t, release.GetOption(dhcpv6.OptionIANA), 1)
+	require.Len(t, release.GetOption(dhcpv6.OptionIAPD), 1)
+	require.NotNil(t, release.GetOneOption(dhcpv6.OptionServerID))
+
+	// Nothing is left to release.
+	require.NoError(t, mc.ReleaseAll(context.Background()))
+	h.mu.Lock()
+	require.Equal(t, release, h.received[len(h.received)-1])
+	h.mu.Unlock()
+}
+
+func TestReleaseAllFailure(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType != dhcpv6.MessageTypeRelease {
+			fakeServer(conn, peer, m)
+			return
+		}
+		// The server does not know the IA_PD.
+		resp, err := dhcpv6.NewReplyFromMessage(m)
+		if err != nil {
+			return
+		}
+		resp.AddOption(m.GetOneOption(dhcpv6.OptionServerID))
+		for _, opt := range m.GetOption(dhcpv6.OptionIAPD) {
+			resp.AddOption(&dhcpv6.OptIAForPrefixDelegation{
+				IaId:    opt.(*dhcpv6.OptIAForPrefixDelegation).IaId,
+				Options: dhcpv6.Options{&dhcpv6.OptStatusCode{StatusCode: iana.StatusNoBinding}},
+			})
+		}
+		conn.WriteTo(resp.ToBytes(), peer)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	_, err = mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true, WantPD: true})
+	require.NoError(t, err)
+	err = mc.ReleaseAll(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "IA_PD faceb00c: NoBinding")
+	rerr, ok := err.(ReleaseError)
+	require.True(t, ok)
+	require.Len(t, rerr, 1)
+	require.Equal(t, dhcpv6.OptionIAPD, rerr[0].Code)
+	require.Equal(t, [4]byte{0xfa, 0xce, 0xb0, 0x0c}, rerr[0].IAID)
+	require.Equal(t, net.HardwareAddr{1, 2, 3, 4, 5, 6}, rerr[0].Server.LinkLayerAddr)
+	require.Equal(t, "NoBinding", rerr[0].Unwrap().Error())
+}
+
+func TestReleaseAllNestedMerge(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	h := &handler{}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		h.handle(conn, peer, m)
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	newLease := func(iaid byte) *dhcpv6.Lease {
+		return &dhcpv6.Lease{
+			ClientID: mc.duid(),
+			ServerID: dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}},
+			IANA:     []*dhcpv6.OptIANA{{IaId: [4]byte{0, 0, 0, iaid}}},
+		}
+	}
+	// b, which c was merged into, is itself merged into a: Split returns a
+	// copy of b rather than b.
+	a, b, c := newLease(1), newLease(2), newLease(3)
+	require.NoError(t, b.Merge(c))
+	require.NoError(t, a.Merge(b))
+	mc.leases = []*dhcpv6.Lease{a, b, c}
+
+	require.NoError(t, mc.ReleaseAll(context.Background()))
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var released [][4]byte
+	for _, m := range h.received {
+		for _, opt := range m.GetOption(dhcpv6.OptionIANA) {
+			released = append(released, opt.(*dhcpv6.OptIANA).IaId)
+		}
+	}
+	require.Equal(t, [][4]byte{{0, 0, 0, 1}, {0, 0, 0, 2}, {0, 0, 0, 3}}, released)
+}
+
+func TestLastAdvertise(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	// The first Request is lost.
+	var requests int32
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType == dhcpv6.MessageTypeRequest && atomic.AddInt32(&requests, 1) == 1 {
+			return
+		}
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(50*time.Millisecond))
+	defer mc.Close()
+	require.Nil(t, mc.LastAdvertise())
+
+	advertise, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, advertise, mc.LastAdvertise())
+
+	_, err = mc.Request(context.Background(), advertise)
+	require.Equal(t, ErrNoResponse, err)
+
+	reply, err := mc.Request(context.Background(), mc.LastAdvertise())
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeReply, reply.MessageType)
+	require.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestSendRaw(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	// A Solicit whose Elapsed Time option is one byte short.
+	raw := []byte{
+		byte(dhcpv6.MessageTypeSolicit), 0xaa, 0xbb, 0xcc,
+		0, 8, 0, 1, 0,
+	}
+	received := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, maxMessageSize)
+		n, peer, err := serverConn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		received <- b[:n]
+		adv := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0xaa, 0xbb, 0xcc})
+		serverConn.WriteTo(adv.ToBytes(), peer)
+	}()
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second), WithEnsureClientID())
+	defer mc.Close()
+
+	resp, err := mc.SendRaw(context.Background(), AllDHCPServers, raw, IsAdvertise())
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.TransactionID{0xaa, 0xbb, 0xcc}, resp.TransactionID)
+	// The bytes were sent untouched.
+	require.Equal(t, raw, <-received)
+
+	_, err = mc.SendRaw(context.Background(), AllDHCPServers, raw[:3], nil)
+	require.Error(t, err)
+}
+
+func TestSendRawNoRetry(t *testing.T) {
+	mc, serverConn := serveAndClient(context.Background(), nil, WithRetry(0))
+	defer mc.Close()
+	defer serverConn.Close()
+
+	raw := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0xaa, 0xbb, 0xcc}).ToBytes()
+	for i := 0; i < 2; i++ {
+		_, err := mc.SendRaw(context.Background(), AllDHCPServers, raw, nil)
+		require.Equal(t, ErrNoResponse, err)
+	}
+	mc.pendingMu.Lock()
+	require.Empty(t, mc.pending)
+	mc.pendingMu.Unlock()
+}
+
+func TestSolicitPD(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	h := &handler{}
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		h.handle(conn, peer, m)
+		fakeServer(conn, peer, m)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	hint := net.IPNet{IP: net.ParseIP("2001:db8:1::"), Mask: net.CIDRMask(56, 128)}
+	adv, err := mc.SolicitPD(context.Background(), dhcpv6.WithIAPrefix(hint, 0, 0))
+	require.NoError(t, err)
+	require.Len(t, adv.DelegatedPrefixes(), 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	solicit := h.received[0]
+	require.Equal(t, dhcpv6.MessageTypeSolicit, solicit.MessageType)
+	require.Empty(t, solicit.GetOption(dhcpv6.OptionIANA))
+	iaPDs := solicit.GetOption(dhcpv6.OptionIAPD)
+	require.Len(t, iaPDs, 1)
+	iaPD := iaPDs[0].(*dhcpv6.OptIAForPrefixDelegation)
+	require.Equal(t, defaultIAID, iaPD.IaId)
+	// The prefix hint went into the IA_PD.
+	require.Len(t, iaPD.Options.Get(dhcpv6.OptionIAPrefix), 1)
+	require.NotNil(t, solicit.GetOneOption(dhcpv6.OptionClientID))
+}
+
+func TestInformationRequestCaptivePortal(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType != dhcpv6.MessageTypeInformationRequest {
+			return
+		}
+		reply, err := dhcpv6.NewReplyFromMessage(m, dhcpv6.WithDNS(net.ParseIP("2001:db8::53")))
+		if err != nil {
+			return
+		}
+		if m.IsOptionRequested(dhcpv6.OptionCaptivePortal) {
+			reply.AddOption(&dhcpv6.OptCaptivePortal{URI: "https://portal.example.com/api"})
+		}
+		conn.WriteTo(reply.ToBytes(), peer)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	reply, err := mc.InformationRequest(context.Background())
+	require.NoError(t, err)
+	_, ok := reply.CaptivePortalURI()
+	require.False(t, ok)
+
+	reply, err = mc.InformationRequest(context.Background(), dhcpv6.WithRequestedOptions(dhcpv6.OptionCaptivePortal))
+	require.NoError(t, err)
+	uri, ok := reply.CaptivePortalURI()
+	require.True(t, ok)
+	require.Equal(t, "https://portal.example.com/api", uri)
+}
+
+func TestDuplicateHandler(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeRequest, [3]byte{0x33, 0x33, 0x33})
+	resp := newPacket(dhcpv6.MessageTypeReply, [3]byte{0x33, 0x33, 0x33})
+	// A stray Reply for an unknown transaction is not a duplicate.
+	stray := newPacket(dhcpv6.MessageTypeReply, [3]byte{0x44, 0x44, 0x44})
+
+	dups := make(chan *dhcpv6.Message, 10)
+	mc, serverConn := serveAndClient(context.Background(), [][]*dhcpv6.Message{{resp}},
+		WithDuplicateHandler(func(m *dhcpv6.Message) { dups <- m }))
+	defer mc.Close()
+
+	rcvd, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, IsReply())
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, resp))
+
+	// The server sends its Reply again after the transaction completed.
+	_, err = serverConn.WriteTo(stray.ToBytes(), nil)
+	require.NoError(t, err)
+	_, err = serverConn.WriteTo(resp.ToBytes(), nil)
+	require.NoError(t, err)
+
+	select {
+	case dup := <-dups:
+		require.NoError(t, ComparePacket(dup, resp))
+	case <-time.After(time.Second):
+		t.Fatal("duplicate not reported")
+	}
+	select {
+	case dup := <-dups:
+		t.Fatalf("unexpected duplicate %v", dup)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestZeroXID(t *testing.T) {
+	// The zero XID is valid on the wire and must be matched like any other.
+	pkt := newPacket(dhcpv6.MessageTypeRequest, [3]byte{0, 0, 0})
+	resp := newPacket(dhcpv6.MessageTypeReply, [3]byte{0, 0, 0})
+
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{
+		newPacket(dhcpv6.MessageTypeReply, [3]byte{0, 0, 1}),
+		resp,
+	}})
+	defer mc.Close()
+
+	rcvd, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, resp))
+}
+
+// fakeServer answers Solicits with an Advertise and Requests with a Reply
+// granting the requested IAs, along with DNS and NTP configuration.
+func fakeServer(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+	serverID := dhcpv6.WithServerID(dhcpv6.Duid{
+		Type:          dhcpv6.DUID_LL,
+		HwType:        iana.HWTypeEthernet,
+		LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6},
+	})
+	grant := func(d dhcpv6.DHCPv6) {
+		for _, opt := range m.GetOption(dhcpv6.OptionIANA) {
+			d.AddOption(&dhcpv6.OptIANA{
+				IaId: opt.(*dhcpv6.OptIANA).IaId,
+				Options: dhcpv6.Options{
+					&dhcpv6.OptIAAddress{
+						IPv6Addr:          net.ParseIP("2001:db8::1"),
+						PreferredLifetime: 3600,
+						ValidLifetime:     7200,
+					},
+					&dhcpv6.OptIAAddress{
+						IPv6Addr:          net.ParseIP("2001:db8::2"),
+						PreferredLifetime: 3600,
+						ValidLifetime:     7200,
+					},
+				},
+			})
+		}
+		for _, opt := range m.GetOption(dhcpv6.OptionIAPD) {
+			prefix := &dhcpv6.OptIAPrefix{}
+			prefix.SetPrefixLength(56)
+			prefix.SetIPv6Prefix(net.ParseIP("2001:db8:1::"))
+			d.AddOption(&dhcpv6.OptIAForPrefixDelegation{
+				IaId:    opt.(*dhcpv6.OptIAForPrefixDelegation).IaId,
+				Options: dhcpv6.Options{prefix},
+			})
+		}
+	}
+
+	var resp *dhcpv6.Message
+	var err error
+	switch m.MessageType {
+	case dhcpv6.MessageTypeSolicit:
+		resp, err = dhcpv6.NewAdvertiseFromSolicit(m, serverID, grant)
+	case dhcpv6.MessageTypeDecline, dhcpv6.MessageTypeRelease:
+		resp, err = dhcpv6.NewReplyFromMessage(m, serverID)
+	case dhcpv6.MessageTypeRequest:
+		resp, err = dhcpv6.NewReplyFromMessage(m, serverID, grant,
+			dhcpv6.WithDNS(net.ParseIP("2001:db8::53")),
+			dhcpv6.WithDomainSearchList("example.com"))
+		if err == nil && m.IsOptionRequested(dhcpv6.OptionNTPServer) {
+			resp.AddOption(&dhcpv6.OptNTPServer{Suboptions: dhcpv6.Options{
+				&dhcpv6.OptionGeneric{
+					OptionCode: dhcpv6.NTPSuboptionSrvAddr,
+					OptionData: net.ParseIP("2001:db8::123"),
+				},
+			}})
+		}
+	}
+	if err != nil || resp == nil {
+		return
+	}
+	conn.WriteTo(resp.ToBytes(), peer)
+}
+
+func TestSolicitFull(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		cfg       SolicitConfig
+		wantAddrs []net.IP
+		wantPD    int
+	}{
+		{
+			desc:      "address",
+			cfg:       SolicitConfig{WantAddress: true},
+			wantAddrs: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+		},
+		{
+			desc:   "prefix",
+			cfg:    SolicitConfig{WantPD: true},
+			wantPD: 1,
+		},
+		{
+			desc:      "address and prefix",
+			cfg:       SolicitConfig{WantAddress: true, WantPD: true, RequestedOptions: []dhcpv6.OptionCode{dhcpv6.OptionNTPServer}},
+			wantAddrs: []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")},
+			wantPD:    1,
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(t, err)
+			defer serverConn.Close()
+			go serve(serverConn, fakeServer)
+
+			mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+				WithRetry(1), WithTimeout(2*time.Second), WithTimerNormalization())
+			defer mc.Close()
+
+			lease, err := mc.SolicitFull(context.Background(), tt.cfg)
+			require.NoError(t, err)
+			for _, ia := range lease.IANA {
+				// The fake server leaves T1 and T2 to the client.
+				require.Equal(t, uint32(1800), ia.T1)
+				require.Equal(t, uint32(2880), ia.T2)
+			}
+			require.Equal(t, tt.wantAddrs, lease.Addresses())
+			require.Len(t, lease.Prefixes(), tt.wantPD)
+			require.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, lease.DNS)
+			require.Equal(t, []string{"example.com"}, lease.DomainSearch)
+			require.Equal(t, []net.IP{net.ParseIP("2001:db8::123")}, lease.NTPServers)
+		})
+	}
+
+	mc, _ := serveAndClient(context.Background(), nil)
+	defer mc.Close()
+	_, err := mc.SolicitFull(context.Background(), SolicitConfig{})
+	require.Error(t, err)
+}
+
+func TestPassiveHandler(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	unmatched := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x44, 0x44, 0x44})
+	resp := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})
+
+	var mu sync.Mutex
+	var seen []*dhcpv6.Message
+	handler := func(m *dhcpv6.Message, peer net.Addr) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, m)
+		// Modifying the copy must not affect the matched message.
+		m.MessageType = dhcpv6.MessageTypeNone
+	}
+
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{{unmatched, resp}}, WithPassiveHandler(handler))
+	defer mc.Close()
+
+	rcvd, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	require.NoError(t, ComparePacket(rcvd, resp))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, seen, 2)
+	require.Equal(t, unmatched.TransactionID, seen[0].TransactionID)
+	require.Equal(t, resp.TransactionID, seen[1].TransactionID)
+}
+
+func TestProgress(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+
+	var attempts [][2]int
+	progress := func(attempt, max int) {
+		attempts = append(attempts, [2]int{attempt, max})
+	}
+
+	mc, _ := serveAndClient(context.Background(), nil,
+		WithRetry(3), WithTimeout(10*time.Millisecond), WithProgress(progress))
+	defer mc.Close()
+
+	_, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.Equal(t, ErrNoResponse, err)
+	require.Equal(t, [][2]int{{1, 3}, {2, 3}, {3, 3}}, attempts)
+}
+
+func TestProgressUnlimited(t *testing.T) {
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	adv := newPacket(dhcpv6.MessageTypeAdvertise, [3]byte{0x33, 0x33, 0x33})
+
+	var attempts [][2]int
+	progress := func(attempt, max int) {
+		attempts = append(attempts, [2]int{attempt, max})
+	}
+
+	// Only the third transmission is answered.
+	mc, _ := serveAndClient(context.Background(), [][]*dhcpv6.Message{nil, nil, {adv}},
+		WithRetry(-1), WithTimeout(10*time.Millisecond), WithProgress(progress))
+	defer mc.Close()
+
+	_, err := mc.SendAndRead(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	require.Equal(t, [][2]int{{1, 0}, {2, 0}, {3, 0}}, attempts)
+}
+
+func TestAddressSelector(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	declines := make(chan *dhcpv6.Message, 1)
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType == dhcpv6.MessageTypeDecline {
+			declines <- m
+		}
+		fakeServer(conn, peer, m)
+	})
+
+	selector := func(candidates []net.IP) []net.IP {
+		require.Len(t, candidates, 2)
+		return candidates[1:]
+	}
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second), WithAddressSelector(selector))
+	defer mc.Close()
+
+	lease, err := mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true})
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::2")}, lease.Addresses())
+
+	decline := <-declines
+	require.NotNil(t, decline.GetOneOption(dhcpv6.OptionServerID))
+	iaNa := decline.GetOneOption(dhcpv6.OptionIANA).(*dhcpv6.OptIANA)
+	require.Equal(t, defaultIAID, iaNa.IaId)
+	addrs := iaNa.Options.Get(dhcpv6.OptionIAAddr)
+	require.Len(t, addrs, 1)
+	require.True(t, addrs[0].(*dhcpv6.OptIAAddress).IPv6Addr.Equal(net.ParseIP("2001:db8::1")))
+}
+
+func TestRetryMaxTimeout(t *testing.T) {
+	c := &Client{
+		timeout:    time.Second,
+		retry:      10,
+		maxTimeout: 4 * time.Second,
+	}
+	var timeouts []time.Duration
+	err := c.retryFn(func(timeout time.Duration) error {
+		timeouts = append(timeouts, timeout)
+		return errDeadlineExceeded
+	})
+	require.Equal(t, errDeadlineExceeded, err)
+	require.Len(t, timeouts, 10)
+	require.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, timeouts[:3])
+	for _, timeout := range timeouts[3:] {
+		require.True(t, timeout >= 3600*time.Millisecond && timeout <= 4400*time.Millisecond,
+			"timeout %v is not within 10%% of MRT", timeout)
+	}
+
+	// Without MRT, the timeout keeps doubling.
+	c.maxTimeout = 0
+	timeouts = nil
+	c.retryFn(func(timeout time.Duration) error {
+		timeouts = append(timeouts, timeout)
+		return errDeadlineExceeded
+	})
+	require.Equal(t, 512*time.Second, timeouts[9])
+}
+
+func TestReconnect(t *testing.T) {
+	var serverConns []net.PacketConn
+	newConn := func() (Transport, error) {
+		clientConn, serverConn, err := packetSocketPair()
+		if err != nil {
+			return nil, err
+		}
+		serverConns = append(serverConns, serverConn)
+		go serve(serverConn, fakeServer)
+		return clientConn, nil
+	}
+	defer func() {
+		for _, conn := range serverConns {
+			conn.Close()
+		}
+	}()
+
+	conn, err := newConn()
+	require.NoError(t, err)
+	mc := newClient(conn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, WithRetry(1), WithTimeout(2*time.Second))
+	mc.newConn = newConn
+	mc.start()
+	defer mc.Close()
+
+	lease, err := mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true})
+	require.NoError(t, err)
+
+	require.NoError(t, mc.Reconnect())
+	require.Len(t, serverConns, 2)
+
+	// The new connection works, and the Client ID is preserved.
+	renewed, err := mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true})
+	require.NoError(t, err)
+	require.Equal(t, lease.ClientID, renewed.ClientID)
+
+	require.NoError(t, mc.Close())
+	require.Error(t, mc.Reconnect())
+}
+
+func TestReconnectWithConn(t *testing.T) {
+	mc, _ := serveAndClient(context.Background(), nil)
+	defer mc.Close()
+	require.Error(t, mc.Reconnect())
+}
+
+func TestDUIDLL(t *testing.T) {
+	hwaddr := net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}
+	c1 := newClient(nil, hwaddr, WithDUIDLL())
+	c2 := newClient(nil, hwaddr, WithDUIDLL())
+
+	d1, d2 := c1.duid(), c2.duid()
+	require.Equal(t, dhcpv6.DUID_LL, d1.Type)
+	require.Equal(t, hwaddr, d1.LinkLayerAddr)
+	require.Equal(t, d1.ToBytes(), d2.ToBytes())
+
+	d3 := newClient(nil, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0x0}, WithDUIDLL()).duid()
+	require.NotEqual(t, d1.ToBytes(), d3.ToBytes())
+}
+
+func TestHardwareType(t *testing.T) {
+	// InfiniBand link-layer addresses are 20 bytes long.
+	hwaddr := make(net.HardwareAddr, 20)
+	for i := range hwaddr {
+		hwaddr[i] = byte(i)
+	}
+
+	d := newClient(nil, hwaddr, WithHardwareType(iana.HWTypeInfiniband), WithDUIDLL()).duid()
+	b := d.ToBytes()
+	require.Equal(t, []byte{0, 3}, b[0:2], "DUID type")
+	require.Equal(t, []byte{0, 32}, b[2:4], "hardware type")
+	require.Equal(t, []byte(hwaddr), b[4:])
+
+	d = newClient(nil, hwaddr, WithHardwareType(iana.HWTypeInfiniband)).duid()
+	b = d.ToBytes()
+	require.Equal(t, []byte{0, 1}, b[0:2], "DUID type")
+	require.Equal(t, []byte{0, 32}, b[2:4], "hardware type")
+
+	// Unknown hardware types default to Ethernet.
+	for _, htype := range []iana.HWType{0, 200} {
+		d = newClient(nil, hwaddr, WithHardwareType(htype)).duid()
+		require.Equal(t, iana.HWTypeEthernet, d.HwType)
+	}
+}
+
+func TestNewWithoutHardwareAddr(t *testing.T) {
+	_, err := New("", nil)
+	require.Error(t, err)
+	_, err = New("", net.HardwareAddr{}, WithDUIDLL())
+	require.Error(t, err)
+
+	duid := dhcpv6.Duid{Type: dhcpv6.DUID_UUID, Uuid: make([]byte, 16)}
+	mc := newClient(nil, nil, WithClientDUID(duid))
+	require.NoError(t, mc.checkDUID())
+	require.Equal(t, duid, mc.duid())
+}
+
+func TestElapsedTime(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(2), WithTimeout(50*time.Millisecond))
+	defer mc.Close()
+
+	// A stale elapsed time is reset on the first transmission.
+	pkt := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	pkt.AddOption(&dhcpv6.OptElapsedTime{ElapsedTime: 0x1234})
+	tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, pkt, nil)
+	require.NoError(t, err)
+	defer tr.Cancel()
+
+	var elapsed [][]byte
+	for i := 0; i < 2; i++ {
+		b := make([]byte, maxMessageSize)
+		n, _, err := serverConn.ReadFrom(b)
+		require.NoError(t, err)
+		m, err := dhcpv6.MessageFromBytes(b[:n])
+		require.NoError(t, err)
+		elapsed = append(elapsed, m.GetOneOption(dhcpv6.OptionElapsedTime).ToBytes())
+	}
+	require.Equal(t, []byte{0, 0}, elapsed[0])
+	// The retransmission happens at least 50ms later, i.e. 5 hundredths.
+	require.True(t, elapsed[1][1] >= 5 && elapsed[1][0] == 0, "got elapsed time %v", elapsed[1])
+}
+
+func TestEnsureClientID(t *testing.T) {
+	hwaddr := net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}
+	existing := dhcpv6.Duid{
+		Type:          dhcpv6.DUID_LL,
+		HwType:        iana.HWTypeEthernet,
+		LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6},
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		send    *dhcpv6.Message
+		opts    []ClientOpt
+		wantCID bool
+		want    net.HardwareAddr
+	}{
+		{
+			desc:    "request without client ID",
+			send:    newPacket(dhcpv6.MessageTypeRequest, [3]byte{0x33, 0x33, 0x33}),
+			opts:    []ClientOpt{WithEnsureClientID()},
+			wantCID: true,
+			want:    hwaddr,
+		},
+		{
+			desc: "request with client ID",
+			send: &dhcpv6.Message{
+				MessageType:   dhcpv6.MessageTypeRequest,
+				TransactionID: [3]byte{0x33, 0x33, 0x33},
+				Options:       dhcpv6.Options{&dhcpv6.OptClientId{Cid: existing}},
+			},
+			opts:    []ClientOpt{WithEnsureClientID()},
+			wantCID: true,
+			want:    existing.LinkLayerAddr,
+		},
+		{
+			desc: "reply is left untouched",
+			send: newPacket(dhcpv6.MessageTypeReply, [3]byte{0x33, 0x33, 0x33}),
+			opts: []ClientOpt{WithEnsureClientID()},
+		},
+		{
+			desc: "disabled",
+			send: newPacket(dhcpv6.MessageTypeRequest, [3]byte{0x33, 0x33, 0x33}),
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			clientConn, serverConn, err := packetSocketPair()
+			require.NoError(t, err)
+			defer serverConn.Close()
+
+			mc := NewWithConn(clientConn, hwaddr, tt.opts...)
+			defer mc.Close()
+
+			tr, err := mc.SendAndReadAsync(context.Background(), AllDHCPServers, tt.send, nil)
+			require.NoError(t, err)
+			defer tr.Cancel()
+
+			b := make([]byte, maxMessageSize)
+			n, _, err := serverConn.ReadFrom(b)
+			require.NoError(t, err)
+			m, err := dhcpv6.MessageFromBytes(b[:n])
+			require.NoError(t, err)
+
+			cids := m.GetOption(dhcpv6.OptionClientID)
+			if !tt.wantCID {
+				require.Empty(t, cids)
+				return
+			}
+			require.Len(t, cids, 1)
+			require.Equal(t, tt.want, cids[0].(*dhcpv6.OptClientId).Cid.LinkLayerAddr)
+		})
+	}
+}
+
+func TestDefaultDest(t *testing.T) {
+	unicast := &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: dhcpv6.DefaultServerPort}
+
+	for _, tt := range []struct {
+		msgType  dhcpv6.MessageType
+		override *net.UDPAddr
+		want     *net.UDPAddr
+	}{
+		{dhcpv6.MessageTypeSolicit, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeRequest, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeConfirm, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeRenew, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeRebind, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeRelease, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeDecline, nil, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeInformationRequest, nil, AllDHCPRelayAgentsAndServers},
+
+		// Messages that may be unicast honor the override.
+		{dhcpv6.MessageTypeRequest, unicast, unicast},
+		{dhcpv6.MessageTypeRenew, unicast, unicast},
+		{dhcpv6.MessageTypeRelease, unicast, unicast},
+		{dhcpv6.MessageTypeDecline, unicast, unicast},
+
+		// Messages that are always multicast ignore a unicast override,
+		// but not a multicast one.
+		{dhcpv6.MessageTypeSolicit, unicast, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeConfirm, unicast, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeRebind, unicast, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeInformationRequest, unicast, AllDHCPRelayAgentsAndServers},
+		{dhcpv6.MessageTypeSolicit, AllDHCPServers, AllDHCPServers},
+		{dhcpv6.MessageTypeRebind, AllDHCPServers, AllDHCPServers},
+	} {
+		t.Run(fmt.Sprintf("%s to %v", tt.msgType, tt.override), func(t *testing.T) {
+			var opts []ClientOpt
+			if tt.override != nil {
+				opts = append(opts, WithDestForType(tt.msgType, tt.override))
+			}
+			mc, _ := serveAndClient(context.Background(), nil, opts...)
+			defer mc.Close()
+
+			require.Equal(t, tt.want, mc.defaultDest(tt.msgType))
+		})
+	}
+}
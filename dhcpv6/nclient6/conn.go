@@ -0,0 +1,133 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"golang.org/x/net/ipv6"
+)
+
+// Transport is what a Client sends and receives messages on. Any
+// net.PacketConn is a Transport, but other transports, such as in-memory ones
+// for testing or ones framing messages over a stream, only need to implement
+// these methods.
+//
+// If a Transport also has a SetReadDeadline(time.Time) error method, as
+// net.PacketConns do, the Client uses it to stop reading promptly once closed,
+// even if Close does not unblock ReadFrom.
+type Transport interface {
+	// ReadFrom reads a message into b, and returns the number of bytes
+	// read and the address it came from. Once the Transport is closed,
+	// ReadFrom must return an error, preferably io.EOF.
+	ReadFrom(b []byte) (n int, addr net.Addr, err error)
+
+	// WriteTo writes the message b to addr.
+	WriteTo(b []byte, addr net.Addr) (n int, err error)
+
+	// Close closes the Transport, unblocking any ReadFrom.
+	Close() error
+}
+
+// NewIPv6UDPConn returns a UDP connection bound to both the link-local address
+// of the given interface and the given port. Multicast messages are sent on
+// that interface too, rather than on the system's default multicast
+// interface.
+//
+// The interface must already have a link-local address configured.
+func NewIPv6UDPConn(iface string, port int) (net.PacketConn, error) {
+	ip, err := dhcpv6.GetLinkLocalAddr(iface)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{
+		IP:   ip,
+		Port: port,
+		Zone: iface,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := setMulticastInterface(conn, iface); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// NewServerConn returns a UDP connection bound to the DHCPv6 server port on all
+// addresses, that has joined the All_DHCP_Relay_Agents_and_Servers and
+// All_DHCP_Servers multicast groups on the given interface. It receives the
+// messages sent by Clients on that interface, which is useful to test relay
+// or server code against a Client.
+func NewServerConn(iface string) (net.PacketConn, error) {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{Port: dhcpv6.DefaultServerPort})
+	if err != nil {
+		if isPermissionError(err) {
+			return nil, fmt.Errorf("%v: binding port %d requires root privileges or the CAP_NET_BIND_SERVICE capability", err, dhcpv6.DefaultServerPort)
+		}
+		return nil, err
+	}
+	p := ipv6.NewPacketConn(conn)
+	for _, group := range []*net.UDPAddr{AllDHCPRelayAgentsAndServers, AllDHCPServers} {
+		if err := p.JoinGroup(ifi, &net.UDPAddr{IP: group.IP}); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot join multicast group %v on %s: %v", group.IP, iface, err)
+		}
+	}
+	return conn, nil
+}
+
+// setTrafficClass sets the Traffic Class of the packets sent on conn, which
+// must be a UDP connection.
+func setTrafficClass(conn net.PacketConn, tc int) error {
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return fmt.Errorf("cannot set traffic class on a %T", conn)
+	}
+	if err := ipv6.NewConn(udpConn).SetTrafficClass(tc); err != nil {
+		return fmt.Errorf("cannot set traffic class %d: %v", tc, err)
+	}
+	return nil
+}
+
+// setMulticastInterface sets the interface multicast packets are sent on by
+// conn, which must be a UDP connection, with the IPV6_MULTICAST_IF socket
+// option.
+func setMulticastInterface(conn net.PacketConn, iface string) error {
+	udpConn, ok := conn.(*net.UDPConn)
+	if !ok {
+		return fmt.Errorf("cannot set multicast interface on a %T", conn)
+	}
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return err
+	}
+	if err := ipv6.NewPacketConn(udpConn).SetMulticastInterface(ifi); err != nil {
+		return fmt.Errorf("cannot set multicast interface %s: %v", iface, err)
+	}
+	return nil
+}
+
+// isPermissionError returns whether err is caused by a lack of privileges.
+func isPermissionError(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.EACCES || err == syscall.EPERM
+}
@@ -0,0 +1,272 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/ipv6"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	bindErr := &net.OpError{Op: "listen", Net: "udp6", Err: os.NewSyscallError("bind", syscall.EACCES)}
+	require.True(t, isPermissionError(bindErr))
+	require.True(t, isPermissionError(syscall.EPERM))
+
+	require.False(t, isPermissionError(&net.OpError{Op: "listen", Net: "udp6", Err: os.NewSyscallError("bind", syscall.EADDRINUSE)}))
+	require.False(t, isPermissionError(errors.New("some error")))
+}
+
+func TestNewServerConnNoInterface(t *testing.T) {
+	_, err := NewServerConn("nonexistent-iface0")
+	require.Error(t, err)
+}
+
+func TestSetTrafficClass(t *testing.T) {
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("IPv6 not available: %v", err)
+	}
+	defer conn.Close()
+
+	require.NoError(t, setTrafficClass(conn, 0xb8))
+	tc, err := ipv6.NewConn(conn).TrafficClass()
+	require.NoError(t, err)
+	require.Equal(t, 0xb8, tc)
+
+	require.Error(t, setTrafficClass(nil, 0xb8))
+}
+
+// loopback returns the loopback interface, or skips the test.
+func loopback(t *testing.T) *net.Interface {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, ifi := range ifaces {
+		if ifi.Flags&net.FlagLoopback != 0 {
+			return &ifi
+		}
+	}
+	t.Skip("no loopback interface")
+	return nil
+}
+
+func TestSetMulticastInterface(t *testing.T) {
+	conn, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skipf("IPv6 not available: %v", err)
+	}
+	defer conn.Close()
+	lo := loopback(t)
+
+	require.NoError(t, setMulticastInterface(conn, lo.Name))
+	ifi, err := ipv6.NewPacketConn(conn).MulticastInterface()
+	require.NoError(t, err)
+	require.Equal(t, lo.Index, ifi.Index)
+
+	require.Error(t, setMulticastInterface(conn, "nonexistent-iface0"))
+	require.Error(t, setMulticastInterface(nil, lo.Name))
+
+	// NewWithConn applies WithInterface.
+	conn2, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	require.NoError(t, err)
+	mc := NewWithConn(conn2, nil, WithInterface(lo.Name))
+	defer mc.Close()
+	ifi, err = ipv6.NewPacketConn(conn2).MulticastInterface()
+	require.NoError(t, err)
+	require.Equal(t, lo.Index, ifi.Index)
+}
+
+func TestWithTrafficClass(t *testing.T) {
+	c := newClient(nil, nil)
+	require.Equal(t, -1, c.trafficClass)
+
+	WithTrafficClass(0xb8)(c)
+	require.Equal(t, 0xb8, c.trafficClass)
+
+	WithTrafficClass(256)(c)
+	WithTrafficClass(-1)(c)
+	require.Equal(t, 0xb8, c.trafficClass)
+}
+
+// memPacket is a packet received by a memTransport.
+type memPacket struct {
+	b    []byte
+	from net.Addr
+}
+
+// memTransport is an in-memory Transport, on which respond is called with
+// each message written to build the responses to receive.
+type memTransport struct {
+	respond   func(m *dhcpv6.Message) []memPacket
+	in        chan memPacket
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newMemTransport(respond func(m *dhcpv6.Message) []memPacket) *memTransport {
+	return &memTransport{
+		respond: respond,
+		in:      make(chan memPacket, 10),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (t *memTransport) ReadFrom(b []byte) (int, net.Addr, error) {
+	select {
+	case <-t.closed:
+		return 0, nil, io.EOF
+	case p := <-t.in:
+		return copy(b, p.b), p.from, nil
+	}
+}
+
+func (t *memTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
+	m, err := dhcpv6.MessageFromBytes(b)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range t.respond(m) {
+		t.in <- p
+	}
+	return len(b), nil
+}
+
+func (t *memTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return nil
+}
+
+// timeoutError is the error returned by a stuckTransport once its read
+// deadline is exceeded.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// stuckTransport is a Transport whose Close does not unblock ReadFrom, which
+// only returns once the read deadline is exceeded.
+type stuckTransport struct {
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (t *stuckTransport) SetReadDeadline(d time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deadline = d
+	return nil
+}
+
+func (t *stuckTransport) ReadFrom(b []byte) (int, net.Addr, error) {
+	t.mu.Lock()
+	d := t.deadline
+	t.mu.Unlock()
+	time.Sleep(time.Until(d))
+	return 0, nil, timeoutError{}
+}
+
+func (t *stuckTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return len(b), nil
+}
+
+func (t *stuckTransport) Close() error {
+	return nil
+}
+
+func TestCloseBlockedRead(t *testing.T) {
+	mc := NewWithTransport(&stuckTransport{}, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf})
+
+	closed := make(chan error)
+	go func() { closed <- mc.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(3 * readPollInterval):
+		t.Fatal("Close did not return with a blocked read")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	from := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: dhcpv6.DefaultServerPort}
+	tr := newMemTransport(func(m *dhcpv6.Message) []memPacket {
+		adv, err := dhcpv6.NewAdvertiseFromSolicit(m)
+		if err != nil {
+			return nil
+		}
+		return []memPacket{{b: adv.ToBytes(), from: from}}
+	})
+	mc := NewWithTransport(tr, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeAdvertise, adv.MessageType)
+	require.NoError(t, mc.Close())
+}
+
+func TestSource(t *testing.T) {
+	from := &net.UDPAddr{IP: net.ParseIP("2001:db8::547"), Port: dhcpv6.DefaultServerPort}
+	tr := newMemTransport(func(m *dhcpv6.Message) []memPacket {
+		var resp *dhcpv6.Message
+		var err error
+		switch m.MessageType {
+		case dhcpv6.MessageTypeSolicit:
+			resp, err = dhcpv6.NewAdvertiseFromSolicit(m, dhcpv6.WithServerID(dhcpv6.Duid{
+				Type:          dhcpv6.DUID_LL,
+				HwType:        iana.HWTypeEthernet,
+				LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6},
+			}))
+			if err == nil {
+				resp.AddOption(m.GetOneOption(dhcpv6.OptionIANA))
+				resp.AddOption(&dhcpv6.OptUnicast{ServerAddr: from.IP})
+			}
+		default:
+			resp, err = dhcpv6.NewReplyFromMessage(m)
+			if err == nil {
+				resp.AddOption(m.GetOneOption(dhcpv6.OptionServerID))
+			}
+		}
+		if err != nil {
+			return nil
+		}
+		return []memPacket{{b: resp.ToBytes(), from: from}}
+	})
+	var events []TraceEvent
+	mc := NewWithTransport(tr, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second),
+		WithTrace(func(e TraceEvent) { events = append(events, e) }))
+	defer mc.Close()
+
+	solicit, err := mc.newSolicit()
+	require.NoError(t, err)
+	txn, err := mc.SendAndReadAsync(context.Background(), AllDHCPRelayAgentsAndServers, solicit, IsMessageType(dhcpv6.MessageTypeAdvertise))
+	require.NoError(t, err)
+	_, err = txn.Result()
+	require.NoError(t, err)
+	require.Equal(t, from, txn.Source())
+
+	adv, err := mc.Solicit(context.Background())
+	require.NoError(t, err)
+	_, err = mc.Request(context.Background(), adv)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, TraceUnicastAccepted, events[0].Type)
+	require.Equal(t, from, events[0].Source)
+	require.Contains(t, events[0].String(), "from [2001:db8::547]:547")
+}
@@ -0,0 +1,55 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"log"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// DADChecker performs Duplicate Address Detection, as required by RFC 8415,
+// Section 18.2.10.1, on the addresses granted to the Client. See NDPChecker
+// for an implementation on Linux.
+type DADChecker interface {
+	// Check returns whether ip is already in use on the link.
+	Check(ctx context.Context, ip net.IP) (inUse bool, err error)
+}
+
+// WithDADChecker configures the Client to check the IA_NA addresses of the
+// Replies to its Requests with d. Addresses found in use are removed from the
+// Reply and declined with a Decline message. Addresses that could not be
+// checked are kept.
+func WithDADChecker(d DADChecker) ClientOpt {
+	return func(c *Client) {
+		c.dadChecker = d
+	}
+}
+
+// WithDADRerequests configures the Client to send up to n new Requests to
+// obtain other addresses when the DAD checker configured with WithDADChecker
+// found some in use.
+func WithDADRerequests(n int) ClientOpt {
+	return func(c *Client) {
+		c.dadRerequests = n
+	}
+}
+
+// checkAddresses runs the DAD checker on the IA_NA addresses of reply, and
+// declines those in use. It returns whether any address was declined.
+func (c *Client) checkAddresses(ctx context.Context, reply *dhcpv6.Message) bool {
+	return c.declineAddresses(ctx, reply, func(ip net.IP) bool {
+		inUse, err := c.dadChecker.Check(ctx, ip)
+		if err != nil {
+			log.Printf("error checking whether %s is in use: %v", ip, err)
+			return true
+		}
+		return !inUse
+	})
+}
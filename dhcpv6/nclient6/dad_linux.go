@@ -0,0 +1,154 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+// NDPChecker is a DADChecker sending Neighbor Solicitations for the checked
+// address on an interface, as described by RFC 4862, Section 5.4, and
+// reporting the address in use if a Neighbor Advertisement for it is received.
+//
+// The solicitations are sent from an address of the interface rather than
+// from the unspecified address, which cannot be selected with a raw socket.
+// Opening the raw ICMPv6 socket requires the CAP_NET_RAW capability.
+type NDPChecker struct {
+	// Interface is the name of the interface to probe on.
+	Interface string
+
+	// Transmits is the number of Neighbor Solicitations sent. It defaults
+	// to 1, the default DupAddrDetectTransmits.
+	Transmits int
+
+	// RetransTimer is how long to wait for an advertisement after each
+	// solicitation. It defaults to 1s, the default RetransTimer of RFC
+	// 4861.
+	RetransTimer time.Duration
+}
+
+// Check implements DADChecker.
+func (n *NDPChecker) Check(ctx context.Context, ip net.IP) (bool, error) {
+	target := ip.To16()
+	if target == nil || ip.To4() != nil {
+		return false, fmt.Errorf("not an IPv6 address: %v", ip)
+	}
+	iface, err := net.InterfaceByName(n.Interface)
+	if err != nil {
+		return false, err
+	}
+	transmits, retrans := n.Transmits, n.RetransTimer
+	if transmits <= 0 {
+		transmits = 1
+	}
+	if retrans <= 0 {
+		retrans = time.Second
+	}
+
+	conn, err := icmp.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	pc := conn.IPv6PacketConn()
+	// RFC 4861, Section 7.1.1: Neighbor Discovery messages must have a hop
+	// limit of 255.
+	if err := pc.SetMulticastHopLimit(255); err != nil {
+		return false, err
+	}
+	if err := pc.SetMulticastInterface(iface); err != nil {
+		return false, err
+	}
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeNeighborAdvertisement)
+	if err := pc.SetICMPFilter(&filter); err != nil {
+		return false, err
+	}
+
+	ns := icmp.Message{
+		Type: ipv6.ICMPTypeNeighborSolicitation,
+		Body: &icmp.RawBody{Data: neighborSolicitation(target, iface.HardwareAddr)},
+	}
+	// The checksum is computed by the kernel for ICMPv6 sockets.
+	b, err := ns.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+	dst := &net.IPAddr{IP: solicitedNodeAddr(target), Zone: iface.Name}
+
+	buf := make([]byte, 1500)
+	for i := 0; i < transmits; i++ {
+		if _, err := conn.WriteTo(b, dst); err != nil {
+			return false, err
+		}
+		deadline := time.Now().Add(retrans)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return false, err
+		}
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if isTimeout(err) {
+					break
+				}
+				return false, err
+			}
+			if isNeighborAdvertisementFor(buf[:n], target) {
+				return true, nil
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// neighborSolicitation returns the body of a Neighbor Solicitation for target,
+// with a Source Link-Layer Address option for hwaddr if set, as defined by RFC
+// 4861, Section 4.3.
+func neighborSolicitation(target net.IP, hwaddr net.HardwareAddr) []byte {
+	body := make([]byte, 4, 4+net.IPv6len+2+len(hwaddr))
+	body = append(body, target...)
+	if len(hwaddr) == 6 {
+		body = append(body, 1, 1)
+		body = append(body, hwaddr...)
+	}
+	return body
+}
+
+// solicitedNodeAddr returns the solicited-node multicast address of ip, as
+// defined by RFC 4291, Section 2.7.1.
+func solicitedNodeAddr(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip[13:])
+	return addr
+}
+
+// isNeighborAdvertisementFor returns whether b is a Neighbor Advertisement for
+// target.
+func isNeighborAdvertisementFor(b []byte, target net.IP) bool {
+	m, err := icmp.ParseMessage(ipv6.ICMPTypeNeighborAdvertisement.Protocol(), b)
+	if err != nil || m.Type != ipv6.ICMPTypeNeighborAdvertisement {
+		return false
+	}
+	body, ok := m.Body.(*icmp.RawBody)
+	if !ok || len(body.Data) < 4+net.IPv6len {
+		return false
+	}
+	return net.IP(body.Data[4 : 4+net.IPv6len]).Equal(target)
+}
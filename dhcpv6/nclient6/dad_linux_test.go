@@ -0,0 +1,42 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv6"
+)
+
+func TestSolicitedNodeAddr(t *testing.T) {
+	got := solicitedNodeAddr(net.ParseIP("2001:db8::1:2345:6789"))
+	require.Equal(t, net.ParseIP("ff02::1:ff45:6789"), got)
+}
+
+func TestIsNeighborAdvertisementFor(t *testing.T) {
+	target := net.ParseIP("2001:db8::1")
+	marshal := func(typ ipv6.ICMPType, target net.IP) []byte {
+		m := icmp.Message{
+			Type: typ,
+			Body: &icmp.RawBody{Data: append([]byte{0x60, 0, 0, 0}, target...)},
+		}
+		b, err := m.Marshal(nil)
+		require.NoError(t, err)
+		return b
+	}
+	require.True(t, isNeighborAdvertisementFor(marshal(ipv6.ICMPTypeNeighborAdvertisement, target), target))
+	require.False(t, isNeighborAdvertisementFor(marshal(ipv6.ICMPTypeNeighborAdvertisement, net.ParseIP("2001:db8::2")), target))
+	require.False(t, isNeighborAdvertisementFor(marshal(ipv6.ICMPTypeNeighborSolicitation, target), target))
+	require.False(t, isNeighborAdvertisementFor([]byte{136, 0}, target))
+
+	ns := neighborSolicitation(target.To16(), net.HardwareAddr{0, 1, 2, 3, 4, 5})
+	require.Len(t, ns, 4+16+8)
+	require.Equal(t, []byte{1, 1, 0, 1, 2, 3, 4, 5}, ns[20:])
+}
@@ -0,0 +1,113 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDAD reports the addresses of inUse in use, and fails to check those of
+// broken.
+type fakeDAD struct {
+	mu      sync.Mutex
+	inUse   []net.IP
+	broken  []net.IP
+	checked []net.IP
+}
+
+func (f *fakeDAD) Check(ctx context.Context, ip net.IP) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checked = append(f.checked, ip)
+	for _, b := range f.broken {
+		if b.Equal(ip) {
+			return false, errors.New("no link")
+		}
+	}
+	for _, u := range f.inUse {
+		if u.Equal(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// dadClient returns a Client talking to fakeServer on serverConn, and a
+// function counting the messages of each type the server received.
+func dadClient(t *testing.T, opts ...ClientOpt) (mc *Client, serverConn net.PacketConn, count func(dhcpv6.MessageType) int) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+
+	var (
+		mu     sync.Mutex
+		counts = make(map[dhcpv6.MessageType]int)
+	)
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		mu.Lock()
+		counts[m.MessageType]++
+		mu.Unlock()
+		fakeServer(conn, peer, m)
+	})
+	count = func(t dhcpv6.MessageType) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[t]
+	}
+	o := append([]ClientOpt{WithRetry(1), WithTimeout(2 * time.Second)}, opts...)
+	mc = NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, o...)
+	return mc, serverConn, count
+}
+
+func TestDADChecker(t *testing.T) {
+	dad := &fakeDAD{inUse: []net.IP{net.ParseIP("2001:db8::1")}}
+	mc, serverConn, count := dadClient(t, WithDADChecker(dad))
+	defer serverConn.Close()
+	defer mc.Close()
+
+	lease, err := mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true})
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::2")}, lease.Addresses())
+	require.Len(t, dad.checked, 2)
+	require.Equal(t, 1, count(dhcpv6.MessageTypeRequest))
+	require.Equal(t, 1, count(dhcpv6.MessageTypeDecline))
+}
+
+func TestDADCheckerError(t *testing.T) {
+	dad := &fakeDAD{broken: []net.IP{net.ParseIP("2001:db8::1")}}
+	mc, serverConn, count := dadClient(t, WithDADChecker(dad), WithDADRerequests(3))
+	defer serverConn.Close()
+	defer mc.Close()
+
+	lease, err := mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true})
+	require.NoError(t, err)
+	// Addresses that could not be checked are kept.
+	require.Len(t, lease.Addresses(), 2)
+	require.Equal(t, 0, count(dhcpv6.MessageTypeDecline))
+}
+
+func TestDADRerequests(t *testing.T) {
+	// The fake server grants the same addresses again, so that each
+	// Request leads to a Decline.
+	dad := &fakeDAD{inUse: []net.IP{net.ParseIP("2001:db8::1")}}
+	mc, serverConn, count := dadClient(t, WithDADChecker(dad), WithDADRerequests(2))
+	defer serverConn.Close()
+	defer mc.Close()
+
+	lease, err := mc.SolicitFull(context.Background(), SolicitConfig{WantAddress: true})
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::2")}, lease.Addresses())
+	require.Equal(t, 3, count(dhcpv6.MessageTypeRequest))
+	require.Equal(t, 3, count(dhcpv6.MessageTypeDecline))
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// deprecatedOptions maps deprecated options to their replacements.
+var deprecatedOptions = map[dhcpv6.OptionCode]dhcpv6.OptionCode{
+	// RFC 5908, Section 1: the NTP Server option supersedes the SNTP
+	// Servers option of RFC 4075.
+	dhcpv6.OptionSNTPServerList: dhcpv6.OptionNTPServer,
+}
+
+// WithDeprecationWarnings configures a function receiving a warning when the
+// Client sends a message carrying or requesting a deprecated option, which
+// suggests the option replacing it. Each option is reported once per Client.
+//
+// Without it, messages are not checked for deprecated options.
+func WithDeprecationWarnings(f func(msg string)) ClientOpt {
+	return func(c *Client) {
+		c.deprecationWarn = f
+		c.deprecationWarned = make(map[dhcpv6.OptionCode]bool)
+	}
+}
+
+// warnDeprecated reports the deprecated options msg carries or requests in its
+// Option Request option.
+func (c *Client) warnDeprecated(msg *dhcpv6.Message) {
+	for _, opt := range msg.Options {
+		c.warnDeprecatedCode(msg.MessageType, opt.Code(), "carries")
+		if oro, ok := opt.(*dhcpv6.OptRequestedOption); ok {
+			for _, code := range oro.RequestedOptions() {
+				c.warnDeprecatedCode(msg.MessageType, code, "requests")
+			}
+		}
+	}
+}
+
+func (c *Client) warnDeprecatedCode(t dhcpv6.MessageType, code dhcpv6.OptionCode, verb string) {
+	replacement, ok := deprecatedOptions[code]
+	if !ok {
+		return
+	}
+	c.deprecationMu.Lock()
+	warned := c.deprecationWarned[code]
+	c.deprecationWarned[code] = true
+	c.deprecationMu.Unlock()
+	if !warned {
+		c.deprecationWarn(fmt.Sprintf("%s message %s deprecated option %s, use %s instead", t, verb, code, replacement))
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecationWarnings(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	var warnings []string
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithDeprecationWarnings(func(msg string) { warnings = append(warnings, msg) }))
+	defer mc.Close()
+
+	msg := newPacket(dhcpv6.MessageTypeInformationRequest, [3]byte{1, 2, 3})
+	require.NoError(t, mc.Send(AllDHCPRelayAgentsAndServers, msg))
+	require.Empty(t, warnings)
+
+	oro := &dhcpv6.OptRequestedOption{}
+	oro.SetRequestedOptions([]dhcpv6.OptionCode{dhcpv6.OptionDNSRecursiveNameServer, dhcpv6.OptionSNTPServerList})
+	msg.UpdateOption(oro)
+	require.NoError(t, mc.Send(AllDHCPRelayAgentsAndServers, msg))
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "requests deprecated option OPTION_SNTP_SERVERS, use OPTION_NTP_SERVER instead")
+
+	// Each option is only reported once.
+	msg.AddOption(&dhcpv6.OptionGeneric{OptionCode: dhcpv6.OptionSNTPServerList, OptionData: net.ParseIP("2001:db8::123")})
+	require.NoError(t, mc.Send(AllDHCPRelayAgentsAndServers, msg))
+	require.Len(t, warnings, 1)
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+)
+
+// maxXIDAttempts is the number of transaction IDs SolicitAndInfo generates at
+// most to find one distinct from that of its Solicit.
+const maxXIDAttempts = 16
+
+// Config is the configuration a client obtained with an Information-request,
+// as extracted from the server's Reply.
+type Config struct {
+	// ServerID is the DUID of the server that replied, left zero if the
+	// Reply has no Server ID.
+	ServerID dhcpv6.Duid
+
+	// DNS, DomainSearch and NTPServers are the configuration parameters
+	// found in the Reply, if any.
+	DNS          []net.IP
+	DomainSearch []string
+	NTPServers   []net.IP
+
+	// Reply is the message the configuration was extracted from.
+	Reply *dhcpv6.Message
+}
+
+// newConfig extracts a Config from the Reply to an Information-request. The
+// Reply may lack a Server ID, as accepted by default by isReplyTo.
+func (c *Client) newConfig(reply *dhcpv6.Message) (*Config, error) {
+	if sc, ok := reply.GetOneOption(dhcpv6.OptionStatusCode).(*dhcpv6.OptStatusCode); ok && sc.StatusCode != iana.StatusSuccess {
+		return nil, fmt.Errorf("REPLY has status %s: %s", sc.StatusCode, sc.StatusMessage)
+	}
+	config := &Config{Reply: reply}
+	for _, opt := range reply.Options {
+		switch o := opt.(type) {
+		case *dhcpv6.OptServerId:
+			config.ServerID = o.Sid
+		case *dhcpv6.OptDNSRecursiveNameServer:
+			config.DNS = append(config.DNS, o.NameServers...)
+		case *dhcpv6.OptDomainSearchList:
+			if o.DomainSearchList != nil {
+				config.DomainSearch = append(config.DomainSearch, o.DomainSearchList.Labels...)
+			}
+		case *dhcpv6.OptNTPServer:
+			config.NTPServers = append(config.NTPServers, o.ServerAddrs()...)
+		}
+	}
+	return config, nil
+}
+
+// SolicitAndInfoError is returned by SolicitAndInfo when either of its
+// exchanges failed.
+type SolicitAndInfoError struct {
+	// LeaseErr is the error of the Solicit-Request exchange, if it
+	// failed.
+	LeaseErr error
+	// InfoErr is the error of the Information-request exchange, if it
+	// failed.
+	InfoErr error
+}
+
+func (e *SolicitAndInfoError) Error() string {
+	var errs []string
+	if e.LeaseErr != nil {
+		errs = append(errs, "lease: "+e.LeaseErr.Error())
+	}
+	if e.InfoErr != nil {
+		errs = append(errs, "information request: "+e.InfoErr.Error())
+	}
+	return strings.Join(errs, "; ")
+}
+
+// SolicitAndInfo obtains a lease with the 4-way Solicit-Advertise-Request-Reply
+// handshake and, concurrently, other configuration parameters with an
+// Information-request, as some operating systems do to cut the time spent
+// acquiring both. modifiers are applied to the Solicit.
+//
+// The two exchanges use distinct Transaction IDs. The DNS servers, domain
+// search list and NTP servers of the Config fill those the lease lacks.
+//
+// If either exchange fails, a *SolicitAndInfoError reports which, and the
+// result of the other is still returned.
+func (c *Client) SolicitAndInfo(ctx context.Context, modifiers ...dhcpv6.Modifier) (*dhcpv6.Lease, *Config, error) {
+	solicit, err := c.newSolicit(modifiers...)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := c.newInformationRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; info.TransactionID == solicit.TransactionID; i++ {
+		if i == maxXIDAttempts {
+			return nil, nil, errors.New("could not generate distinct transaction IDs")
+		}
+		if info.TransactionID, err = c.generateXID(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		lease  *dhcpv6.Lease
+		config *Config
+		errs   SolicitAndInfoError
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		advertise, err := c.sendSolicit(ctx, solicit)
+		if err != nil {
+			errs.LeaseErr = err
+			return
+		}
+		lease, errs.LeaseErr = c.RequestLease(ctx, advertise)
+	}()
+	go func() {
+		defer wg.Done()
+		reply, err := c.SendAndRead(ctx, c.defaultDest(info.MessageType), info, c.isReplyTo(info.MessageType))
+		if err != nil {
+			errs.InfoErr = err
+			return
+		}
+		config, errs.InfoErr = c.newConfig(reply)
+	}()
+	wg.Wait()
+
+	if lease != nil && config != nil {
+		if len(lease.DNS) == 0 {
+			lease.DNS = config.DNS
+		}
+		if len(lease.DomainSearch) == 0 {
+			lease.DomainSearch = config.DomainSearch
+		}
+		if len(lease.NTPServers) == 0 {
+			lease.NTPServers = config.NTPServers
+		}
+	}
+	if errs.LeaseErr != nil || errs.InfoErr != nil {
+		return lease, config, &errs
+	}
+	return lease, config, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+// infoServer is fakeServer, answering Information-requests too unless
+// ignoreInfo is set. The messages received are sent on received.
+func infoServer(received chan<- *dhcpv6.Message, ignoreInfo bool) func(net.PacketConn, net.Addr, *dhcpv6.Message) {
+	return func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		received <- m
+		if m.MessageType != dhcpv6.MessageTypeInformationRequest {
+			fakeServer(conn, peer, m)
+			return
+		}
+		if ignoreInfo {
+			return
+		}
+		reply, err := dhcpv6.NewReplyFromMessage(m,
+			dhcpv6.WithServerID(dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}),
+			dhcpv6.WithDNS(net.ParseIP("2001:db8::54")))
+		if err != nil {
+			return
+		}
+		reply.AddOption(&dhcpv6.OptNTPServer{Suboptions: dhcpv6.Options{
+			&dhcpv6.OptionGeneric{
+				OptionCode: dhcpv6.NTPSuboptionSrvAddr,
+				OptionData: net.ParseIP("2001:db8::124"),
+			},
+		}})
+		conn.WriteTo(reply.ToBytes(), peer)
+	}
+}
+
+func TestSolicitAndInfo(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	received := make(chan *dhcpv6.Message, 8)
+	go serve(serverConn, infoServer(received, false))
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	lease, config, err := mc.SolicitAndInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, lease.Addresses(), 2)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::54")}, config.DNS)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::124")}, config.NTPServers)
+	// The lease has DNS servers of its own, but no NTP servers.
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::53")}, lease.DNS)
+	require.Equal(t, config.NTPServers, lease.NTPServers)
+
+	xids := make(map[dhcpv6.MessageType]dhcpv6.TransactionID)
+	for len(xids) < 3 {
+		m := <-received
+		xids[m.MessageType] = m.TransactionID
+	}
+	require.NotEqual(t, xids[dhcpv6.MessageTypeSolicit], xids[dhcpv6.MessageTypeInformationRequest])
+}
+
+func TestSolicitAndInfoNoServerID(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType != dhcpv6.MessageTypeInformationRequest {
+			fakeServer(conn, peer, m)
+			return
+		}
+		// Some servers omit the Server ID in stateless exchanges.
+		reply, err := dhcpv6.NewReplyFromMessage(m, dhcpv6.WithDNS(net.ParseIP("2001:db8::54")))
+		if err != nil {
+			return
+		}
+		conn.WriteTo(reply.ToBytes(), peer)
+	})
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(2*time.Second))
+	defer mc.Close()
+
+	lease, config, err := mc.SolicitAndInfo(context.Background())
+	require.NoError(t, err)
+	require.Len(t, lease.Addresses(), 2)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::54")}, config.DNS)
+	require.Equal(t, dhcpv6.Duid{}, config.ServerID)
+}
+
+func TestSolicitAndInfoPartialFailure(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	received := make(chan *dhcpv6.Message, 8)
+	go serve(serverConn, infoServer(received, true))
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(200*time.Millisecond))
+	defer mc.Close()
+
+	lease, config, err := mc.SolicitAndInfo(context.Background())
+	require.Error(t, err)
+	saiErr, ok := err.(*SolicitAndInfoError)
+	require.True(t, ok)
+	require.NoError(t, saiErr.LeaseErr)
+	require.Equal(t, ErrNoResponse, saiErr.InfoErr)
+	require.NotNil(t, lease)
+	require.Len(t, lease.Addresses(), 2)
+	require.Nil(t, config)
+}
+
+func TestSolicitAndInfoDistinctXIDs(t *testing.T) {
+	mc, serverConn := serveAndClient(context.Background(), nil,
+		WithXIDGenerator(func() dhcpv6.TransactionID { return dhcpv6.TransactionID{1, 2, 3} }))
+	defer mc.Close()
+	defer serverConn.Close()
+
+	_, _, err := mc.SolicitAndInfo(context.Background())
+	require.Error(t, err)
+	_, ok := err.(*SolicitAndInfoError)
+	require.False(t, ok)
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// Leasequery sends query, a LEASEQUERY message such as built by
+// dhcpv6.NewLeasequeryByAddress or dhcpv6.NewLeasequeryByClientID, and returns
+// the LEASEQUERY-REPLY. The Client's DUID is added as the requestor's Client ID
+// if query has none.
+//
+// query is sent to the address configured with WithDestForType for
+// MessageTypeLeaseQuery, or to All_DHCP_Servers by default.
+func (c *Client) Leasequery(ctx context.Context, query *dhcpv6.Message) (*dhcpv6.Message, error) {
+	if query == nil {
+		return nil, errors.New("query cannot be nil")
+	}
+	if query.MessageType != dhcpv6.MessageTypeLeaseQuery {
+		return nil, fmt.Errorf("cannot send a %s message as a leasequery", query.MessageType)
+	}
+	lq := query.LQQuery()
+	if lq == nil {
+		return nil, errors.New("leasequery has no LQ Query option")
+	}
+	if err := lq.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid LQ Query: %v", err)
+	}
+	if query.GetOneOption(dhcpv6.OptionClientID) == nil {
+		query.AddOption(&dhcpv6.OptClientId{Cid: c.duid()})
+	}
+	return c.SendAndRead(ctx, c.serverDest(query.MessageType), query, And(
+		IsMessageType(dhcpv6.MessageTypeLeaseQueryReply),
+		func(p *dhcpv6.Message) bool {
+			return p.GetOneOption(dhcpv6.OptionServerID) != nil
+		},
+	))
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+// serveLeasequery answers LEASEQUERY messages with a LEASEQUERY-REPLY carrying
+// the binding of 2001:db8::1, after sending the queries on queries.
+func serveLeasequery(queries chan<- *dhcpv6.Message) func(net.PacketConn, net.Addr, *dhcpv6.Message) {
+	client := dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	return func(conn net.PacketConn, peer net.Addr, m *dhcpv6.Message) {
+		if m.MessageType != dhcpv6.MessageTypeLeaseQuery {
+			return
+		}
+		queries <- m
+		reply := newPacket(dhcpv6.MessageTypeLeaseQueryReply, m.TransactionID)
+		reply.AddOption(m.GetOneOption(dhcpv6.OptionClientID))
+		reply.AddOption(&dhcpv6.OptServerId{Sid: dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}})
+		reply.AddOption(&dhcpv6.OptClientData{Options: dhcpv6.Options{
+			&dhcpv6.OptClientId{Cid: client},
+			&dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 3600, ValidLifetime: 7200},
+		}})
+		conn.WriteTo(reply.ToBytes(), peer)
+	}
+}
+
+func TestLeasequery(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	queries := make(chan *dhcpv6.Message, 2)
+	go serve(serverConn, serveLeasequery(queries))
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, WithRetry(1))
+	defer mc.Close()
+
+	byAddr, err := dhcpv6.NewLeasequeryByAddress(net.ParseIP("2001:db8::1"))
+	require.NoError(t, err)
+	reply, err := mc.Leasequery(context.Background(), byAddr)
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeLeaseQueryReply, reply.MessageType)
+	require.Len(t, reply.ClientData(), 1)
+	q := <-queries
+	require.Equal(t, dhcpv6.LQQueryByAddress, q.LQQuery().QueryType)
+	// The Client's DUID is the requestor's Client ID.
+	require.Equal(t, mc.duid(), q.GetOneOption(dhcpv6.OptionClientID).(*dhcpv6.OptClientId).Cid)
+
+	client := dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	byCID, err := dhcpv6.NewLeasequeryByClientID(client)
+	require.NoError(t, err)
+	reply, err = mc.Leasequery(context.Background(), byCID)
+	require.NoError(t, err)
+	cid, ok := reply.ClientData()[0].ClientID()
+	require.True(t, ok)
+	require.Equal(t, client, cid)
+	q = <-queries
+	require.Equal(t, dhcpv6.LQQueryByClientID, q.LQQuery().QueryType)
+}
+
+func TestLeasequeryInvalid(t *testing.T) {
+	mc, serverConn := serveAndClient(context.Background(), nil)
+	defer mc.Close()
+	defer serverConn.Close()
+
+	_, err := mc.Leasequery(context.Background(), nil)
+	require.Error(t, err)
+
+	// Not a LEASEQUERY.
+	_, err = mc.Leasequery(context.Background(), newPacket(dhcpv6.MessageTypeSolicit, [3]byte{1, 2, 3}))
+	require.Error(t, err)
+
+	// No LQ Query option.
+	_, err = mc.Leasequery(context.Background(), newPacket(dhcpv6.MessageTypeLeaseQuery, [3]byte{1, 2, 3}))
+	require.Error(t, err)
+
+	// QUERY_BY_ADDRESS without an address.
+	m := newPacket(dhcpv6.MessageTypeLeaseQuery, [3]byte{1, 2, 3})
+	m.AddOption(&dhcpv6.OptLQQuery{QueryType: dhcpv6.LQQueryByAddress})
+	_, err = mc.Leasequery(context.Background(), m)
+	require.Error(t, err)
+}
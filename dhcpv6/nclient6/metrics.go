@@ -0,0 +1,57 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// Metrics receives measurements of the Client's exchanges, e.g. to export them
+// to a monitoring system. See the prommetrics package for an implementation
+// exporting them to Prometheus.
+//
+// The methods may be called concurrently, and must not block.
+type Metrics interface {
+	// MessageSent is called for each message of type t written,
+	// including retransmissions.
+	MessageSent(t dhcpv6.MessageType)
+
+	// Retransmission is called for each retransmission of a message of
+	// type t.
+	Retransmission(t dhcpv6.MessageType)
+
+	// ResponseReceived is called when a response to a message of type t
+	// is received, latency after the message was first sent.
+	ResponseReceived(t dhcpv6.MessageType, latency time.Duration)
+
+	// Timeout is called when no response to a message of type t was
+	// received after all retransmissions.
+	Timeout(t dhcpv6.MessageType)
+}
+
+// WithMetrics configures the Metrics the Client reports its exchanges to.
+func WithMetrics(m Metrics) ClientOpt {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// observeExchange reports the outcome of an exchange started at start by a
+// message of type t to the Client's Metrics, if any.
+func (c *Client) observeExchange(t dhcpv6.MessageType, start time.Time, err error) {
+	if c.metrics == nil {
+		return
+	}
+	switch err {
+	case nil:
+		c.metrics.ResponseReceived(t, c.clock.Now().Sub(start))
+	case ErrNoResponse:
+		c.metrics.Timeout(t)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// MultiClient is a set of Clients, one per interface, used to perform
+// operations on whichever interface answers first.
+type MultiClient struct {
+	clients map[string]*Client
+}
+
+// MultiClientConfig configures how NewMultiClientWithConfig sets up the
+// Clients of a MultiClient.
+type MultiClientConfig struct {
+	// DUIDPerInterface gives each Client its own DUID, derived from the
+	// hardware address of its interface. By default, all Clients share a
+	// single device-wide DUID, derived from the first interface, as RFC
+	// 8415, Section 11 recommends: a DUID identifies a device, not an
+	// interface, and servers use it to relate the bindings of all of its
+	// interfaces.
+	DUIDPerInterface bool
+}
+
+// NewMultiClient returns a MultiClient with a Client bound to each of the
+// given interfaces. All Clients are configured with opts and share a single
+// DUID.
+func NewMultiClient(ifaceNames []string, opts ...ClientOpt) (*MultiClient, error) {
+	return NewMultiClientWithConfig(ifaceNames, MultiClientConfig{}, opts...)
+}
+
+// NewMultiClientWithConfig returns a MultiClient with a Client bound to each
+// of the given interfaces, set up according to cfg. All Clients are
+// configured with opts.
+func NewMultiClientWithConfig(ifaceNames []string, cfg MultiClientConfig, opts ...ClientOpt) (*MultiClient, error) {
+	return newMultiClient(ifaceNames, cfg, func(name string, opts ...ClientOpt) (*Client, error) {
+		iface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		return New(name, iface.HardwareAddr, opts...)
+	}, opts...)
+}
+
+// newMultiClient returns a MultiClient with the Clients returned by open for
+// each of the given interfaces.
+func newMultiClient(ifaceNames []string, cfg MultiClientConfig, open func(name string, opts ...ClientOpt) (*Client, error), opts ...ClientOpt) (*MultiClient, error) {
+	m := &MultiClient{clients: make(map[string]*Client)}
+	for _, name := range ifaceNames {
+		c, err := open(name, opts...)
+		if err != nil {
+			m.Close()
+			return nil, fmt.Errorf("interface %s: %v", name, err)
+		}
+		m.clients[name] = c
+		if !cfg.DUIDPerInterface && len(m.clients) == 1 {
+			// The other interfaces use the DUID of the first one.
+			opts = append(opts[:len(opts):len(opts)], WithClientDUID(c.duid()))
+		}
+	}
+	return m, nil
+}
+
+// NewMultiClientFromClients returns a MultiClient made of already configured
+// Clients, indexed by interface name.
+func NewMultiClientFromClients(clients map[string]*Client) *MultiClient {
+	m := &MultiClient{clients: make(map[string]*Client, len(clients))}
+	for name, c := range clients {
+		m.clients[name] = c
+	}
+	return m
+}
+
+// Client returns the Client of the given interface, or nil.
+func (m *MultiClient) Client(iface string) *Client {
+	return m.clients[iface]
+}
+
+// Interfaces returns the sorted names of the MultiClient's interfaces.
+func (m *MultiClient) Interfaces() []string {
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close closes all Clients.
+func (m *MultiClient) Close() error {
+	var err error
+	for _, c := range m.clients {
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// SolicitAny sends a Solicit on all interfaces concurrently and returns the
+// first Advertise received, along with the name of the interface it was
+// received on. The Solicits pending on the other interfaces are then
+// cancelled.
+func (m *MultiClient) SolicitAny(ctx context.Context, modifiers ...dhcpv6.Modifier) (*dhcpv6.Message, string, error) {
+	if len(m.clients) == 0 {
+		return nil, "", errors.New("MultiClient has no interfaces")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		iface string
+		adv   *dhcpv6.Message
+		err   error
+	}
+	results := make(chan result, len(m.clients))
+	for name, c := range m.clients {
+		go func(name string, c *Client) {
+			adv, err := c.Solicit(ctx, modifiers...)
+			results <- result{iface: name, adv: adv, err: err}
+		}(name, c)
+	}
+
+	errs := make(map[string]error)
+	for range m.clients {
+		r := <-results
+		if r.err == nil {
+			return r.adv, r.iface, nil
+		}
+		errs[r.iface] = r.err
+	}
+
+	var msgs []string
+	for _, name := range m.Interfaces() {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, errs[name]))
+	}
+	return nil, "", fmt.Errorf("no Advertise received on any interface: %s", strings.Join(msgs, "; "))
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+func numPending(c *Client) int {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	return len(c.pending)
+}
+
+func TestSolicitAny(t *testing.T) {
+	// eth0 never gets an answer.
+	silent, _ := serveAndClient(context.Background(), nil, WithTimeout(time.Minute))
+
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	go serve(serverConn, fakeServer)
+	answered := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, WithRetry(1))
+
+	m := NewMultiClientFromClients(map[string]*Client{"eth0": silent, "eth1": answered})
+	defer m.Close()
+	require.Equal(t, []string{"eth0", "eth1"}, m.Interfaces())
+	require.Equal(t, silent, m.Client("eth0"))
+
+	start := time.Now()
+	adv, iface, err := m.SolicitAny(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "eth1", iface)
+	require.Equal(t, dhcpv6.MessageTypeAdvertise, adv.MessageType)
+	require.True(t, time.Since(start) < 10*time.Second)
+
+	// The Solicit on eth0 is cancelled.
+	require.Eventually(t, func() bool { return numPending(silent) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSolicitAnyNoAnswer(t *testing.T) {
+	eth0, _ := serveAndClient(context.Background(), nil, WithTimeout(10*time.Millisecond))
+	eth1, _ := serveAndClient(context.Background(), nil, WithTimeout(10*time.Millisecond))
+	m := NewMultiClientFromClients(map[string]*Client{"eth0": eth0, "eth1": eth1})
+	defer m.Close()
+
+	_, _, err := m.SolicitAny(context.Background())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "eth0: ")
+	require.Contains(t, err.Error(), "eth1: ")
+
+	_, _, err = NewMultiClientFromClients(nil).SolicitAny(context.Background())
+	require.Error(t, err)
+}
+
+func TestNewMultiClientDUID(t *testing.T) {
+	hwAddrs := map[string]net.HardwareAddr{
+		"eth0": {0xa, 0xb, 0xc, 0xd, 0xe, 0x0},
+		"eth1": {0xa, 0xb, 0xc, 0xd, 0xe, 0x1},
+		"eth2": {0xa, 0xb, 0xc, 0xd, 0xe, 0x2},
+	}
+	names := []string{"eth0", "eth1", "eth2"}
+
+	for _, tt := range []struct {
+		name         string
+		perInterface bool
+	}{
+		{name: "shared"},
+		{name: "per-interface", perInterface: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			sent := make(map[string]dhcpv6.Duid)
+			open := func(name string, opts ...ClientOpt) (*Client, error) {
+				opts = append(opts, WithOutgoingHook(func(m *dhcpv6.Message) {
+					mu.Lock()
+					defer mu.Unlock()
+					sent[name] = m.GetOneOption(dhcpv6.OptionClientID).(*dhcpv6.OptClientId).Cid
+				}))
+				clientConn, serverConn, err := packetSocketPair()
+				if err != nil {
+					return nil, err
+				}
+				go serve(serverConn, fakeServer)
+				return NewWithConn(clientConn, hwAddrs[name], opts...), nil
+			}
+
+			m, err := newMultiClient(names, MultiClientConfig{DUIDPerInterface: tt.perInterface}, open, WithRetry(1))
+			require.NoError(t, err)
+			defer m.Close()
+			for _, name := range names {
+				_, err := m.Client(name).Solicit(context.Background())
+				require.NoError(t, err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, sent, len(names))
+			for _, name := range names {
+				if tt.perInterface {
+					require.Equal(t, hwAddrs[name], sent[name].LinkLayerAddr, name)
+				} else {
+					require.True(t, sent[name].Equal(sent["eth0"]), name)
+					require.Equal(t, hwAddrs["eth0"], sent[name].LinkLayerAddr, name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+// Package prommetrics exports the metrics of nclient6 Clients to Prometheus.
+//
+// It is kept apart from nclient6 so that the client does not depend on the
+// Prometheus libraries:
+//
+//	m, err := prommetrics.New(registry)
+//	if err != nil {
+//		return err
+//	}
+//	client, err := nclient6.New(iface, hwaddr, nclient6.WithMetrics(m))
+package prommetrics
+
+import (
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/dhcpv6/nclient6"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "dhcpv6_client"
+
+// promMetrics implements nclient6.Metrics with Prometheus metrics, labelled
+// by message type.
+type promMetrics struct {
+	sent            *prometheus.CounterVec
+	retransmissions *prometheus.CounterVec
+	latency         *prometheus.HistogramVec
+	timeouts        *prometheus.CounterVec
+}
+
+// New returns nclient6.Metrics exporting the following metrics, registered
+// with reg:
+//
+//   - dhcpv6_client_messages_sent_total, the messages sent, including
+//     retransmissions;
+//   - dhcpv6_client_retransmissions_total, the retransmissions;
+//   - dhcpv6_client_response_latency_seconds, the time between the first
+//     transmission of a message and its response;
+//   - dhcpv6_client_timeouts_total, the messages left without a response.
+//
+// All of them have a "type" label holding the type of the message sent, such
+// as "SOLICIT".
+//
+// A Registerer, such as a *prometheus.Registry, only accepts one set of these
+// metrics: Clients sharing a Registerer must share the returned Metrics.
+func New(reg prometheus.Registerer) (nclient6.Metrics, error) {
+	m := &promMetrics{
+		sent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_sent_total",
+			Help:      "Messages sent, including retransmissions.",
+		}, []string{"type"}),
+		retransmissions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retransmissions_total",
+			Help:      "Messages retransmitted for lack of a response.",
+		}, []string{"type"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "response_latency_seconds",
+			Help:      "Time between the first transmission of a message and its response.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"type"}),
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "timeouts_total",
+			Help:      "Messages left without a response after all retransmissions.",
+		}, []string{"type"}),
+	}
+	for _, c := range []prometheus.Collector{m.sent, m.retransmissions, m.latency, m.timeouts} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *promMetrics) MessageSent(t dhcpv6.MessageType) {
+	m.sent.WithLabelValues(t.String()).Inc()
+}
+
+func (m *promMetrics) Retransmission(t dhcpv6.MessageType) {
+	m.retransmissions.WithLabelValues(t.String()).Inc()
+}
+
+func (m *promMetrics) ResponseReceived(t dhcpv6.MessageType, latency time.Duration) {
+	m.latency.WithLabelValues(t.String()).Observe(latency.Seconds())
+}
+
+func (m *promMetrics) Timeout(t dhcpv6.MessageType) {
+	m.timeouts.WithLabelValues(t.String()).Inc()
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package prommetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg)
+	require.NoError(t, err)
+
+	m.MessageSent(dhcpv6.MessageTypeSolicit)
+	m.MessageSent(dhcpv6.MessageTypeSolicit)
+	m.Retransmission(dhcpv6.MessageTypeSolicit)
+	m.ResponseReceived(dhcpv6.MessageTypeSolicit, 10*time.Millisecond)
+	m.MessageSent(dhcpv6.MessageTypeRequest)
+	m.Timeout(dhcpv6.MessageTypeRequest)
+
+	pm := m.(*promMetrics)
+	require.Equal(t, 2.0, testutil.ToFloat64(pm.sent.WithLabelValues("SOLICIT")))
+	require.Equal(t, 1.0, testutil.ToFloat64(pm.sent.WithLabelValues("REQUEST")))
+	require.Equal(t, 1.0, testutil.ToFloat64(pm.retransmissions.WithLabelValues("SOLICIT")))
+	require.Equal(t, 1.0, testutil.ToFloat64(pm.timeouts.WithLabelValues("REQUEST")))
+	require.Equal(t, 1, testutil.CollectAndCount(pm.latency))
+
+	// The metrics can only be registered once.
+	_, err = New(reg)
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// tokenBucket limits the rate of events to a number per second. It holds a
+// single token, so that events are evenly spaced rather than sent in bursts.
+type tokenBucket struct {
+	mu     sync.Mutex
+	clock  dhcpv6.Clock
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a full tokenBucket allowing perSecond events per
+// second.
+func newTokenBucket(perSecond int, clock dhcpv6.Clock) *tokenBucket {
+	return &tokenBucket{
+		clock:  clock,
+		rate:   float64(perSecond),
+		tokens: 1,
+		last:   clock.Now(),
+	}
+}
+
+// wait takes a token, waiting for one to be available if needed. It returns
+// an error if ctx is done or done is closed first.
+func (b *tokenBucket) wait(ctx context.Context, done <-chan struct{}) error {
+	for {
+		b.mu.Lock()
+		now := b.clock.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > 1 {
+			b.tokens = 1
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-b.clock.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-done:
+			return ErrClosed
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// ForwardRelay sends relayForw, a Relay-Forward message, upstream and waits
+// for the Relay-Reply whose innermost message has the Transaction ID of the
+// message relayForw relays. This allows a relay agent to use the Client as its
+// leg toward the servers.
+//
+// As required by RFC 3315, Section 20.1.2, the hop count of relayForw is set to
+// 0 if it relays a client message, or to the hop count of the Relay-Forward
+// message it relays incremented by 1. Relay-Forward messages whose relayed
+// message has reached dhcpv6.HopCountLimit are rejected.
+//
+// relayForw is sent to the address configured with WithDestForType for
+// MessageTypeRelayForward, or to All_DHCP_Servers by default, and is
+// retransmitted like the messages sent by SendAndRead.
+func (c *Client) ForwardRelay(ctx context.Context, relayForw *dhcpv6.RelayMessage) (*dhcpv6.RelayMessage, error) {
+	if relayForw == nil {
+		return nil, errors.New("relay message cannot be nil")
+	}
+	if relayForw.Type() != dhcpv6.MessageTypeRelayForward {
+		return nil, fmt.Errorf("cannot forward a %s message, want %s", relayForw.Type(), dhcpv6.MessageTypeRelayForward)
+	}
+	relayed, err := dhcpv6.DecapsulateRelay(relayForw)
+	if err != nil {
+		return nil, err
+	}
+	relayForw.HopCount = 0
+	if r, ok := relayed.(*dhcpv6.RelayMessage); ok {
+		if r.HopCount >= dhcpv6.HopCountLimit {
+			return nil, fmt.Errorf("relayed message hop count %d reached the limit of %d", r.HopCount, dhcpv6.HopCountLimit)
+		}
+		relayForw.HopCount = r.HopCount + 1
+	}
+	inner, err := relayForw.GetInnerMessage()
+	if err != nil {
+		return nil, err
+	}
+	b, err := relayForw.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		replies = make(map[*dhcpv6.Message]*dhcpv6.RelayMessage)
+	)
+	record := func(msg *dhcpv6.Message, relay *dhcpv6.RelayMessage) {
+		mu.Lock()
+		defer mu.Unlock()
+		replies[msg] = relay
+	}
+	dest := c.serverDest(dhcpv6.MessageTypeRelayForward)
+	start := c.clock.Now()
+	transmit := func() (<-chan Received, func(), error) {
+		ch, rem, err := c.register(inner.TransactionID, &pendingCh{relayed: record})
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.write(ctx, dest, b); err != nil {
+			rem()
+			return nil, nil, err
+		}
+		return ch, rem, nil
+	}
+	ch, rem, err := transmit()
+	if err != nil {
+		return nil, err
+	}
+	retransmit := func() (<-chan Received, func(), error) {
+		ch, rem, err := transmit()
+		if err == nil && c.metrics != nil {
+			c.metrics.Retransmission(dhcpv6.MessageTypeRelayForward)
+		}
+		return ch, rem, err
+	}
+
+	if c.operationTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.operationTimeout)
+		defer cancel()
+	}
+	resp, _, err := c.sendAndRead(ctx, MatchAll, retransmit, ch, rem)
+	c.observeExchange(dhcpv6.MessageTypeRelayForward, start, err)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return replies[resp], nil
+}
+
+// serverDest returns the address messages of type t, which are only handled
+// by servers, are sent to: All_DHCP_Servers unless configured otherwise with
+// WithDestForType.
+func (c *Client) serverDest(t dhcpv6.MessageType) *net.UDPAddr {
+	if addr := c.dests[t]; addr != nil {
+		return addr
+	}
+	return AllDHCPServers
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/stretchr/testify/require"
+)
+
+// serveRelay answers each Relay-Forward read from conn with a plain Reply to
+// the relayed message, which a relay must ignore, then with a Relay-Reply
+// carrying the same Reply. The hop counts of the Relay-Forward messages are
+// sent on hops.
+func serveRelay(conn net.PacketConn, hops chan<- uint8) {
+	for {
+		b := make([]byte, maxMessageSize)
+		n, peer, err := conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		relay, err := dhcpv6.RelayMessageFromBytes(b[:n])
+		if err != nil {
+			continue
+		}
+		hops <- relay.HopCount
+		inner, err := relay.GetInnerMessage()
+		if err != nil {
+			continue
+		}
+		reply := newPacket(dhcpv6.MessageTypeReply, inner.TransactionID)
+		repl, err := dhcpv6.NewRelayReplFromRelayForw(relay, reply)
+		if err != nil {
+			continue
+		}
+		_, _ = conn.WriteTo(reply.ToBytes(), peer)
+		_, _ = conn.WriteTo(repl.ToBytes(), peer)
+	}
+}
+
+func TestForwardRelay(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	hops := make(chan uint8, 2)
+	go serveRelay(serverConn, hops)
+
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(time.Second))
+	defer mc.Close()
+
+	solicit := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	linkAddr := net.ParseIP("2001:db8::1")
+	peerAddr := net.ParseIP("fe80::1")
+	forw, err := dhcpv6.NewRelayForward(solicit, linkAddr, peerAddr)
+	require.NoError(t, err)
+
+	repl, err := mc.ForwardRelay(context.Background(), forw)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), <-hops)
+	require.Equal(t, dhcpv6.MessageTypeRelayReply, repl.Type())
+	require.True(t, repl.LinkAddr.Equal(linkAddr))
+	require.True(t, repl.PeerAddr.Equal(peerAddr))
+	inner, err := repl.GetInnerMessage()
+	require.NoError(t, err)
+	require.Equal(t, dhcpv6.MessageTypeReply, inner.MessageType)
+	require.Equal(t, solicit.TransactionID, inner.TransactionID)
+
+	// The hop count of a Relay-Forward relaying another one is fixed up.
+	solicit = newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x44, 0x44, 0x44})
+	forw, err = dhcpv6.NewRelayForward(solicit, linkAddr, peerAddr)
+	require.NoError(t, err)
+	forw.HopCount = 3
+	outer, err := dhcpv6.NewRelayForward(forw, nil, linkAddr)
+	require.NoError(t, err)
+	outer.HopCount = 0
+
+	_, err = mc.ForwardRelay(context.Background(), outer)
+	require.NoError(t, err)
+	require.Equal(t, uint8(4), <-hops)
+}
+
+func TestForwardRelayInvalid(t *testing.T) {
+	mc, serverConn := serveAndClient(context.Background(), nil)
+	defer mc.Close()
+	defer serverConn.Close()
+
+	solicit := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	repl, err := dhcpv6.EncapsulateRelay(solicit, dhcpv6.MessageTypeRelayReply, net.IPv6loopback, net.IPv6loopback)
+	require.NoError(t, err)
+	_, err = mc.ForwardRelay(context.Background(), repl)
+	require.Error(t, err)
+
+	_, err = mc.ForwardRelay(context.Background(), nil)
+	require.Error(t, err)
+
+	forw, err := dhcpv6.NewRelayForward(solicit, net.IPv6loopback, net.IPv6loopback)
+	require.NoError(t, err)
+	forw.HopCount = dhcpv6.HopCountLimit
+	outer, err := dhcpv6.NewRelayForward(forw, nil, nil)
+	require.NoError(t, err)
+	_, err = mc.ForwardRelay(context.Background(), outer)
+	require.Error(t, err)
+}
+
+func TestForwardRelayNoRetry(t *testing.T) {
+	mc, serverConn := serveAndClient(context.Background(), nil, WithRetry(0))
+	defer mc.Close()
+	defer serverConn.Close()
+
+	solicit := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	for i := 0; i < 2; i++ {
+		forw, err := dhcpv6.NewRelayForward(solicit, net.IPv6loopback, net.IPv6loopback)
+		require.NoError(t, err)
+		_, err = mc.ForwardRelay(context.Background(), forw)
+		require.Equal(t, ErrNoResponse, err)
+	}
+	mc.pendingMu.Lock()
+	require.Empty(t, mc.pending)
+	mc.pendingMu.Unlock()
+}
+
+func TestForwardRelayPassiveHandler(t *testing.T) {
+	clientConn, serverConn, err := packetSocketPair()
+	require.NoError(t, err)
+	defer serverConn.Close()
+	linkAddr := net.ParseIP("2001:db8::1")
+	peerAddr := net.ParseIP("fe80::1")
+	go func() {
+		b := make([]byte, maxMessageSize)
+		n, peer, err := serverConn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		relay, err := dhcpv6.RelayMessageFromBytes(b[:n])
+		if err != nil {
+			return
+		}
+		inner, err := relay.GetInnerMessage()
+		if err != nil {
+			return
+		}
+		// The Relay-Forward of another relay agent, which the Client
+		// only passes to the passive handler.
+		forw, err := dhcpv6.NewRelayForward(newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x55, 0x55, 0x55}), linkAddr, peerAddr)
+		if err != nil {
+			return
+		}
+		_, _ = serverConn.WriteTo(forw.ToBytes(), peer)
+		repl, err := dhcpv6.NewRelayReplFromRelayForw(relay, newPacket(dhcpv6.MessageTypeReply, inner.TransactionID))
+		if err != nil {
+			return
+		}
+		_, _ = serverConn.WriteTo(repl.ToBytes(), peer)
+	}()
+
+	var mu sync.Mutex
+	var seen []*dhcpv6.Message
+	handler := func(m *dhcpv6.Message, peer net.Addr) {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, m)
+	}
+	mc := NewWithConn(clientConn, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithRetry(1), WithTimeout(time.Second), WithPassiveHandler(handler))
+	defer mc.Close()
+
+	solicit := newPacket(dhcpv6.MessageTypeSolicit, [3]byte{0x33, 0x33, 0x33})
+	forw, err := dhcpv6.NewRelayForward(solicit, linkAddr, peerAddr)
+	require.NoError(t, err)
+	_, err = mc.ForwardRelay(context.Background(), forw)
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, seen, 2)
+	require.Equal(t, dhcpv6.MessageTypeSolicit, seen[0].MessageType)
+	require.Equal(t, dhcpv6.TransactionID{0x55, 0x55, 0x55}, seen[0].TransactionID)
+	require.Equal(t, dhcpv6.MessageTypeReply, seen[1].MessageType)
+	require.Equal(t, solicit.TransactionID, seen[1].TransactionID)
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// RogueServer is a server that answered a Solicit without being allowed to.
+type RogueServer struct {
+	// ServerID is the DUID of the server.
+	ServerID dhcpv6.Duid
+
+	// Addr is the address the Advertise was received from. It is the
+	// address of the relay agent if the Advertise was relayed.
+	Addr net.Addr
+
+	// Addresses, Prefixes and DNS are the configuration offered by the
+	// server.
+	Addresses []net.IP
+	Prefixes  []net.IPNet
+	DNS       []net.IP
+
+	// Advertise is the Advertise sent by the server.
+	Advertise *dhcpv6.Message
+}
+
+// DetectRogueServers solicits all servers like SolicitAll and reports the ones
+// whose DUID is not in allowlist, e.g. to find unauthorized servers on a
+// network. Advertises are collected for the Client's timeout, as configured
+// with WithTimeout.
+//
+// If no server answers, no rogue server is reported. If ctx is done early,
+// the rogue servers found so far are returned along with ErrWindowIncomplete.
+func (c *Client) DetectRogueServers(ctx context.Context, allowlist []dhcpv6.Duid, modifiers ...dhcpv6.Modifier) ([]RogueServer, error) {
+	var mu sync.Mutex
+	sources := make(map[*dhcpv6.Message]net.Addr)
+	observe := func(msg *dhcpv6.Message, peer net.Addr) {
+		mu.Lock()
+		defer mu.Unlock()
+		sources[msg] = peer
+	}
+
+	advertises, err := c.solicitAll(ctx, c.timeout, observe, modifiers...)
+	if err == ErrNoResponse {
+		return nil, nil
+	}
+	if err != nil && err != ErrWindowIncomplete {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var rogues []RogueServer
+	for _, adv := range advertises {
+		// solicitAll only returns Advertises with a Server ID.
+		sid := adv.GetOneOption(dhcpv6.OptionServerID).(*dhcpv6.OptServerId).Sid
+		if isAllowed(sid, allowlist) {
+			continue
+		}
+		rogue := RogueServer{
+			ServerID:  sid,
+			Addr:      sources[adv],
+			Advertise: adv,
+		}
+		for _, opt := range adv.Options {
+			switch o := opt.(type) {
+			case *dhcpv6.OptIANA:
+				for _, a := range o.Options.Get(dhcpv6.OptionIAAddr) {
+					if addr, ok := a.(*dhcpv6.OptIAAddress); ok {
+						rogue.Addresses = append(rogue.Addresses, addr.IPv6Addr)
+					}
+				}
+			case *dhcpv6.OptDNSRecursiveNameServer:
+				rogue.DNS = append(rogue.DNS, o.NameServers...)
+			}
+		}
+		for _, p := range adv.DelegatedPrefixes() {
+			rogue.Prefixes = append(rogue.Prefixes, p.Prefix)
+		}
+		rogues = append(rogues, rogue)
+	}
+	return rogues, err
+}
+
+// isAllowed returns whether duid is in allowlist.
+func isAllowed(duid dhcpv6.Duid, allowlist []dhcpv6.Duid) bool {
+	b := duid.ToBytes()
+	for _, allowed := range allowlist {
+		if bytes.Equal(b, allowed.ToBytes()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectRogueServers(t *testing.T) {
+	trusted := dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{1, 2, 3, 4, 5, 6}}
+	rogue := dhcpv6.Duid{Type: dhcpv6.DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{6, 6, 6, 6, 6, 6}}
+	trustedAddr := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: dhcpv6.DefaultServerPort}
+	rogueAddr := &net.UDPAddr{IP: net.ParseIP("fe80::666"), Port: dhcpv6.DefaultServerPort}
+
+	tr := newMemTransport(func(m *dhcpv6.Message) []memPacket {
+		if m.MessageType != dhcpv6.MessageTypeSolicit {
+			return nil
+		}
+		good, err := dhcpv6.NewAdvertiseFromSolicit(m, dhcpv6.WithServerID(trusted))
+		if err != nil {
+			return nil
+		}
+		bad, err := dhcpv6.NewAdvertiseFromSolicit(m, dhcpv6.WithServerID(rogue),
+			dhcpv6.WithDNS(net.ParseIP("2001:db8::666")),
+			dhcpv6.WithIANA(dhcpv6.OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::66")}))
+		if err != nil {
+			return nil
+		}
+		return []memPacket{
+			{b: good.ToBytes(), from: trustedAddr},
+			{b: bad.ToBytes(), from: rogueAddr},
+		}
+	})
+	mc := NewWithTransport(tr, net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf},
+		WithTimeout(100*time.Millisecond))
+	defer mc.Close()
+
+	rogues, err := mc.DetectRogueServers(context.Background(), []dhcpv6.Duid{trusted})
+	require.NoError(t, err)
+	require.Len(t, rogues, 1)
+	require.Equal(t, rogue, rogues[0].ServerID)
+	require.Equal(t, rogueAddr, rogues[0].Addr)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::666")}, rogues[0].DNS)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::66")}, rogues[0].Addresses)
+	require.NotNil(t, rogues[0].Advertise)
+
+	// All servers are allowed.
+	rogues, err = mc.DetectRogueServers(context.Background(), []dhcpv6.Duid{rogue, trusted})
+	require.NoError(t, err)
+	require.Empty(t, rogues)
+}
+
+func TestDetectRogueServersNoServer(t *testing.T) {
+	mc := NewWithTransport(newMemTransport(func(*dhcpv6.Message) []memPacket { return nil }),
+		net.HardwareAddr{0xa, 0xb, 0xc, 0xd, 0xe, 0xf}, WithTimeout(50*time.Millisecond))
+	defer mc.Close()
+
+	rogues, err := mc.DetectRogueServers(context.Background(), nil)
+	require.NoError(t, err)
+	require.Empty(t, rogues)
+}
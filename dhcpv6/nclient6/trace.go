@@ -0,0 +1,100 @@
+// Copyright 2018 the u-root Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build go1.12
+
+package nclient6
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/dhcpv6"
+)
+
+// TraceEventType is the kind of a TraceEvent.
+type TraceEventType int
+
+// Trace event types.
+const (
+	// TraceUnicastAccepted is emitted when the Client will unicast
+	// messages to the address of a Server Unicast option.
+	TraceUnicastAccepted TraceEventType = iota
+	// TraceUnicastRejected is emitted when the Client ignores the address
+	// of a Server Unicast option, and keeps sending messages by multicast.
+	TraceUnicastRejected
+)
+
+func (t TraceEventType) String() string {
+	switch t {
+	case TraceUnicastAccepted:
+		return "UnicastAccepted"
+	case TraceUnicastRejected:
+		return "UnicastRejected"
+	}
+	return fmt.Sprintf("TraceEventType(%d)", int(t))
+}
+
+// TraceEvent describes a decision taken by the Client, for debugging.
+type TraceEvent struct {
+	Type TraceEventType
+
+	// Message is the message the event relates to.
+	Message *dhcpv6.Message
+
+	// Source is the address Message was received from, if it was received
+	// by the Client.
+	Source net.Addr
+
+	// Addr is the address the event relates to, if any.
+	Addr net.Addr
+
+	// Err explains the event, if relevant.
+	Err error
+
+	// Attribution identifies the downstream client the exchange was
+	// performed on behalf of, if its context carries one, e.g. when it was
+	// started with SendAndReadWithCtx or a context built with
+	// ContextWithAttribution.
+	Attribution *Attribution
+}
+
+func (e TraceEvent) String() string {
+	s := e.Type.String()
+	if e.Addr != nil {
+		s += " " + e.Addr.String()
+	}
+	if e.Source != nil {
+		s += " from " + e.Source.String()
+	}
+	if e.Attribution != nil {
+		s += " for " + e.Attribution.String()
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// WithTrace configures a function called with the Client's trace events.
+//
+// The function may be called concurrently, and must not block.
+func WithTrace(f func(TraceEvent)) ClientOpt {
+	return func(c *Client) {
+		c.trace = f
+	}
+}
+
+// traceEvent passes e to the trace function, if any, filling in the
+// Attribution carried by ctx, the context of the exchange.
+func (c *Client) traceEvent(ctx context.Context, e TraceEvent) {
+	if c.trace == nil {
+		return
+	}
+	if a, ok := AttributionFromContext(ctx); ok && e.Attribution == nil {
+		e.Attribution = &a
+	}
+	c.trace(e)
+}
@@ -0,0 +1,46 @@
+package dhcpv6
+
+import (
+	"fmt"
+)
+
+// OptAFTRName implements the AFTR-Name option.
+//
+// This module defines the OptAFTRName structure.
+// https://www.ietf.org/rfc/rfc6334.txt
+type OptAFTRName struct {
+	// Name is the FQDN of the AFTR tunnel endpoint used by DS-Lite.
+	Name string
+}
+
+func (op *OptAFTRName) Code() OptionCode {
+	return OptionAFTRName
+}
+
+// ToBytes marshals this option according to RFC 6334, Section 3.
+func (op *OptAFTRName) ToBytes() []byte {
+	return encodeString(StringFQDN, op.Name)
+}
+
+func (op *OptAFTRName) String() string {
+	return fmt.Sprintf("OptAFTRName{name=%v}", op.Name)
+}
+
+// ParseOptAFTRName builds an OptAFTRName structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptAFTRName(data []byte) (*OptAFTRName, error) {
+	name, err := decodeString("AFTR-Name", StringFQDN, data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptAFTRName{Name: name}, nil
+}
+
+// AFTRName returns the AFTR name carried by the message, if any.
+func (m *Message) AFTRName() (string, bool) {
+	opt, ok := m.GetOneOption(OptionAFTRName).(*OptAFTRName)
+	if !ok {
+		return "", false
+	}
+	return opt.Name, true
+}
@@ -0,0 +1,47 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptAFTRName(t *testing.T) {
+	data := []byte{
+		4, 'a', 'f', 't', 'r', 3, 'i', 's', 'p', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'n', 'e', 't', 0,
+	}
+	opt, err := ParseOptAFTRName(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionAFTRName, opt.Code())
+	require.Equal(t, "aftr.isp.example.net", opt.Name)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "name=aftr.isp.example.net")
+}
+
+func TestOptAFTRNameRoundTrip(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	m.AddOption(&OptAFTRName{Name: "aftr.example.com"})
+
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	name, ok := parsed.AFTRName()
+	require.True(t, ok)
+	require.Equal(t, "aftr.example.com", name)
+
+	_, ok = (&Message{}).AFTRName()
+	require.False(t, ok)
+}
+
+func TestParseOptAFTRNameInvalid(t *testing.T) {
+	// Truncated label.
+	_, err := ParseOptAFTRName([]byte{4, 'a', 'f'})
+	require.Error(t, err)
+
+	// Empty.
+	_, err = ParseOptAFTRName([]byte{})
+	require.Error(t, err)
+
+	// Two names.
+	_, err = ParseOptAFTRName([]byte{1, 'a', 0, 1, 'b', 0})
+	require.Error(t, err)
+}
@@ -38,3 +38,16 @@ func TestOptClientArchType(t *testing.T) {
 	require.Equal(t, OptionClientArchType, opt.Code())
 	require.Contains(t, opt.String(), "archtype=EFI Itanium", "String() should contain the correct ArchType output")
 }
+
+func TestOptClientArchTypeMultiple(t *testing.T) {
+	data := []byte{
+		0, 7, // EFI_BC
+		0, 9, // EFI_X86_64
+		0, 0, // INTEL_X86PC
+	}
+	opt, err := ParseOptClientArchType(data)
+	require.NoError(t, err)
+	require.Equal(t, []iana.Arch{iana.EFI_BC, iana.EFI_X86_64, iana.INTEL_X86PC}, opt.ArchTypes)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "archtype=EFI BC, EFI x86-64, Intel x86PC")
+}
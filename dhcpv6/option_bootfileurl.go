@@ -19,7 +19,7 @@ func (op *OptBootFileURL) Code() OptionCode {
 
 // ToBytes serializes the option and returns it as a sequence of bytes
 func (op *OptBootFileURL) ToBytes() []byte {
-	return op.BootFileURL
+	return encodeString(StringASCII, string(op.BootFileURL))
 }
 
 func (op *OptBootFileURL) String() string {
@@ -29,7 +29,6 @@ func (op *OptBootFileURL) String() string {
 // ParseOptBootFileURL builds an OptBootFileURL structure from a sequence
 // of bytes. The input data does not include option code and length bytes.
 func ParseOptBootFileURL(data []byte) (*OptBootFileURL, error) {
-	var opt OptBootFileURL
-	opt.BootFileURL = append([]byte(nil), data...)
-	return &opt, nil
+	// Unlike other string options, an empty boot file URL is accepted.
+	return &OptBootFileURL{BootFileURL: append([]byte(nil), data...)}, nil
 }
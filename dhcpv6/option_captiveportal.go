@@ -0,0 +1,63 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// OptCaptivePortal implements the Captive-Portal option.
+//
+// This module defines the OptCaptivePortal structure.
+// https://www.ietf.org/rfc/rfc8910.txt
+type OptCaptivePortal struct {
+	// URI is the URI of the captive portal API, or
+	// "urn:ietf:params:capport:unrestricted" if there is no captive portal.
+	URI string
+}
+
+// Code returns the option code
+func (op *OptCaptivePortal) Code() OptionCode {
+	return OptionCaptivePortal
+}
+
+// ToBytes marshals this option according to RFC 8910, Section 2.2.
+func (op *OptCaptivePortal) ToBytes() []byte {
+	return encodeString(StringASCII, op.URI)
+}
+
+func (op *OptCaptivePortal) String() string {
+	return fmt.Sprintf("OptCaptivePortal{uri=%v}", op.URI)
+}
+
+// Validate checks that the URI is absolute, as required by RFC 8910,
+// Section 2.
+func (op *OptCaptivePortal) Validate() error {
+	u, err := url.Parse(op.URI)
+	if err != nil {
+		return fmt.Errorf("invalid captive portal URI: %v", err)
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("captive portal URI %q is not absolute", op.URI)
+	}
+	return nil
+}
+
+// ParseOptCaptivePortal builds an OptCaptivePortal structure from a sequence
+// of bytes. The input data does not include option code and length bytes.
+func ParseOptCaptivePortal(data []byte) (*OptCaptivePortal, error) {
+	uri, err := decodeString("captive portal URI", StringASCII, data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptCaptivePortal{URI: uri}, nil
+}
+
+// CaptivePortalURI returns the captive portal URI carried by the message, if
+// any.
+func (m *Message) CaptivePortalURI() (string, bool) {
+	opt, ok := m.GetOneOption(OptionCaptivePortal).(*OptCaptivePortal)
+	if !ok {
+		return "", false
+	}
+	return opt.URI, true
+}
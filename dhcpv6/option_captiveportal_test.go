@@ -0,0 +1,64 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptCaptivePortal(t *testing.T) {
+	data := []byte("https://portal.example.com/api")
+	opt, err := ParseOptCaptivePortal(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionCaptivePortal, opt.Code())
+	require.Equal(t, "https://portal.example.com/api", opt.URI)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "uri=https://portal.example.com/api")
+
+	opt, err = ParseOptCaptivePortal([]byte("urn:ietf:params:capport:unrestricted"))
+	require.NoError(t, err)
+	require.Equal(t, "urn:ietf:params:capport:unrestricted", opt.URI)
+}
+
+func TestParseOptCaptivePortalEmpty(t *testing.T) {
+	_, err := ParseOptCaptivePortal(nil)
+	require.Error(t, err)
+}
+
+func TestOptCaptivePortalValidate(t *testing.T) {
+	require.NoError(t, (&OptCaptivePortal{URI: "https://portal.example.com/api"}).Validate())
+	for _, uri := range []string{"portal.example.com/api", "https://[::1"} {
+		opt, err := ParseOptCaptivePortal([]byte(uri))
+		require.NoError(t, err, uri)
+		require.Error(t, opt.Validate(), uri)
+	}
+}
+
+func TestOptCaptivePortalInvalidInMessage(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	m.AddOption(&OptCaptivePortal{URI: "portal.example.com/api"})
+	data := m.ToBytes()
+
+	parsed, err := MessageFromBytes(data)
+	require.NoError(t, err)
+	uri, ok := parsed.CaptivePortalURI()
+	require.True(t, ok)
+	require.Equal(t, "portal.example.com/api", uri)
+
+	_, err = MessageFromBytesStrict(data)
+	require.Error(t, err)
+}
+
+func TestOptCaptivePortalRoundTrip(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	m.AddOption(&OptCaptivePortal{URI: "https://portal.example.com/api"})
+
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	uri, ok := parsed.CaptivePortalURI()
+	require.True(t, ok)
+	require.Equal(t, "https://portal.example.com/api", uri)
+
+	_, ok = (&Message{}).CaptivePortalURI()
+	require.False(t, ok)
+}
@@ -25,6 +25,11 @@ func (op *OptClientId) String() string {
 	return fmt.Sprintf("OptClientId{cid=%v}", op.Cid.String())
 }
 
+// Validate checks that the Client ID is a valid DUID.
+func (op *OptClientId) Validate() error {
+	return op.Cid.Validate()
+}
+
 // ParseOptClientId builds an OptClientId structure from a sequence
 // of bytes. The input data does not include option code and length
 // bytes.
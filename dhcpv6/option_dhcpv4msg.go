@@ -0,0 +1,46 @@
+package dhcpv6
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// OptDHCPv4Msg implements the DHCPv4 Message option, which carries a DHCPv4
+// message in DHCPv4-query and DHCPv4-response messages.
+//
+// This module defines the OptDHCPv4Msg structure.
+// https://www.ietf.org/rfc/rfc7341.txt
+type OptDHCPv4Msg struct {
+	Msg *dhcpv4.DHCPv4
+}
+
+func (op *OptDHCPv4Msg) Code() OptionCode {
+	return OptionDHCPv4Msg
+}
+
+// ToBytes marshals this option according to RFC 7341, Section 7.1.
+func (op *OptDHCPv4Msg) ToBytes() []byte {
+	if op.Msg == nil {
+		return nil
+	}
+	return op.Msg.ToBytes()
+}
+
+func (op *OptDHCPv4Msg) String() string {
+	return fmt.Sprintf("OptDHCPv4Msg{msg=%v}", op.Msg)
+}
+
+// ParseOptDHCPv4Msg builds an OptDHCPv4Msg structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func ParseOptDHCPv4Msg(data []byte) (*OptDHCPv4Msg, error) {
+	if len(data) == 0 {
+		return nil, errors.New("DHCPv4 message cannot be empty")
+	}
+	msg, err := dhcpv4.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptDHCPv4Msg{Msg: msg}, nil
+}
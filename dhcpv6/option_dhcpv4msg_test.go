@@ -0,0 +1,43 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptDHCPv4MsgRoundTrip(t *testing.T) {
+	discover, err := dhcpv4.NewDiscovery(net.HardwareAddr{1, 2, 3, 4, 5, 6})
+	require.NoError(t, err)
+
+	query := &Message{
+		MessageType: MessageTypeDHCPv4Query,
+		// The flags field of DHCPv4-query, with the unicast flag set.
+		TransactionID: TransactionID{0x80, 0, 0},
+	}
+	query.AddOption(&OptDHCPv4Msg{Msg: discover})
+
+	parsed, err := FromBytes(query.ToBytes())
+	require.NoError(t, err)
+	m := parsed.(*Message)
+	require.Equal(t, MessageTypeDHCPv4Query, m.MessageType)
+	require.Equal(t, "DHCPV4-QUERY", m.MessageType.String())
+	require.Equal(t, TransactionID{0x80, 0, 0}, m.TransactionID)
+
+	opt, ok := m.GetOneOption(OptionDHCPv4Msg).(*OptDHCPv4Msg)
+	require.True(t, ok)
+	require.Equal(t, discover.TransactionID, opt.Msg.TransactionID)
+	require.Equal(t, discover.ClientHWAddr, opt.Msg.ClientHWAddr)
+	require.Equal(t, dhcpv4.MessageTypeDiscover, opt.Msg.MessageType())
+	require.Equal(t, discover.ToBytes(), opt.ToBytes())
+}
+
+func TestParseOptDHCPv4MsgInvalid(t *testing.T) {
+	_, err := ParseOptDHCPv4Msg(nil)
+	require.Error(t, err)
+
+	_, err = ParseOptDHCPv4Msg([]byte{1, 2, 3})
+	require.Error(t, err)
+}
@@ -2,10 +2,16 @@ package dhcpv6
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/u-root/u-root/pkg/uio"
 )
 
+// MaxElapsedTime is the largest elapsed time that can be represented by the
+// Elapsed Time option. Longer durations are encoded as 0xffff, see RFC 8415,
+// Section 21.9.
+const MaxElapsedTime = 0xffff * 10 * time.Millisecond
+
 // OptElapsedTime implements the Elapsed Time option.
 //
 // This module defines the OptElapsedTime structure.
@@ -25,6 +31,48 @@ func (op *OptElapsedTime) ToBytes() []byte {
 	return buf.Data()
 }
 
+// SetElapsedTime sets the elapsed time from a duration, converting it to
+// hundredths of a second. Durations that do not fit in the 16-bit field are
+// clamped to 0xffff instead of wrapping around, and negative durations are
+// encoded as 0.
+func (op *OptElapsedTime) SetElapsedTime(d time.Duration) {
+	switch {
+	case d <= 0:
+		op.ElapsedTime = 0
+	case d >= MaxElapsedTime:
+		op.ElapsedTime = 0xffff
+	default:
+		op.ElapsedTime = uint16(d / (10 * time.Millisecond))
+	}
+}
+
+// ElapsedTimer keeps the Elapsed Time option of the messages of an exchange
+// up to date. The zero value is ready to use.
+type ElapsedTimer struct {
+	// Clock is the source of time of the timer. If nil, RealClock is used.
+	Clock Clock
+
+	start time.Time
+}
+
+// Stamp sets the Elapsed Time option of d, if present, to the time elapsed
+// since the first call to Stamp. The first call therefore always sets it to
+// exactly 0, as required by RFC 3315, Section 22.9, for the first message of
+// an exchange.
+func (t *ElapsedTimer) Stamp(d DHCPv6) {
+	clock := t.Clock
+	if clock == nil {
+		clock = RealClock
+	}
+	now := clock.Now()
+	if t.start.IsZero() {
+		t.start = now
+	}
+	if opt, ok := d.GetOneOption(OptionElapsedTime).(*OptElapsedTime); ok {
+		opt.SetElapsedTime(now.Sub(t.start))
+	}
+}
+
 func (op *OptElapsedTime) String() string {
 	return fmt.Sprintf("OptElapsedTime{elapsedtime=%v}", op.ElapsedTime)
 }
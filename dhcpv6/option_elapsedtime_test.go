@@ -3,6 +3,7 @@ package dhcpv6
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -33,6 +34,27 @@ func TestOptElapsedTimeSetGetElapsedTime(t *testing.T) {
 	}
 }
 
+func TestOptElapsedTimeSetElapsedTimeDuration(t *testing.T) {
+	opt := OptElapsedTime{}
+	opt.SetElapsedTime(1500 * time.Millisecond)
+	require.Equal(t, uint16(150), opt.ElapsedTime)
+
+	opt.SetElapsedTime(-time.Second)
+	require.Equal(t, uint16(0), opt.ElapsedTime)
+}
+
+func TestOptElapsedTimeSetElapsedTimeOverflow(t *testing.T) {
+	opt := OptElapsedTime{}
+	// 700 seconds is 70000 hundredths of a second, which would wrap to
+	// 4464 if it were not clamped.
+	opt.SetElapsedTime(700 * time.Second)
+	require.Equal(t, uint16(0xffff), opt.ElapsedTime)
+	require.Equal(t, []byte{0xff, 0xff}, opt.ToBytes())
+
+	opt.SetElapsedTime(MaxElapsedTime)
+	require.Equal(t, []byte{0xff, 0xff}, opt.ToBytes())
+}
+
 func TestOptElapsedTimeString(t *testing.T) {
 	opt := OptElapsedTime{}
 	opt.ElapsedTime = 10
@@ -49,3 +71,22 @@ func TestOptElapsedTimeParseInvalidOption(t *testing.T) {
 	_, err = ParseOptElapsedTime([]byte{0xaa, 0xbb, 0xcc})
 	require.Error(t, err, "An option with too many bytes should return an error")
 }
+
+func TestElapsedTimer(t *testing.T) {
+	m := &Message{MessageType: MessageTypeSolicit}
+	m.AddOption(&OptElapsedTime{ElapsedTime: 42})
+
+	clock := &fakeClock{now: time.Now()}
+	timer := ElapsedTimer{Clock: clock}
+	timer.Stamp(m)
+	require.Equal(t, []byte{0, 0}, m.GetOneOption(OptionElapsedTime).ToBytes())
+
+	clock.Advance(time.Second)
+	timer.Stamp(m)
+	require.Equal(t, uint16(100), m.GetOneOption(OptionElapsedTime).(*OptElapsedTime).ElapsedTime)
+
+	// Messages without the option are left alone.
+	m = &Message{MessageType: MessageTypeSolicit}
+	timer.Stamp(m)
+	require.Empty(t, m.Options)
+}
@@ -0,0 +1,48 @@
+package dhcpv6
+
+import (
+	"fmt"
+)
+
+// OptERPLocalDomainName implements the ERP Local Domain Name option.
+//
+// This module defines the OptERPLocalDomainName structure.
+// https://www.ietf.org/rfc/rfc6440.txt
+type OptERPLocalDomainName struct {
+	// Name is the FQDN of the local domain used for EAP re-authentication.
+	Name string
+}
+
+func (op *OptERPLocalDomainName) Code() OptionCode {
+	return OptionERPLocalDomainName
+}
+
+// ToBytes marshals this option according to RFC 6440, Section 3.
+func (op *OptERPLocalDomainName) ToBytes() []byte {
+	return encodeString(StringFQDN, op.Name)
+}
+
+func (op *OptERPLocalDomainName) String() string {
+	return fmt.Sprintf("OptERPLocalDomainName{name=%v}", op.Name)
+}
+
+// ParseOptERPLocalDomainName builds an OptERPLocalDomainName structure from a
+// sequence of bytes. The input data does not include option code and length
+// bytes.
+func ParseOptERPLocalDomainName(data []byte) (*OptERPLocalDomainName, error) {
+	name, err := decodeString("ERP Local Domain Name", StringFQDN, data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptERPLocalDomainName{Name: name}, nil
+}
+
+// ERPLocalDomainName returns the ERP local domain name carried by the message,
+// if any.
+func (m *Message) ERPLocalDomainName() (string, bool) {
+	opt, ok := m.GetOneOption(OptionERPLocalDomainName).(*OptERPLocalDomainName)
+	if !ok {
+		return "", false
+	}
+	return opt.Name, true
+}
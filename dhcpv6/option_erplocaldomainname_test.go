@@ -0,0 +1,43 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptERPLocalDomainName(t *testing.T) {
+	data := []byte{
+		3, 'e', 'r', 'p', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+	}
+	opt, err := ParseOptERPLocalDomainName(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionERPLocalDomainName, opt.Code())
+	require.Equal(t, "erp.example.com", opt.Name)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "name=erp.example.com")
+}
+
+func TestOptERPLocalDomainNameRoundTrip(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	m.AddOption(&OptERPLocalDomainName{Name: "erp.example.com"})
+
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	name, ok := parsed.ERPLocalDomainName()
+	require.True(t, ok)
+	require.Equal(t, "erp.example.com", name)
+
+	_, ok = (&Message{}).ERPLocalDomainName()
+	require.False(t, ok)
+}
+
+func TestParseOptERPLocalDomainNameInvalid(t *testing.T) {
+	// Truncated label.
+	_, err := ParseOptERPLocalDomainName([]byte{3, 'e', 'r'})
+	require.Error(t, err)
+
+	// Two names.
+	_, err = ParseOptERPLocalDomainName([]byte{1, 'a', 0, 1, 'b', 0})
+	require.Error(t, err)
+}
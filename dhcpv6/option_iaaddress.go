@@ -38,6 +38,19 @@ func (op *OptIAAddress) String() string {
 		op.IPv6Addr, op.PreferredLifetime, op.ValidLifetime, op.Options)
 }
 
+// Validate checks that the address is an IPv6 address whose preferred
+// lifetime does not exceed its valid lifetime, as required by RFC 3315,
+// Section 22.6, and validates its options.
+func (op *OptIAAddress) Validate() error {
+	if op.IPv6Addr.To16() == nil || op.IPv6Addr.To4() != nil {
+		return fmt.Errorf("invalid IPv6 address %v", op.IPv6Addr)
+	}
+	if op.PreferredLifetime > op.ValidLifetime {
+		return fmt.Errorf("preferred lifetime %d is greater than valid lifetime %d", op.PreferredLifetime, op.ValidLifetime)
+	}
+	return op.Options.Validate()
+}
+
 // ParseOptIAAddress builds an OptIAAddress structure from a sequence
 // of bytes. The input data does not include option code and length
 // bytes.
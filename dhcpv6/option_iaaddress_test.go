@@ -89,3 +89,14 @@ func TestOptIAAddressString(t *testing.T) {
 		"String() should return the validlifetime",
 	)
 }
+
+func TestOptIAAddressValidate(t *testing.T) {
+	opt := OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 3600, ValidLifetime: 7200}
+	require.NoError(t, opt.Validate())
+
+	opt.PreferredLifetime = 7201
+	require.Error(t, opt.Validate())
+
+	opt = OptIAAddress{IPv6Addr: net.IP{1, 2, 3}}
+	require.Error(t, opt.Validate())
+}
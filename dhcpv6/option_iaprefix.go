@@ -1,8 +1,10 @@
 package dhcpv6
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/u-root/u-root/pkg/uio"
 )
@@ -19,6 +21,46 @@ type OptIAPrefix struct {
 	Options           Options
 }
 
+// NewOptIAPrefix builds an OptIAPrefix for the given prefix and lifetimes,
+// with opts as sub-options. Lifetimes are truncated to whole seconds, and
+// lifetimes that do not fit in 32 bits are set to infinity.
+//
+// The prefix must be an IPv6 prefix whose address has no bits set past the
+// prefix length.
+func NewOptIAPrefix(prefix net.IPNet, preferred, valid time.Duration, opts ...Option) (*OptIAPrefix, error) {
+	ones, bits := prefix.Mask.Size()
+	if bits != 8*net.IPv6len {
+		return nil, fmt.Errorf("invalid IPv6 prefix mask %v", prefix.Mask)
+	}
+	ip := prefix.IP.To16()
+	if ip == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 prefix %v", prefix.IP)
+	}
+	if !ip.Mask(prefix.Mask).Equal(ip) {
+		return nil, fmt.Errorf("prefix %v has bits set past its length %d", ip, ones)
+	}
+	return &OptIAPrefix{
+		PreferredLifetime: lifetimeSeconds(preferred),
+		ValidLifetime:     lifetimeSeconds(valid),
+		prefixLength:      byte(ones),
+		ipv6Prefix:        ip,
+		Options:           opts,
+	}, nil
+}
+
+// lifetimeSeconds converts d to a lifetime in seconds, saturating to
+// infinity.
+func lifetimeSeconds(d time.Duration) uint32 {
+	switch {
+	case d <= 0:
+		return 0
+	case d/time.Second >= infiniteLifetime:
+		return infiniteLifetime
+	default:
+		return uint32(d / time.Second)
+	}
+}
+
 func (op *OptIAPrefix) Code() OptionCode {
 	return OptionIAPrefix
 }
@@ -57,6 +99,22 @@ func (op *OptIAPrefix) String() string {
 		op.PreferredLifetime, op.ValidLifetime, op.PrefixLength(), op.IPv6Prefix(), op.Options)
 }
 
+// Validate checks that the prefix is an IPv6 prefix of at most 128 bits whose
+// preferred lifetime does not exceed its valid lifetime, as required by RFC
+// 3633, Section 10, and validates its options.
+func (op *OptIAPrefix) Validate() error {
+	if op.prefixLength > 8*net.IPv6len {
+		return fmt.Errorf("invalid prefix length %d", op.prefixLength)
+	}
+	if op.ipv6Prefix.To16() == nil {
+		return fmt.Errorf("invalid IPv6 prefix %v", op.ipv6Prefix)
+	}
+	if op.PreferredLifetime > op.ValidLifetime {
+		return fmt.Errorf("preferred lifetime %d is greater than valid lifetime %d", op.PreferredLifetime, op.ValidLifetime)
+	}
+	return op.Options.Validate()
+}
+
 // GetOneOption will get an option of the give type from the Options field, if
 // it is present. It will return `nil` otherwise
 func (op *OptIAPrefix) GetOneOption(code OptionCode) Option {
@@ -77,6 +135,9 @@ func ParseOptIAPrefix(data []byte) (*OptIAPrefix, error) {
 	opt.ValidLifetime = buf.Read32()
 	opt.prefixLength = buf.Read8()
 	opt.ipv6Prefix = net.IP(buf.CopyN(net.IPv6len))
+	if opt.prefixLength > 8*net.IPv6len {
+		return nil, errors.New("IAPrefix prefix length cannot be greater than 128")
+	}
 	if err := opt.Options.FromBytes(buf.ReadAll()); err != nil {
 		return nil, err
 	}
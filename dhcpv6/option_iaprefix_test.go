@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -94,3 +95,58 @@ func TestOptIAPrefixString(t *testing.T) {
 		"String() should return the validlifetime",
 	)
 }
+
+func TestOptIAPrefixParseInvalidPrefixLength(t *testing.T) {
+	buf := []byte{
+		0, 0, 0, 1, // preferredLifetime
+		0, 0, 0, 2, // validLifetime
+		129,                                            // prefixLength
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // ipv6Prefix
+	}
+	_, err := ParseOptIAPrefix(buf)
+	require.Error(t, err)
+}
+
+func TestNewOptIAPrefix(t *testing.T) {
+	_, prefix, err := net.ParseCIDR("2001:db8:1::/48")
+	require.NoError(t, err)
+	opt, err := NewOptIAPrefix(*prefix, time.Hour, 2*time.Hour, &OptStatusCode{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(3600), opt.PreferredLifetime)
+	require.Equal(t, uint32(7200), opt.ValidLifetime)
+	require.Len(t, opt.Options, 1)
+
+	// RFC 3633 encoding: prefix length, then the 16-byte prefix.
+	b := opt.ToBytes()
+	require.Equal(t, byte(48), b[8])
+	require.Equal(t, []byte(prefix.IP.To16()), b[9:25])
+
+	opt, err = NewOptIAPrefix(net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}, 1<<62, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint8(0), opt.PrefixLength())
+	require.Equal(t, uint32(0xffffffff), opt.PreferredLifetime)
+	require.Equal(t, uint32(0), opt.ValidLifetime)
+
+	// Address with bits set past the prefix length.
+	_, err = NewOptIAPrefix(net.IPNet{IP: net.ParseIP("2001:db8:1::1"), Mask: net.CIDRMask(48, 128)}, time.Hour, time.Hour)
+	require.Error(t, err)
+	// IPv4 mask.
+	_, err = NewOptIAPrefix(net.IPNet{IP: net.ParseIP("2001:db8:1::"), Mask: net.CIDRMask(24, 32)}, time.Hour, time.Hour)
+	require.Error(t, err)
+	// IPv4 prefix.
+	_, err = NewOptIAPrefix(net.IPNet{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(120, 128)}, time.Hour, time.Hour)
+	require.Error(t, err)
+}
+
+func TestOptIAPrefixValidate(t *testing.T) {
+	opt, err := NewOptIAPrefix(net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(56, 128)}, time.Hour, 2*time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, opt.Validate())
+
+	opt.SetPrefixLength(129)
+	require.Error(t, opt.Validate())
+
+	opt.SetPrefixLength(56)
+	opt.PreferredLifetime = 3 * 3600
+	require.Error(t, opt.Validate())
+}
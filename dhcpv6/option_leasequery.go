@@ -0,0 +1,404 @@
+package dhcpv6
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// OptClientData implements the Client Data option, which carries the binding
+// of a client in a LEASEQUERY-REPLY.
+//
+// This module defines the OptClientData structure.
+// https://www.ietf.org/rfc/rfc5007.txt
+type OptClientData struct {
+	Options Options
+}
+
+// Code returns the option code
+func (op *OptClientData) Code() OptionCode {
+	return OptionClientData
+}
+
+// ToBytes marshals this option according to RFC 5007, Section 4.1.2.2.
+func (op *OptClientData) ToBytes() []byte {
+	return op.Options.ToBytes()
+}
+
+func (op *OptClientData) String() string {
+	return fmt.Sprintf("OptClientData{options=%v}", op.Options)
+}
+
+// Validate validates the options of the client's binding.
+func (op *OptClientData) Validate() error {
+	return op.Options.Validate()
+}
+
+// ClientID returns the DUID of the client, if present.
+func (op *OptClientData) ClientID() (Duid, bool) {
+	cid, ok := op.Options.GetOne(OptionClientID).(*OptClientId)
+	if !ok {
+		return Duid{}, false
+	}
+	return cid.Cid, true
+}
+
+// Addresses returns the addresses bound to the client.
+func (op *OptClientData) Addresses() []*OptIAAddress {
+	var addrs []*OptIAAddress
+	for _, opt := range op.Options.Get(OptionIAAddr) {
+		if addr, ok := opt.(*OptIAAddress); ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// Prefixes returns the prefixes delegated to the client.
+func (op *OptClientData) Prefixes() []*OptIAPrefix {
+	var prefixes []*OptIAPrefix
+	for _, opt := range op.Options.Get(OptionIAPrefix) {
+		if p, ok := opt.(*OptIAPrefix); ok {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// CLTTime returns the time elapsed since the server last communicated with
+// the client, if present.
+func (op *OptClientData) CLTTime() (time.Duration, bool) {
+	clt, ok := op.Options.GetOne(OptionCLTTime).(*OptCLTTime)
+	if !ok {
+		return 0, false
+	}
+	return clt.Time, true
+}
+
+// ParseOptClientData builds an OptClientData structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func ParseOptClientData(data []byte) (*OptClientData, error) {
+	var opt OptClientData
+	if err := opt.Options.FromBytes(data); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+// OptCLTTime implements the Client Last Transaction Time option.
+//
+// This module defines the OptCLTTime structure.
+// https://www.ietf.org/rfc/rfc5007.txt
+type OptCLTTime struct {
+	// Time is the time elapsed since the server last communicated with the
+	// client, with a precision of one second.
+	Time time.Duration
+}
+
+// Code returns the option code
+func (op *OptCLTTime) Code() OptionCode {
+	return OptionCLTTime
+}
+
+// ToBytes marshals this option according to RFC 5007, Section 4.1.2.3.
+func (op *OptCLTTime) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write32(uint32(op.Time / time.Second))
+	return buf.Data()
+}
+
+func (op *OptCLTTime) String() string {
+	return fmt.Sprintf("OptCLTTime{time=%v}", op.Time)
+}
+
+// ParseOptCLTTime builds an OptCLTTime structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptCLTTime(data []byte) (*OptCLTTime, error) {
+	var opt OptCLTTime
+	buf := uio.NewBigEndianBuffer(data)
+	opt.Time = time.Duration(buf.Read32()) * time.Second
+	return &opt, buf.FinError()
+}
+
+// OptLQRelayData implements the LQ Relay Data option, which carries the
+// Relay-Forward message the server last received from the client.
+//
+// This module defines the OptLQRelayData structure.
+// https://www.ietf.org/rfc/rfc5007.txt
+type OptLQRelayData struct {
+	// PeerAddr is the address of the relay agent the message was received
+	// from.
+	PeerAddr     net.IP
+	RelayMessage *RelayMessage
+}
+
+// Code returns the option code
+func (op *OptLQRelayData) Code() OptionCode {
+	return OptionLQRelayData
+}
+
+// ToBytes marshals this option according to RFC 5007, Section 4.1.2.4.
+func (op *OptLQRelayData) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.WriteBytes(op.PeerAddr.To16())
+	if op.RelayMessage != nil {
+		buf.WriteBytes(op.RelayMessage.ToBytes())
+	}
+	return buf.Data()
+}
+
+func (op *OptLQRelayData) String() string {
+	return fmt.Sprintf("OptLQRelayData{peeraddr=%v, relaymsg=%v}", op.PeerAddr, op.RelayMessage)
+}
+
+// ParseOptLQRelayData builds an OptLQRelayData structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func ParseOptLQRelayData(data []byte) (*OptLQRelayData, error) {
+	var opt OptLQRelayData
+	buf := uio.NewBigEndianBuffer(data)
+	opt.PeerAddr = net.IP(buf.CopyN(net.IPv6len))
+	if err := buf.Error(); err != nil {
+		return nil, err
+	}
+	relay, err := RelayMessageFromBytes(buf.ReadAll())
+	if err != nil {
+		return nil, err
+	}
+	opt.RelayMessage = relay
+	return &opt, nil
+}
+
+// OptLQClientLink implements the LQ Client Link option, which lists the links
+// on which a client has bindings.
+//
+// This module defines the OptLQClientLink structure.
+// https://www.ietf.org/rfc/rfc5007.txt
+type OptLQClientLink struct {
+	LinkAddrs []net.IP
+}
+
+// Code returns the option code
+func (op *OptLQClientLink) Code() OptionCode {
+	return OptionLQClientLink
+}
+
+// ToBytes marshals this option according to RFC 5007, Section 4.1.2.5.
+func (op *OptLQClientLink) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, addr := range op.LinkAddrs {
+		buf.WriteBytes(addr.To16())
+	}
+	return buf.Data()
+}
+
+func (op *OptLQClientLink) String() string {
+	return fmt.Sprintf("OptLQClientLink{linkaddrs=%v}", op.LinkAddrs)
+}
+
+// ParseOptLQClientLink builds an OptLQClientLink structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func ParseOptLQClientLink(data []byte) (*OptLQClientLink, error) {
+	if len(data)%net.IPv6len != 0 {
+		return nil, fmt.Errorf("LQ Client Link length must be a multiple of %d, got %d", net.IPv6len, len(data))
+	}
+	var opt OptLQClientLink
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(net.IPv6len) {
+		opt.LinkAddrs = append(opt.LinkAddrs, buf.CopyN(net.IPv6len))
+	}
+	return &opt, buf.FinError()
+}
+
+// LQQueryType is the query-type of an LQ Query option.
+type LQQueryType uint8
+
+// Query types defined by RFC 5007, Section 4.1.2.1, and RFC 5460, Section
+// 5.3.
+const (
+	LQQueryByAddress     LQQueryType = 1
+	LQQueryByClientID    LQQueryType = 2
+	LQQueryByRelayID     LQQueryType = 3
+	LQQueryByLinkAddress LQQueryType = 4
+	LQQueryByRemoteID    LQQueryType = 5
+)
+
+var lqQueryTypeToString = map[LQQueryType]string{
+	LQQueryByAddress:     "QUERY_BY_ADDRESS",
+	LQQueryByClientID:    "QUERY_BY_CLIENTID",
+	LQQueryByRelayID:     "QUERY_BY_RELAY_ID",
+	LQQueryByLinkAddress: "QUERY_BY_LINK_ADDRESS",
+	LQQueryByRemoteID:    "QUERY_BY_REMOTE_ID",
+}
+
+func (t LQQueryType) String() string {
+	if s, ok := lqQueryTypeToString[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown (%d)", uint8(t))
+}
+
+// lqQueryOption is the query option required by each query type.
+var lqQueryOption = map[LQQueryType]OptionCode{
+	LQQueryByAddress:  OptionIAAddr,
+	LQQueryByClientID: OptionClientID,
+	LQQueryByRelayID:  OptionRelayID,
+	LQQueryByRemoteID: OptionRemoteID,
+}
+
+// OptLQQuery implements the LQ Query option, which carries the query of a
+// LEASEQUERY message.
+//
+// This module defines the OptLQQuery structure.
+// https://www.ietf.org/rfc/rfc5007.txt
+type OptLQQuery struct {
+	QueryType LQQueryType
+	// LinkAddr is the link the query applies to, or the unspecified
+	// address for all links.
+	LinkAddr net.IP
+	// Options are the query options, e.g. the address or the Client ID
+	// queried for.
+	Options Options
+}
+
+// Code returns the option code
+func (op *OptLQQuery) Code() OptionCode {
+	return OptionLQQuery
+}
+
+// ToBytes marshals this option according to RFC 5007, Section 4.1.2.1.
+func (op *OptLQQuery) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(uint8(op.QueryType))
+	linkAddr := op.LinkAddr
+	if linkAddr == nil {
+		linkAddr = net.IPv6unspecified
+	}
+	buf.WriteBytes(linkAddr.To16())
+	buf.WriteBytes(op.Options.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptLQQuery) String() string {
+	return fmt.Sprintf("OptLQQuery{querytype=%s, linkaddr=%v, options=%v}", op.QueryType, op.LinkAddr, op.Options)
+}
+
+// Validate checks that the query options carry what the query type requires:
+// an IA Address option for QUERY_BY_ADDRESS, a Client ID option for
+// QUERY_BY_CLIENTID, a Relay ID option for QUERY_BY_RELAY_ID and a Remote ID
+// option for QUERY_BY_REMOTE_ID. QUERY_BY_LINK_ADDRESS requires a link
+// address instead.
+func (op *OptLQQuery) Validate() error {
+	if op.QueryType == LQQueryByLinkAddress {
+		if op.LinkAddr == nil || op.LinkAddr.IsUnspecified() {
+			return fmt.Errorf("%s requires a link address", op.QueryType)
+		}
+	} else if code, ok := lqQueryOption[op.QueryType]; !ok {
+		return fmt.Errorf("unknown query type %s", op.QueryType)
+	} else if op.Options.GetOne(code) == nil {
+		return fmt.Errorf("%s requires an %s query option", op.QueryType, code)
+	}
+	if op.LinkAddr != nil && (op.LinkAddr.To16() == nil || op.LinkAddr.To4() != nil) {
+		return fmt.Errorf("invalid link address %v", op.LinkAddr)
+	}
+	return op.Options.Validate()
+}
+
+// ParseOptLQQuery builds an OptLQQuery structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptLQQuery(data []byte) (*OptLQQuery, error) {
+	var opt OptLQQuery
+	buf := uio.NewBigEndianBuffer(data)
+	opt.QueryType = LQQueryType(buf.Read8())
+	opt.LinkAddr = net.IP(buf.CopyN(net.IPv6len))
+	if err := buf.Error(); err != nil {
+		return nil, err
+	}
+	if err := opt.Options.FromBytes(buf.ReadAll()); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+// NewLeasequery creates a LEASEQUERY message carrying query. The requestor's
+// Client ID, which RFC 5007, Section 4.1.2, requires, can be added with the
+// WithClientID modifier.
+//
+// An error is returned if query is not valid. See OptLQQuery.Validate.
+func NewLeasequery(query *OptLQQuery, modifiers ...Modifier) (*Message, error) {
+	if query == nil {
+		return nil, errors.New("LQ Query cannot be nil")
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	m, err := NewMessage()
+	if err != nil {
+		return nil, err
+	}
+	m.MessageType = MessageTypeLeaseQuery
+	m.AddOption(query)
+	for _, mod := range modifiers {
+		mod(m)
+	}
+	return m, nil
+}
+
+// NewLeasequeryByAddress creates a LEASEQUERY message querying the binding of
+// the address ip on all links. See NewLeasequery.
+func NewLeasequeryByAddress(ip net.IP, modifiers ...Modifier) (*Message, error) {
+	if ip.To16() == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 address %v", ip)
+	}
+	return NewLeasequery(&OptLQQuery{
+		QueryType: LQQueryByAddress,
+		LinkAddr:  net.IPv6unspecified,
+		Options:   Options{&OptIAAddress{IPv6Addr: ip}},
+	}, modifiers...)
+}
+
+// NewLeasequeryByClientID creates a LEASEQUERY message querying the bindings
+// of the client with the given DUID on all links. See NewLeasequery.
+func NewLeasequeryByClientID(duid Duid, modifiers ...Modifier) (*Message, error) {
+	return NewLeasequery(&OptLQQuery{
+		QueryType: LQQueryByClientID,
+		LinkAddr:  net.IPv6unspecified,
+		Options:   Options{&OptClientId{Cid: duid}},
+	}, modifiers...)
+}
+
+// LQQuery returns the LQ Query option of the message, or nil.
+func (m *Message) LQQuery() *OptLQQuery {
+	opt, _ := m.GetOneOption(OptionLQQuery).(*OptLQQuery)
+	return opt
+}
+
+// ClientData returns the Client Data options of the message, i.e. the client
+// bindings of a LEASEQUERY-REPLY or LEASEQUERY-DATA.
+func (m *Message) ClientData() []*OptClientData {
+	var data []*OptClientData
+	for _, opt := range m.GetOption(OptionClientData) {
+		if cd, ok := opt.(*OptClientData); ok {
+			data = append(data, cd)
+		}
+	}
+	return data
+}
+
+// LQRelayData returns the LQ Relay Data option of the message, or nil.
+func (m *Message) LQRelayData() *OptLQRelayData {
+	opt, _ := m.GetOneOption(OptionLQRelayData).(*OptLQRelayData)
+	return opt
+}
+
+// LQClientLinks returns the link addresses of the LQ Client Link option of
+// the message, if any.
+func (m *Message) LQClientLinks() []net.IP {
+	opt, ok := m.GetOneOption(OptionLQClientLink).(*OptLQClientLink)
+	if !ok {
+		return nil
+	}
+	return opt.LinkAddrs
+}
@@ -0,0 +1,206 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/iana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptClientData(t *testing.T) {
+	data := []byte{
+		0, 1, 0, 10, // Client ID
+		0, 3, 0, 1, 0, 1, 2, 3, 4, 5,
+		0, 5, 0, 24, // IA Address
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0, 0, 0x0e, 0x10, // preferred lifetime
+		0, 0, 0x1c, 0x20, // valid lifetime
+		0, 26, 0, 25, // IA Prefix
+		0, 0, 0x0e, 0x10,
+		0, 0, 0x1c, 0x20,
+		56,
+		0x20, 0x01, 0x0d, 0xb8, 0xab, 0xcd, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 46, 0, 4, // CLT Time
+		0, 0, 0, 120,
+	}
+	opt, err := ParseOptClientData(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionClientData, opt.Code())
+
+	cid, ok := opt.ClientID()
+	require.True(t, ok)
+	require.Equal(t, Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}, cid)
+	require.Len(t, opt.Addresses(), 1)
+	require.Equal(t, net.ParseIP("2001:db8::1"), opt.Addresses()[0].IPv6Addr)
+	require.Len(t, opt.Prefixes(), 1)
+	require.Equal(t, uint8(56), opt.Prefixes()[0].PrefixLength())
+	clt, ok := opt.CLTTime()
+	require.True(t, ok)
+	require.Equal(t, 2*time.Minute, clt)
+	require.NoError(t, opt.Validate())
+
+	require.Equal(t, data, opt.ToBytes())
+
+	_, ok = (&OptClientData{}).CLTTime()
+	require.False(t, ok)
+	_, ok = (&OptClientData{}).ClientID()
+	require.False(t, ok)
+}
+
+func TestParseOptCLTTime(t *testing.T) {
+	opt, err := ParseOptCLTTime([]byte{0, 1, 0x51, 0x80})
+	require.NoError(t, err)
+	require.Equal(t, 24*time.Hour, opt.Time)
+	require.Equal(t, []byte{0, 1, 0x51, 0x80}, opt.ToBytes())
+	require.Contains(t, opt.String(), "24h0m0s")
+
+	_, err = ParseOptCLTTime([]byte{0, 1, 0x51})
+	require.Error(t, err)
+}
+
+func TestOptLQRelayDataRoundTrip(t *testing.T) {
+	inner := &Message{MessageType: MessageTypeSolicit, TransactionID: TransactionID{1, 2, 3}}
+	relay, err := EncapsulateRelay(inner, MessageTypeRelayForward, net.ParseIP("2001:db8::"), net.ParseIP("fe80::1"))
+	require.NoError(t, err)
+	opt := &OptLQRelayData{PeerAddr: net.ParseIP("2001:db8::fe"), RelayMessage: relay}
+
+	got, err := ParseOptLQRelayData(opt.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, opt.PeerAddr, got.PeerAddr)
+	require.Equal(t, relay.ToBytes(), got.RelayMessage.ToBytes())
+	msg, err := got.RelayMessage.GetInnerMessage()
+	require.NoError(t, err)
+	require.Equal(t, inner.TransactionID, msg.TransactionID)
+}
+
+func TestParseOptLQRelayDataInvalid(t *testing.T) {
+	// Too short for the peer address.
+	_, err := ParseOptLQRelayData([]byte{0x20, 0x01, 0x0d, 0xb8})
+	require.Error(t, err)
+
+	// Not a relay message.
+	data := append([]byte(net.ParseIP("2001:db8::fe").To16()), byte(MessageTypeSolicit), 1, 2, 3)
+	_, err = ParseOptLQRelayData(data)
+	require.Error(t, err)
+}
+
+func TestParseOptLQClientLink(t *testing.T) {
+	data := append([]byte(net.ParseIP("2001:db8:1::").To16()), net.ParseIP("2001:db8:2::").To16()...)
+	opt, err := ParseOptLQClientLink(data)
+	require.NoError(t, err)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8:1::"), net.ParseIP("2001:db8:2::")}, opt.LinkAddrs)
+	require.Equal(t, data, opt.ToBytes())
+
+	_, err = ParseOptLQClientLink(data[:20])
+	require.Error(t, err)
+}
+
+func TestLeasequeryReplyAccessors(t *testing.T) {
+	m := &Message{MessageType: MessageTypeLeaseQueryReply}
+	require.Empty(t, m.ClientData())
+	require.Nil(t, m.LQRelayData())
+	require.Nil(t, m.LQClientLinks())
+
+	m.AddOption(&OptClientData{Options: Options{
+		&OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: 3600, ValidLifetime: 7200},
+		&OptCLTTime{Time: time.Minute},
+	}})
+	m.AddOption(&OptLQClientLink{LinkAddrs: []net.IP{net.ParseIP("2001:db8:1::")}})
+
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	cd := parsed.ClientData()
+	require.Len(t, cd, 1)
+	require.Equal(t, net.ParseIP("2001:db8::1"), cd[0].Addresses()[0].IPv6Addr)
+	clt, ok := cd[0].CLTTime()
+	require.True(t, ok)
+	require.Equal(t, time.Minute, clt)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8:1::")}, parsed.LQClientLinks())
+}
+
+func TestParseOptLQQuery(t *testing.T) {
+	data := []byte{
+		1,                                              // QUERY_BY_ADDRESS
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, // link-address
+		0, 5, 0, 24, // IA Address
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+	}
+	opt, err := ParseOptLQQuery(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionLQQuery, opt.Code())
+	require.Equal(t, LQQueryByAddress, opt.QueryType)
+	require.True(t, opt.LinkAddr.IsUnspecified())
+	require.NoError(t, opt.Validate())
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "QUERY_BY_ADDRESS")
+
+	_, err = ParseOptLQQuery(data[:10])
+	require.Error(t, err)
+}
+
+func TestOptLQQueryValidate(t *testing.T) {
+	cid := &OptClientId{Cid: Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}}
+	for _, tt := range []struct {
+		name  string
+		query OptLQQuery
+		valid bool
+	}{
+		{"by client ID", OptLQQuery{QueryType: LQQueryByClientID, Options: Options{cid}}, true},
+		{"by client ID without Client ID", OptLQQuery{QueryType: LQQueryByClientID}, false},
+		{"by address with Client ID", OptLQQuery{QueryType: LQQueryByAddress, Options: Options{cid}}, false},
+		{"by link address", OptLQQuery{QueryType: LQQueryByLinkAddress, LinkAddr: net.ParseIP("2001:db8::")}, true},
+		{"by link address without link", OptLQQuery{QueryType: LQQueryByLinkAddress, LinkAddr: net.IPv6unspecified}, false},
+		{"by remote ID", OptLQQuery{QueryType: LQQueryByRemoteID, Options: Options{&OptRemoteId{}}}, true},
+		{"IPv4 link address", OptLQQuery{QueryType: LQQueryByClientID, LinkAddr: net.IPv4(192, 0, 2, 1), Options: Options{cid}}, false},
+		{"unknown type", OptLQQuery{QueryType: 42, Options: Options{cid}}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.query.Validate()
+			if tt.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestNewLeasequery(t *testing.T) {
+	requestor := Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 6}}
+
+	m, err := NewLeasequeryByAddress(net.ParseIP("2001:db8::1"), WithClientID(requestor))
+	require.NoError(t, err)
+	require.Equal(t, MessageTypeLeaseQuery, m.MessageType)
+	require.NotNil(t, m.GetOneOption(OptionClientID))
+	b, err := m.MarshalBinary()
+	require.NoError(t, err)
+	parsed, err := MessageFromBytes(b)
+	require.NoError(t, err)
+	q := parsed.LQQuery()
+	require.NotNil(t, q)
+	require.Equal(t, LQQueryByAddress, q.QueryType)
+	require.True(t, q.Options.GetOne(OptionIAAddr).(*OptIAAddress).IPv6Addr.Equal(net.ParseIP("2001:db8::1")))
+
+	client := Duid{Type: DUID_LL, HwType: iana.HWTypeEthernet, LinkLayerAddr: net.HardwareAddr{0, 1, 2, 3, 4, 5}}
+	m, err = NewLeasequeryByClientID(client, WithClientID(requestor))
+	require.NoError(t, err)
+	b, err = m.MarshalBinary()
+	require.NoError(t, err)
+	parsed, err = MessageFromBytes(b)
+	require.NoError(t, err)
+	q = parsed.LQQuery()
+	require.Equal(t, LQQueryByClientID, q.QueryType)
+	require.Equal(t, client, q.Options.GetOne(OptionClientID).(*OptClientId).Cid)
+
+	_, err = NewLeasequeryByAddress(net.IPv4(192, 0, 2, 1))
+	require.Error(t, err)
+	_, err = NewLeasequery(&OptLQQuery{QueryType: LQQueryByRelayID})
+	require.Error(t, err)
+	_, err = NewLeasequery(nil)
+	require.Error(t, err)
+	require.Nil(t, (&Message{}).LQQuery())
+}
@@ -6,6 +6,10 @@ import (
 	"github.com/u-root/u-root/pkg/uio"
 )
 
+// iaHeaderLen is the length of the IAID, T1 and T2 fields of IA_NA and IA_PD
+// options, which precede their options.
+const iaHeaderLen = 12
+
 // OptIANA implements the identity association for non-temporary addresses
 // option.
 //
@@ -37,6 +41,15 @@ func (op *OptIANA) String() string {
 		op.IaId, op.T1, op.T2, op.Options)
 }
 
+// Validate checks the timers of the IA_NA with ValidateIANATimers, and
+// validates its options.
+func (op *OptIANA) Validate() error {
+	if err := ValidateIANATimers(*op); err != nil {
+		return err
+	}
+	return op.Options.Validate()
+}
+
 // AddOption adds an option at the end of the IA_NA options
 func (op *OptIANA) AddOption(opt Option) {
 	op.Options.Add(opt)
@@ -57,12 +70,59 @@ func (op *OptIANA) DelOption(code OptionCode) {
 // input data does not include option code and length bytes.
 func ParseOptIANA(data []byte) (*OptIANA, error) {
 	var opt OptIANA
+	if len(data) < iaHeaderLen {
+		return nil, fmt.Errorf("IA_NA too short: got %d bytes, want at least %d", len(data), iaHeaderLen)
+	}
 	buf := uio.NewBigEndianBuffer(data)
 	buf.ReadBytes(opt.IaId[:])
 	opt.T1 = buf.Read32()
 	opt.T2 = buf.Read32()
 	if err := opt.Options.FromBytes(buf.ReadAll()); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("IA_NA %x: %v", opt.IaId, err)
 	}
 	return &opt, buf.FinError()
 }
+
+// infiniteLifetime is the value of timers and lifetimes meaning infinity, as
+// defined by RFC 3315, Section 9.
+const infiniteLifetime = 0xffffffff
+
+// shortestPreferredLifetime returns the shortest preferred lifetime of the
+// addresses in the IA_NA, and false if it has no address.
+func (op *OptIANA) shortestPreferredLifetime() (uint32, bool) {
+	var (
+		shortest uint32
+		found    bool
+	)
+	for _, opt := range op.Options.Get(OptionIAAddr) {
+		addr, ok := opt.(*OptIAAddress)
+		if !ok {
+			continue
+		}
+		if !found || addr.PreferredLifetime < shortest {
+			shortest = addr.PreferredLifetime
+			found = true
+		}
+	}
+	return shortest, found
+}
+
+// ValidateIANATimers checks that the T1 and T2 timers of an IA_NA are
+// consistent, i.e. that T1 <= T2 <= the shortest preferred lifetime of its
+// addresses, as required by RFC 3315, Section 22.4. Zero timers, which leave
+// renewal to the client's discretion, and infinite values are valid.
+func ValidateIANATimers(opt OptIANA) error {
+	if opt.T1 != 0 && opt.T2 != 0 && opt.T1 > opt.T2 {
+		return fmt.Errorf("IA_NA T1 (%d) is greater than T2 (%d)", opt.T1, opt.T2)
+	}
+	pref, ok := opt.shortestPreferredLifetime()
+	if !ok || pref == infiniteLifetime {
+		return nil
+	}
+	for _, t := range []uint32{opt.T1, opt.T2} {
+		if t != infiniteLifetime && t > pref {
+			return fmt.Errorf("IA_NA timer %d is greater than the preferred lifetime %d", t, pref)
+		}
+	}
+	return nil
+}
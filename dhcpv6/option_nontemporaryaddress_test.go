@@ -40,6 +40,33 @@ func TestOptIANAParseOptIANAInvalidOptions(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestOptIANAParseOptIANATruncatedOption(t *testing.T) {
+	data := []byte{
+		1, 0, 0, 0, // IAID
+		0, 0, 0, 1, // T1
+		0, 0, 0, 2, // T2
+		0, 5, 0, 0x18, // IAAddr, declaring 24 bytes
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, // truncated from here
+	}
+	_, err := ParseOptIANA(data)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "IA_NA 01000000")
+	require.Contains(t, err.Error(), "declares 24 bytes, only 8 left")
+
+	// The IA_NA is truncated within a message: the bytes of the next
+	// option are not taken for its own.
+	msg := []byte{byte(MessageTypeReply), 0xaa, 0xbb, 0xcc}
+	msg = append(msg, 0, 3, 0, byte(len(data)))
+	msg = append(msg, data...)
+	msg = append(msg, 0, 14, 0, 0) // Rapid Commit
+	_, err = MessageFromBytes(msg)
+	require.Error(t, err)
+
+	// Trailing bytes too short for an option header.
+	_, err = ParseOptIANA([]byte{1, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 2, 0, 5})
+	require.Error(t, err)
+}
+
 func TestOptIANAGetOneOption(t *testing.T) {
 	oaddr := &OptIAAddress{
 		IPv6Addr: net.ParseIP("::1"),
@@ -133,3 +160,31 @@ func TestOptIANAString(t *testing.T) {
 		"String() should return a list of options",
 	)
 }
+
+func TestValidateIANATimers(t *testing.T) {
+	addr := func(pref uint32) Option {
+		return &OptIAAddress{IPv6Addr: net.ParseIP("2001:db8::1"), PreferredLifetime: pref, ValidLifetime: pref * 2}
+	}
+	for _, tt := range []struct {
+		desc    string
+		opt     OptIANA
+		wantErr bool
+	}{
+		{"valid", OptIANA{T1: 1800, T2: 2880, Options: Options{addr(3600)}}, false},
+		{"zero timers", OptIANA{Options: Options{addr(3600)}}, false},
+		{"no address", OptIANA{T1: 1800, T2: 2880}, false},
+		{"infinite", OptIANA{T1: infiniteLifetime, T2: infiniteLifetime, Options: Options{addr(infiniteLifetime)}}, false},
+		{"T1 greater than T2", OptIANA{T1: 2880, T2: 1800, Options: Options{addr(3600)}}, true},
+		{"T2 greater than preferred", OptIANA{T1: 1800, T2: 4000, Options: Options{addr(3600)}}, true},
+		{"T2 greater than shortest preferred", OptIANA{T1: 1800, T2: 2880, Options: Options{addr(3600), addr(2000)}}, true},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			err := ValidateIANATimers(tt.opt)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
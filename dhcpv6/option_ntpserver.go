@@ -0,0 +1,91 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// NTP server suboption codes, as defined by RFC 5908, Section 4.
+const (
+	NTPSuboptionSrvAddr OptionCode = 1
+	NTPSuboptionMCAddr  OptionCode = 2
+	NTPSuboptionSrvFQDN OptionCode = 3
+)
+
+// OptNTPServer implements the NTP server option.
+//
+// This module defines the OptNTPServer structure.
+// https://tools.ietf.org/html/rfc5908
+type OptNTPServer struct {
+	Suboptions Options
+}
+
+// Code returns the option code
+func (op *OptNTPServer) Code() OptionCode {
+	return OptionNTPServer
+}
+
+// ToBytes serializes the option and returns it as a sequence of bytes
+func (op *OptNTPServer) ToBytes() []byte {
+	return op.Suboptions.ToBytes()
+}
+
+func (op *OptNTPServer) String() string {
+	return fmt.Sprintf("OptNTPServer{suboptions=%v}", op.Suboptions)
+}
+
+// addrs returns the addresses carried by the suboptions with the given code.
+func (op *OptNTPServer) addrs(code OptionCode) []net.IP {
+	var ips []net.IP
+	for _, so := range op.Suboptions.Get(code) {
+		data := so.ToBytes()
+		if len(data) == net.IPv6len {
+			ips = append(ips, net.IP(data))
+		}
+	}
+	return ips
+}
+
+// ServerAddrs returns the unicast NTP server addresses.
+func (op *OptNTPServer) ServerAddrs() []net.IP {
+	return op.addrs(NTPSuboptionSrvAddr)
+}
+
+// MulticastAddrs returns the multicast NTP addresses.
+func (op *OptNTPServer) MulticastAddrs() []net.IP {
+	return op.addrs(NTPSuboptionMCAddr)
+}
+
+// FQDNs returns the NTP server FQDNs. Suboptions that do not hold a valid
+// FQDN are skipped.
+func (op *OptNTPServer) FQDNs() []string {
+	var names []string
+	for _, so := range op.Suboptions.Get(NTPSuboptionSrvFQDN) {
+		labels, err := rfc1035label.FromBytes(so.ToBytes())
+		if err != nil || len(labels.Labels) != 1 {
+			continue
+		}
+		names = append(names, labels.Labels[0])
+	}
+	return names
+}
+
+// ParseOptNTPServer builds an OptNTPServer structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptNTPServer(data []byte) (*OptNTPServer, error) {
+	var opt OptNTPServer
+	buf := uio.NewBigEndianBuffer(data)
+	if err := opt.Suboptions.FromBytesWithParser(buf.ReadAll(), ntpParseSuboption); err != nil {
+		return nil, err
+	}
+	return &opt, buf.FinError()
+}
+
+// ntpParseSuboption builds a GenericOption from a slice of bytes. Suboption
+// codes overlap with RFC standard option codes, so ParseOption cannot be used.
+func ntpParseSuboption(code OptionCode, data []byte) (Option, error) {
+	return &OptionGeneric{OptionCode: code, OptionData: append([]byte(nil), data...)}, nil
+}
@@ -0,0 +1,49 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptNTPServer(t *testing.T) {
+	data := []byte{
+		0, 1, // NTPSuboptionSrvAddr
+		0, 16, // length
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+		0, 2, // NTPSuboptionMCAddr
+		0, 16, // length
+		0xff, 0x02, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+		0, 1, // NTPSuboptionSrvAddr
+		0, 2, // bogus length
+		0xaa, 0xbb,
+	}
+	opt, err := ParseOptNTPServer(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionNTPServer, opt.Code())
+	require.Len(t, opt.Suboptions, 3)
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::1")}, opt.ServerAddrs())
+	require.Equal(t, []net.IP{net.ParseIP("ff02::1")}, opt.MulticastAddrs())
+	require.Equal(t, data, opt.ToBytes())
+}
+
+func TestParseOptNTPServerShort(t *testing.T) {
+	_, err := ParseOptNTPServer([]byte{0, 1, 0, 16, 0x20})
+	require.Error(t, err)
+}
+
+func TestOptNTPServerFQDNs(t *testing.T) {
+	fqdn := (&rfc1035label.Labels{Labels: []string{"ntp.example.com"}}).ToBytes()
+	opt := &OptNTPServer{Suboptions: Options{
+		&OptionGeneric{OptionCode: NTPSuboptionSrvFQDN, OptionData: fqdn},
+		&OptionGeneric{OptionCode: NTPSuboptionSrvAddr, OptionData: net.ParseIP("2001:db8::123")},
+		// Not a valid FQDN.
+		&OptionGeneric{OptionCode: NTPSuboptionSrvFQDN, OptionData: []byte{5, 'a'}},
+	}}
+	parsed, err := ParseOptNTPServer(opt.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, []string{"ntp.example.com"}, parsed.FQDNs())
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::123")}, parsed.ServerAddrs())
+}
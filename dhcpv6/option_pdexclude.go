@@ -0,0 +1,125 @@
+package dhcpv6
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// OptPDExclude implements the PD Exclude option, which a server includes in
+// an IA Prefix option to exclude a sub-prefix of the delegated prefix.
+//
+// The excluded prefix is encoded relative to the delegated prefix: only the
+// bits following the delegated prefix length are present. Use
+// NewOptPDExclude and ExcludedPrefix to convert from and to a full prefix.
+//
+// This module defines the OptPDExclude structure.
+// https://www.ietf.org/rfc/rfc6603.txt
+type OptPDExclude struct {
+	// PrefixLength is the length of the excluded prefix.
+	PrefixLength uint8
+	// SubnetID holds the bits of the excluded prefix past the delegated
+	// prefix length, left-aligned and padded with zeros to a whole number
+	// of bytes.
+	SubnetID []byte
+}
+
+// NewOptPDExclude builds an OptPDExclude excluding the excluded prefix from
+// the delegated one.
+func NewOptPDExclude(delegated, excluded net.IPNet) (*OptPDExclude, error) {
+	dlen, dbits := delegated.Mask.Size()
+	elen, ebits := excluded.Mask.Size()
+	if dbits != 8*net.IPv6len || ebits != 8*net.IPv6len {
+		return nil, errors.New("prefixes must be IPv6 prefixes")
+	}
+	if elen <= dlen {
+		return nil, fmt.Errorf("excluded prefix length %d must be greater than delegated prefix length %d", elen, dlen)
+	}
+	if !delegated.Contains(excluded.IP) {
+		return nil, fmt.Errorf("excluded prefix %v is not part of delegated prefix %v", &excluded, &delegated)
+	}
+	ip := excluded.IP.To16()
+	op := &OptPDExclude{
+		PrefixLength: uint8(elen),
+		SubnetID:     make([]byte, subnetIDLen(dlen, elen)),
+	}
+	for i := 0; i < elen-dlen; i++ {
+		if getBit(ip, dlen+i) {
+			setBit(op.SubnetID, i)
+		}
+	}
+	return op, nil
+}
+
+// ExcludedPrefix returns the prefix excluded from delegated.
+func (op *OptPDExclude) ExcludedPrefix(delegated net.IPNet) (net.IPNet, error) {
+	dlen, bits := delegated.Mask.Size()
+	ip := delegated.IP.To16()
+	if bits != 8*net.IPv6len || ip == nil {
+		return net.IPNet{}, errors.New("delegated prefix must be an IPv6 prefix")
+	}
+	elen := int(op.PrefixLength)
+	if elen <= dlen || elen > 8*net.IPv6len {
+		return net.IPNet{}, fmt.Errorf("invalid excluded prefix length %d for delegated prefix length %d", elen, dlen)
+	}
+	if len(op.SubnetID) != subnetIDLen(dlen, elen) {
+		return net.IPNet{}, fmt.Errorf("subnet ID must be %d bytes long, got %d", subnetIDLen(dlen, elen), len(op.SubnetID))
+	}
+	mask := net.CIDRMask(elen, 8*net.IPv6len)
+	excluded := ip.Mask(delegated.Mask)
+	for i := 0; i < elen-dlen; i++ {
+		if getBit(op.SubnetID, i) {
+			setBit(excluded, dlen+i)
+		}
+	}
+	return net.IPNet{IP: excluded, Mask: mask}, nil
+}
+
+// subnetIDLen returns the length in bytes of the IPv6 subnet ID field, as
+// defined by RFC 6603, Section 4.2.
+func subnetIDLen(delegatedLen, excludedLen int) int {
+	return (excludedLen-delegatedLen-1)/8 + 1
+}
+
+func getBit(b []byte, i int) bool {
+	return b[i/8]&(0x80>>uint(i%8)) != 0
+}
+
+func setBit(b []byte, i int) {
+	b[i/8] |= 0x80 >> uint(i%8)
+}
+
+func (op *OptPDExclude) Code() OptionCode {
+	return OptionPDExclude
+}
+
+// ToBytes marshals this option according to RFC 6603, Section 4.2.
+func (op *OptPDExclude) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(op.PrefixLength)
+	buf.WriteBytes(op.SubnetID)
+	return buf.Data()
+}
+
+func (op *OptPDExclude) String() string {
+	return fmt.Sprintf("OptPDExclude{prefixlength=%v, subnetid=%x}", op.PrefixLength, op.SubnetID)
+}
+
+// ParseOptPDExclude builds an OptPDExclude structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptPDExclude(data []byte) (*OptPDExclude, error) {
+	buf := uio.NewBigEndianBuffer(data)
+	var opt OptPDExclude
+	opt.PrefixLength = buf.Read8()
+	opt.SubnetID = buf.ReadAll()
+	if err := buf.FinError(); err != nil {
+		return nil, err
+	}
+	if len(opt.SubnetID) == 0 || len(opt.SubnetID) > net.IPv6len {
+		return nil, fmt.Errorf("invalid PD Exclude subnet ID length %d", len(opt.SubnetID))
+	}
+	opt.SubnetID = append([]byte(nil), opt.SubnetID...)
+	return &opt, nil
+}
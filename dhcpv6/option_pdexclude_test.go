@@ -0,0 +1,74 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustParseCIDR(t *testing.T, s string) net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return *n
+}
+
+func TestOptPDExclude(t *testing.T) {
+	for _, tt := range []struct {
+		delegated, excluded string
+		data                []byte
+	}{
+		// Byte-aligned: the subnet ID is the 8 bits after the /48.
+		{"2001:db8:1234::/48", "2001:db8:1234:ab00::/56", []byte{56, 0xab}},
+		// The excluded /64 is the first subnet of the /56.
+		{"2001:db8:1234:ab00::/56", "2001:db8:1234:ab00::/64", []byte{64, 0x00}},
+		// Not byte-aligned: bits 33 to 36, padded with zeros.
+		{"2001:db8::/33", "2001:db8:7800::/37", []byte{37, 0xf0}},
+		// Spanning several bytes: bits 30 to 63.
+		{"2001:db8::/30", "2001:dbb:ffff:fffe::/64", []byte{64, 0xff, 0xff, 0xff, 0xff, 0x80}},
+	} {
+		t.Run(tt.excluded, func(t *testing.T) {
+			delegated := mustParseCIDR(t, tt.delegated)
+			excluded := mustParseCIDR(t, tt.excluded)
+
+			opt, err := NewOptPDExclude(delegated, excluded)
+			require.NoError(t, err)
+			require.Equal(t, OptionPDExclude, opt.Code())
+			require.Equal(t, tt.data, opt.ToBytes())
+
+			parsed, err := ParseOptPDExclude(tt.data)
+			require.NoError(t, err)
+			require.Equal(t, opt, parsed)
+			got, err := parsed.ExcludedPrefix(delegated)
+			require.NoError(t, err)
+			require.Equal(t, excluded.String(), got.String())
+		})
+	}
+}
+
+func TestOptPDExcludeInvalid(t *testing.T) {
+	delegated := mustParseCIDR(t, "2001:db8:1234::/48")
+
+	// Not longer than the delegated prefix.
+	_, err := NewOptPDExclude(delegated, mustParseCIDR(t, "2001:db8::/32"))
+	require.Error(t, err)
+	// Outside of the delegated prefix.
+	_, err = NewOptPDExclude(delegated, mustParseCIDR(t, "2001:db8:5678:ab00::/56"))
+	require.Error(t, err)
+
+	_, err = ParseOptPDExclude([]byte{})
+	require.Error(t, err)
+	_, err = ParseOptPDExclude([]byte{64})
+	require.Error(t, err)
+
+	// The subnet ID length does not match the prefix lengths.
+	opt, err := ParseOptPDExclude([]byte{64, 0xab})
+	require.NoError(t, err)
+	_, err = opt.ExcludedPrefix(delegated)
+	require.Error(t, err)
+	// The excluded prefix is not longer than the delegated one.
+	opt, err = ParseOptPDExclude([]byte{48, 0xab})
+	require.NoError(t, err)
+	_, err = opt.ExcludedPrefix(delegated)
+	require.Error(t, err)
+}
@@ -36,6 +36,15 @@ func (op *OptIAForPrefixDelegation) String() string {
 		op.IaId, op.T1, op.T2, op.Options)
 }
 
+// Validate checks that T1 does not exceed T2, as required by RFC 3633,
+// Section 9, and validates the options of the IA_PD.
+func (op *OptIAForPrefixDelegation) Validate() error {
+	if op.T1 != 0 && op.T2 != 0 && op.T1 > op.T2 {
+		return fmt.Errorf("IA_PD T1 (%d) is greater than T2 (%d)", op.T1, op.T2)
+	}
+	return op.Options.Validate()
+}
+
 // GetOneOption will get an option of the give type from the Options field, if
 // it is present. It will return `nil` otherwise
 func (op *OptIAForPrefixDelegation) GetOneOption(code OptionCode) Option {
@@ -51,12 +60,15 @@ func (op *OptIAForPrefixDelegation) DelOption(code OptionCode) {
 // The input data does not include option code and length bytes.
 func ParseOptIAForPrefixDelegation(data []byte) (*OptIAForPrefixDelegation, error) {
 	var opt OptIAForPrefixDelegation
+	if len(data) < iaHeaderLen {
+		return nil, fmt.Errorf("IA_PD too short: got %d bytes, want at least %d", len(data), iaHeaderLen)
+	}
 	buf := uio.NewBigEndianBuffer(data)
 	buf.ReadBytes(opt.IaId[:])
 	opt.T1 = buf.Read32()
 	opt.T2 = buf.Read32()
 	if err := opt.Options.FromBytes(buf.ReadAll()); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("IA_PD %x: %v", opt.IaId, err)
 	}
 	return &opt, buf.FinError()
 }
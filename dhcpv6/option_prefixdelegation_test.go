@@ -51,6 +51,23 @@ func TestOptIAForPrefixDelegationParseOptIAForPrefixDelegationInvalidOptions(t *
 	require.Error(t, err)
 }
 
+func TestOptIAForPrefixDelegationParseTruncatedOption(t *testing.T) {
+	data := []byte{
+		1, 0, 0, 0, // IAID
+		0, 0, 0, 1, // T1
+		0, 0, 0, 2, // T2
+		0, 26, 0, 25, // IAPrefix, declaring 25 bytes
+		0, 0, 0, 2, 0, 0, 0, 4, // truncated from here
+	}
+	_, err := ParseOptIAForPrefixDelegation(data)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "IA_PD 01000000")
+
+	_, err = ParseOptIAForPrefixDelegation(data[:6])
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "too short")
+}
+
 func TestOptIAForPrefixDelegationGetOneOption(t *testing.T) {
 	buf := []byte{
 		0xaa, 0xbb, 0xcc, 0xdd, // preferredLifetime
@@ -161,3 +178,14 @@ func TestOptIAForPrefixDelegationString(t *testing.T) {
 		"String() should return a list of options",
 	)
 }
+
+func TestOptIAForPrefixDelegationValidate(t *testing.T) {
+	require.NoError(t, (&OptIAForPrefixDelegation{T1: 1800, T2: 2880}).Validate())
+	require.NoError(t, (&OptIAForPrefixDelegation{}).Validate())
+	require.Error(t, (&OptIAForPrefixDelegation{T1: 2880, T2: 1800}).Validate())
+
+	// Prefixes are validated too.
+	prefix := &OptIAPrefix{PreferredLifetime: 2, ValidLifetime: 1}
+	prefix.SetIPv6Prefix(net.ParseIP("2001:db8::"))
+	require.Error(t, (&OptIAForPrefixDelegation{Options: Options{prefix}}).Validate())
+}
@@ -31,12 +31,29 @@ func (op *OptRelayMsg) String() string {
 	return fmt.Sprintf("OptRelayMsg{relaymsg=%v}", op.relayMessage)
 }
 
+// Validate validates the relayed message.
+func (op *OptRelayMsg) Validate() error {
+	if op.relayMessage == nil {
+		return fmt.Errorf("relay message cannot be nil")
+	}
+	if v, ok := op.relayMessage.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
 // build an OptRelayMsg structure from a sequence of bytes.
 // The input data does not include option code and length bytes.
 func ParseOptRelayMsg(data []byte) (*OptRelayMsg, error) {
+	return parseOptRelayMsg(data, 1)
+}
+
+// parseOptRelayMsg parses a Relay Message option whose message is enclosed in
+// depth relay messages, including the one carrying the option.
+func parseOptRelayMsg(data []byte, depth int) (*OptRelayMsg, error) {
 	var err error
 	var opt OptRelayMsg
-	opt.relayMessage, err = FromBytes(data)
+	opt.relayMessage, err = fromBytes(data, depth)
 	if err != nil {
 		return nil, err
 	}
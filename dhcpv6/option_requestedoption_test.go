@@ -32,7 +32,7 @@ func TestOptRequestedOptionString(t *testing.T) {
 	require.Contains(
 		t,
 		opt.String(),
-		"unknown",
-		"String() should contain 'Unknown' for an illegal option",
+		"UNKNOWN(12345)",
+		"String() should contain 'UNKNOWN(n)' for an illegal option",
 	)
 }
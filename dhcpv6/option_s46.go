@@ -0,0 +1,425 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// This module defines the softwire (S46) options used to provision MAP-E,
+// MAP-T and Lightweight 4over6 CEs.
+// https://www.ietf.org/rfc/rfc7598.txt
+
+// S46RuleFlagFMR is the flag of an OptS46Rule that marks it as a Forwarding
+// Mapping Rule, in addition to being a Basic Mapping Rule.
+const S46RuleFlagFMR uint8 = 0x01
+
+// S46Options are the options encapsulated by the S46 container options.
+type S46Options struct {
+	Options Options
+}
+
+// Rules returns the mapping rules.
+func (s *S46Options) Rules() []*OptS46Rule {
+	var rules []*OptS46Rule
+	for _, opt := range s.Options.Get(OptionS46Rule) {
+		if r, ok := opt.(*OptS46Rule); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// BRs returns the addresses of the Border Relays.
+func (s *S46Options) BRs() []net.IP {
+	var addrs []net.IP
+	for _, opt := range s.Options.Get(OptionS46BR) {
+		if br, ok := opt.(*OptS46BR); ok {
+			addrs = append(addrs, br.Addr)
+		}
+	}
+	return addrs
+}
+
+// DMR returns the Default Mapping Rule, if present.
+func (s *S46Options) DMR() *OptS46DMR {
+	dmr, _ := s.Options.GetOne(OptionS46DMR).(*OptS46DMR)
+	return dmr
+}
+
+// V4V6Bind returns the IPv4 address and IPv6 prefix binding, if present.
+func (s *S46Options) V4V6Bind() *OptS46V4V6Bind {
+	bind, _ := s.Options.GetOne(OptionS46V4V6Bind).(*OptS46V4V6Bind)
+	return bind
+}
+
+// OptS46MapE implements the S46 MAP-E Container option.
+type OptS46MapE struct {
+	S46Options
+}
+
+// Code returns the option code
+func (op *OptS46MapE) Code() OptionCode {
+	return OptionS46ContMapE
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 5.1.
+func (op *OptS46MapE) ToBytes() []byte {
+	return op.Options.ToBytes()
+}
+
+func (op *OptS46MapE) String() string {
+	return fmt.Sprintf("OptS46MapE{options=%v}", op.Options)
+}
+
+// ParseOptS46MapE builds an OptS46MapE structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptS46MapE(data []byte) (*OptS46MapE, error) {
+	var opt OptS46MapE
+	if err := opt.Options.FromBytes(data); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+// OptS46MapT implements the S46 MAP-T Container option.
+type OptS46MapT struct {
+	S46Options
+}
+
+// Code returns the option code
+func (op *OptS46MapT) Code() OptionCode {
+	return OptionS46ContMapT
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 5.2.
+func (op *OptS46MapT) ToBytes() []byte {
+	return op.Options.ToBytes()
+}
+
+func (op *OptS46MapT) String() string {
+	return fmt.Sprintf("OptS46MapT{options=%v}", op.Options)
+}
+
+// ParseOptS46MapT builds an OptS46MapT structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptS46MapT(data []byte) (*OptS46MapT, error) {
+	var opt OptS46MapT
+	if err := opt.Options.FromBytes(data); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+// OptS46Lw implements the S46 Lightweight 4over6 Container option.
+type OptS46Lw struct {
+	S46Options
+}
+
+// Code returns the option code
+func (op *OptS46Lw) Code() OptionCode {
+	return OptionS46ContLW
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 5.3.
+func (op *OptS46Lw) ToBytes() []byte {
+	return op.Options.ToBytes()
+}
+
+func (op *OptS46Lw) String() string {
+	return fmt.Sprintf("OptS46Lw{options=%v}", op.Options)
+}
+
+// ParseOptS46Lw builds an OptS46Lw structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func ParseOptS46Lw(data []byte) (*OptS46Lw, error) {
+	var opt OptS46Lw
+	if err := opt.Options.FromBytes(data); err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
+
+// OptS46Rule implements the S46 Rule option, which describes a Basic Mapping
+// Rule and, if the FMR flag is set, a Forwarding Mapping Rule.
+type OptS46Rule struct {
+	Flags uint8
+	// EALen is the length of the Embedded Address bits.
+	EALen      uint8
+	IPv4Prefix net.IPNet
+	IPv6Prefix net.IPNet
+	// Options may contain an OptS46PortParams.
+	Options Options
+}
+
+// Code returns the option code
+func (op *OptS46Rule) Code() OptionCode {
+	return OptionS46Rule
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 4.1.
+func (op *OptS46Rule) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(op.Flags)
+	buf.Write8(op.EALen)
+	ones, _ := op.IPv4Prefix.Mask.Size()
+	buf.Write8(uint8(ones))
+	ip4 := op.IPv4Prefix.IP.To4()
+	if ip4 == nil {
+		ip4 = net.IPv4zero.To4()
+	}
+	buf.WriteBytes(ip4)
+	writeS46Prefix6(buf, op.IPv6Prefix)
+	buf.WriteBytes(op.Options.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptS46Rule) String() string {
+	return fmt.Sprintf("OptS46Rule{fmr=%v, ealen=%d, ipv4prefix=%v, ipv6prefix=%v, options=%v}",
+		op.IsFMR(), op.EALen, &op.IPv4Prefix, &op.IPv6Prefix, op.Options)
+}
+
+// IsFMR returns whether the rule is also a Forwarding Mapping Rule.
+func (op *OptS46Rule) IsFMR() bool {
+	return op.Flags&S46RuleFlagFMR != 0
+}
+
+// PortParams returns the port parameters of the rule, if present.
+func (op *OptS46Rule) PortParams() *OptS46PortParams {
+	pp, _ := op.Options.GetOne(OptionS46PortParams).(*OptS46PortParams)
+	return pp
+}
+
+// ParseOptS46Rule builds an OptS46Rule structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptS46Rule(data []byte) (*OptS46Rule, error) {
+	var opt OptS46Rule
+	buf := uio.NewBigEndianBuffer(data)
+	opt.Flags = buf.Read8()
+	opt.EALen = buf.Read8()
+	prefix4Len := int(buf.Read8())
+	ip4 := net.IP(buf.CopyN(net.IPv4len))
+	if buf.Error() == nil && prefix4Len > 8*net.IPv4len {
+		return nil, fmt.Errorf("invalid IPv4 prefix length %d", prefix4Len)
+	}
+	opt.IPv4Prefix = net.IPNet{IP: ip4, Mask: net.CIDRMask(prefix4Len, 8*net.IPv4len)}
+	prefix6, err := readS46Prefix6(buf)
+	if err != nil {
+		return nil, err
+	}
+	opt.IPv6Prefix = prefix6
+	if err := opt.Options.FromBytes(buf.ReadAll()); err != nil {
+		return nil, err
+	}
+	return &opt, buf.FinError()
+}
+
+// OptS46BR implements the S46 BR option, which carries the address of a
+// Border Relay.
+type OptS46BR struct {
+	Addr net.IP
+}
+
+// Code returns the option code
+func (op *OptS46BR) Code() OptionCode {
+	return OptionS46BR
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 4.2.
+func (op *OptS46BR) ToBytes() []byte {
+	return op.Addr.To16()
+}
+
+func (op *OptS46BR) String() string {
+	return fmt.Sprintf("OptS46BR{addr=%v}", op.Addr)
+}
+
+// ParseOptS46BR builds an OptS46BR structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func ParseOptS46BR(data []byte) (*OptS46BR, error) {
+	var opt OptS46BR
+	buf := uio.NewBigEndianBuffer(data)
+	opt.Addr = net.IP(buf.CopyN(net.IPv6len))
+	return &opt, buf.FinError()
+}
+
+// OptS46DMR implements the S46 DMR option, which carries the Default Mapping
+// Rule used by MAP-T.
+type OptS46DMR struct {
+	Prefix net.IPNet
+}
+
+// Code returns the option code
+func (op *OptS46DMR) Code() OptionCode {
+	return OptionS46DMR
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 4.3.
+func (op *OptS46DMR) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	writeS46Prefix6(buf, op.Prefix)
+	return buf.Data()
+}
+
+func (op *OptS46DMR) String() string {
+	return fmt.Sprintf("OptS46DMR{prefix=%v}", &op.Prefix)
+}
+
+// ParseOptS46DMR builds an OptS46DMR structure from a sequence of bytes. The
+// input data does not include option code and length bytes.
+func ParseOptS46DMR(data []byte) (*OptS46DMR, error) {
+	var opt OptS46DMR
+	buf := uio.NewBigEndianBuffer(data)
+	prefix, err := readS46Prefix6(buf)
+	if err != nil {
+		return nil, err
+	}
+	opt.Prefix = prefix
+	return &opt, buf.FinError()
+}
+
+// OptS46V4V6Bind implements the S46 IPv4/IPv6 Address Binding option, which
+// carries the IPv4 address and IPv6 prefix of a Lightweight 4over6 CE.
+type OptS46V4V6Bind struct {
+	IPv4Addr       net.IP
+	BindIPv6Prefix net.IPNet
+	// Options may contain an OptS46PortParams.
+	Options Options
+}
+
+// Code returns the option code
+func (op *OptS46V4V6Bind) Code() OptionCode {
+	return OptionS46V4V6Bind
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 4.4.
+func (op *OptS46V4V6Bind) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	ip4 := op.IPv4Addr.To4()
+	if ip4 == nil {
+		ip4 = net.IPv4zero.To4()
+	}
+	buf.WriteBytes(ip4)
+	writeS46Prefix6(buf, op.BindIPv6Prefix)
+	buf.WriteBytes(op.Options.ToBytes())
+	return buf.Data()
+}
+
+func (op *OptS46V4V6Bind) String() string {
+	return fmt.Sprintf("OptS46V4V6Bind{ipv4addr=%v, bindipv6prefix=%v, options=%v}",
+		op.IPv4Addr, &op.BindIPv6Prefix, op.Options)
+}
+
+// PortParams returns the port parameters of the binding, if present.
+func (op *OptS46V4V6Bind) PortParams() *OptS46PortParams {
+	pp, _ := op.Options.GetOne(OptionS46PortParams).(*OptS46PortParams)
+	return pp
+}
+
+// ParseOptS46V4V6Bind builds an OptS46V4V6Bind structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func ParseOptS46V4V6Bind(data []byte) (*OptS46V4V6Bind, error) {
+	var opt OptS46V4V6Bind
+	buf := uio.NewBigEndianBuffer(data)
+	opt.IPv4Addr = net.IP(buf.CopyN(net.IPv4len))
+	prefix, err := readS46Prefix6(buf)
+	if err != nil {
+		return nil, err
+	}
+	opt.BindIPv6Prefix = prefix
+	if err := opt.Options.FromBytes(buf.ReadAll()); err != nil {
+		return nil, err
+	}
+	return &opt, buf.FinError()
+}
+
+// OptS46PortParams implements the S46 Port Parameters option, which
+// describes the ports available to a CE sharing an IPv4 address.
+type OptS46PortParams struct {
+	// Offset is the number of bits of the port number before the PSID.
+	Offset uint8
+	// PSIDLen is the number of significant bits of PSID.
+	PSIDLen uint8
+	// PSID is the Port Set Identifier, left-aligned.
+	PSID uint16
+}
+
+// Code returns the option code
+func (op *OptS46PortParams) Code() OptionCode {
+	return OptionS46PortParams
+}
+
+// ToBytes marshals this option according to RFC 7598, Section 4.5.
+func (op *OptS46PortParams) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(op.Offset)
+	buf.Write8(op.PSIDLen)
+	buf.Write16(op.PSID)
+	return buf.Data()
+}
+
+func (op *OptS46PortParams) String() string {
+	return fmt.Sprintf("OptS46PortParams{offset=%d, psidlen=%d, psid=%#04x}", op.Offset, op.PSIDLen, op.PSID)
+}
+
+// ParseOptS46PortParams builds an OptS46PortParams structure from a sequence
+// of bytes. The input data does not include option code and length bytes.
+func ParseOptS46PortParams(data []byte) (*OptS46PortParams, error) {
+	var opt OptS46PortParams
+	buf := uio.NewBigEndianBuffer(data)
+	opt.Offset = buf.Read8()
+	opt.PSIDLen = buf.Read8()
+	opt.PSID = buf.Read16()
+	if err := buf.FinError(); err != nil {
+		return nil, err
+	}
+	if opt.PSIDLen > 16 {
+		return nil, fmt.Errorf("invalid PSID length %d", opt.PSIDLen)
+	}
+	return &opt, nil
+}
+
+// writeS46Prefix6 writes an IPv6 prefix as its length followed by as few
+// bytes of the prefix as hold that many bits.
+func writeS46Prefix6(buf *uio.Lexer, prefix net.IPNet) {
+	ones, _ := prefix.Mask.Size()
+	ip := prefix.IP.To16()
+	if ip == nil {
+		ip = net.IPv6zero
+	}
+	buf.Write8(uint8(ones))
+	buf.WriteBytes(ip.Mask(prefix.Mask)[:(ones+7)/8])
+}
+
+// readS46Prefix6 reads an IPv6 prefix written by writeS46Prefix6.
+func readS46Prefix6(buf *uio.Lexer) (net.IPNet, error) {
+	ones := int(buf.Read8())
+	if buf.Error() == nil && ones > 8*net.IPv6len {
+		return net.IPNet{}, fmt.Errorf("invalid IPv6 prefix length %d", ones)
+	}
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, buf.Consume((ones+7)/8))
+	if err := buf.Error(); err != nil {
+		return net.IPNet{}, err
+	}
+	return net.IPNet{IP: ip, Mask: net.CIDRMask(ones, 8*net.IPv6len)}, nil
+}
+
+// S46MapE returns the MAP-E Container option, if present.
+func (m *Message) S46MapE() *OptS46MapE {
+	opt, _ := m.GetOneOption(OptionS46ContMapE).(*OptS46MapE)
+	return opt
+}
+
+// S46MapT returns the MAP-T Container option, if present.
+func (m *Message) S46MapT() *OptS46MapT {
+	opt, _ := m.GetOneOption(OptionS46ContMapT).(*OptS46MapT)
+	return opt
+}
+
+// S46Lw returns the Lightweight 4over6 Container option, if present.
+func (m *Message) S46Lw() *OptS46Lw {
+	opt, _ := m.GetOneOption(OptionS46ContLW).(*OptS46Lw)
+	return opt
+}
@@ -0,0 +1,121 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptS46MapE(t *testing.T) {
+	data := []byte{
+		0, 89, 0, 21, // S46 Rule
+		S46RuleFlagFMR, 16, // flags, EA-len
+		24, 192, 0, 2, 0, // IPv4 prefix 192.0.2.0/24
+		40, 0x20, 0x01, 0x0d, 0xb8, 0x00, // IPv6 prefix 2001:db8::/40
+		0, 93, 0, 4, // S46 Port Parameters
+		6, 0, 0, 0,
+		0, 90, 0, 16, // S46 BR
+		0x20, 0x01, 0x0d, 0xb8, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+	}
+	opt, err := ParseOptS46MapE(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionS46ContMapE, opt.Code())
+
+	rules := opt.Rules()
+	require.Len(t, rules, 1)
+	require.True(t, rules[0].IsFMR())
+	require.Equal(t, uint8(16), rules[0].EALen)
+	require.Equal(t, "192.0.2.0/24", rules[0].IPv4Prefix.String())
+	require.Equal(t, "2001:db8::/40", rules[0].IPv6Prefix.String())
+	require.Equal(t, &OptS46PortParams{Offset: 6}, rules[0].PortParams())
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8:ffff::1")}, opt.BRs())
+	require.Nil(t, opt.DMR())
+
+	require.Equal(t, data, opt.ToBytes())
+}
+
+func TestParseOptS46MapT(t *testing.T) {
+	data := []byte{
+		0, 89, 0, 14, // S46 Rule
+		0, 8, // flags, EA-len
+		16, 198, 51, 0, 0, // IPv4 prefix 198.51.0.0/16
+		48, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x01, // IPv6 prefix 2001:db8:1::/48
+		0, 91, 0, 9, // S46 DMR
+		64, 0x20, 0x01, 0x0d, 0xb8, 0x00, 0x64, 0, 0, // 2001:db8:64::/64
+	}
+	opt, err := ParseOptS46MapT(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionS46ContMapT, opt.Code())
+	require.Len(t, opt.Rules(), 1)
+	require.False(t, opt.Rules()[0].IsFMR())
+	require.Nil(t, opt.Rules()[0].PortParams())
+	require.Equal(t, "2001:db8:64::/64", opt.DMR().Prefix.String())
+	require.Equal(t, data, opt.ToBytes())
+}
+
+func TestParseOptS46Lw(t *testing.T) {
+	data := []byte{
+		0, 90, 0, 16, // S46 BR
+		0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+		0, 92, 0, 20, // S46 IPv4/IPv6 Address Binding
+		192, 0, 2, 1, // IPv4 address
+		56, 0x20, 0x01, 0x0d, 0xb8, 0xab, 0xcd, 0x00, // 2001:db8:abcd::/56
+		0, 93, 0, 4, // S46 Port Parameters
+		0, 6, 0x34, 0x00,
+	}
+	opt, err := ParseOptS46Lw(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionS46ContLW, opt.Code())
+	bind := opt.V4V6Bind()
+	require.NotNil(t, bind)
+	require.Equal(t, net.IP{192, 0, 2, 1}, bind.IPv4Addr)
+	require.Equal(t, "2001:db8:abcd::/56", bind.BindIPv6Prefix.String())
+	require.Equal(t, &OptS46PortParams{PSIDLen: 6, PSID: 0x3400}, bind.PortParams())
+	require.Equal(t, data, opt.ToBytes())
+
+	m := &Message{MessageType: MessageTypeReply}
+	m.AddOption(opt)
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, opt, parsed.S46Lw())
+	require.Nil(t, parsed.S46MapE())
+	require.Nil(t, parsed.S46MapT())
+}
+
+func TestOptS46RuleToBytes(t *testing.T) {
+	opt := &OptS46Rule{
+		EALen:      0,
+		IPv4Prefix: mustParseCIDR(t, "203.0.113.0/24"),
+		IPv6Prefix: mustParseCIDR(t, "2001:db8:8000::/33"),
+	}
+	got, err := ParseOptS46Rule(opt.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, opt.IPv4Prefix.String(), got.IPv4Prefix.String())
+	require.Equal(t, opt.IPv6Prefix.String(), got.IPv6Prefix.String())
+	// 33 bits fit in 5 bytes.
+	require.Len(t, opt.ToBytes(), 2+5+1+5)
+}
+
+func TestParseOptS46Invalid(t *testing.T) {
+	// IPv4 prefix length too long.
+	_, err := ParseOptS46Rule([]byte{0, 0, 33, 192, 0, 2, 0, 0})
+	require.Error(t, err)
+	// IPv6 prefix length too long.
+	_, err = ParseOptS46DMR([]byte{129})
+	require.Error(t, err)
+	// IPv6 prefix shorter than its length.
+	_, err = ParseOptS46DMR([]byte{64, 0x20, 0x01})
+	require.Error(t, err)
+	// Truncated BR.
+	_, err = ParseOptS46BR([]byte{0x20, 0x01, 0x0d, 0xb8})
+	require.Error(t, err)
+	// PSID length too long.
+	_, err = ParseOptS46PortParams([]byte{0, 17, 0, 0})
+	require.Error(t, err)
+	_, err = ParseOptS46PortParams([]byte{0, 6})
+	require.Error(t, err)
+	// Invalid encapsulated option.
+	_, err = ParseOptS46MapE([]byte{0, 90, 0, 4, 0x20, 0x01, 0x0d, 0xb8})
+	require.Error(t, err)
+}
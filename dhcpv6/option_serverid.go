@@ -25,6 +25,11 @@ func (op *OptServerId) String() string {
 	return fmt.Sprintf("OptServerId{sid=%v}", op.Sid.String())
 }
 
+// Validate checks that the Server ID is a valid DUID.
+func (op *OptServerId) Validate() error {
+	return op.Sid.Validate()
+}
+
 // ParseOptServerId builds an OptServerId structure from a sequence of bytes.
 // The input data does not include option code and length bytes.
 func ParseOptServerId(data []byte) (*OptServerId, error) {
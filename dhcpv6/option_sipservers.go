@@ -0,0 +1,104 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// OptSIPServerDomains implements the SIP Servers Domain Name List option.
+//
+// This module defines the OptSIPServerDomains structure.
+// https://www.ietf.org/rfc/rfc3319.txt
+type OptSIPServerDomains struct {
+	Domains []string
+}
+
+// Code returns the option code
+func (op *OptSIPServerDomains) Code() OptionCode {
+	return OptionSIPServersDomainNameList
+}
+
+// ToBytes marshals this option according to RFC 3319, Section 3.1.
+func (op *OptSIPServerDomains) ToBytes() []byte {
+	labels := rfc1035label.Labels{Labels: op.Domains}
+	return labels.ToBytes()
+}
+
+func (op *OptSIPServerDomains) String() string {
+	return fmt.Sprintf("OptSIPServerDomains{domains=%v}", op.Domains)
+}
+
+// ParseOptSIPServerDomains builds an OptSIPServerDomains structure from a
+// sequence of bytes. The input data does not include option code and length
+// bytes.
+func ParseOptSIPServerDomains(data []byte) (*OptSIPServerDomains, error) {
+	labels, err := rfc1035label.FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptSIPServerDomains{Domains: labels.Labels}, nil
+}
+
+// OptSIPServerAddresses implements the SIP Servers IPv6 Address List option.
+//
+// This module defines the OptSIPServerAddresses structure.
+// https://www.ietf.org/rfc/rfc3319.txt
+type OptSIPServerAddresses struct {
+	Servers []net.IP
+}
+
+// Code returns the option code
+func (op *OptSIPServerAddresses) Code() OptionCode {
+	return OptionSIPServersIPv6AddressList
+}
+
+// ToBytes marshals this option according to RFC 3319, Section 3.2.
+func (op *OptSIPServerAddresses) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, s := range op.Servers {
+		buf.WriteBytes(s.To16())
+	}
+	return buf.Data()
+}
+
+func (op *OptSIPServerAddresses) String() string {
+	return fmt.Sprintf("OptSIPServerAddresses{servers=%v}", op.Servers)
+}
+
+// ParseOptSIPServerAddresses builds an OptSIPServerAddresses structure from a
+// sequence of bytes. The input data does not include option code and length
+// bytes.
+func ParseOptSIPServerAddresses(data []byte) (*OptSIPServerAddresses, error) {
+	if len(data)%net.IPv6len != 0 {
+		return nil, fmt.Errorf("SIP Servers IPv6 Address List length must be a multiple of %d, got %d", net.IPv6len, len(data))
+	}
+	var opt OptSIPServerAddresses
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Has(net.IPv6len) {
+		opt.Servers = append(opt.Servers, buf.CopyN(net.IPv6len))
+	}
+	return &opt, buf.FinError()
+}
+
+// SIPServerDomains returns the SIP server domain names carried by the message,
+// if any.
+func (m *Message) SIPServerDomains() []string {
+	opt, ok := m.GetOneOption(OptionSIPServersDomainNameList).(*OptSIPServerDomains)
+	if !ok {
+		return nil
+	}
+	return opt.Domains
+}
+
+// SIPServerAddresses returns the SIP server addresses carried by the message,
+// if any.
+func (m *Message) SIPServerAddresses() []net.IP {
+	opt, ok := m.GetOneOption(OptionSIPServersIPv6AddressList).(*OptSIPServerAddresses)
+	if !ok {
+		return nil
+	}
+	return opt.Servers
+}
@@ -0,0 +1,50 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptSIPServerDomains(t *testing.T) {
+	data := []byte{
+		3, 's', 'i', 'p', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+		4, 's', 'i', 'p', '2', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'n', 'e', 't', 0,
+	}
+	opt, err := ParseOptSIPServerDomains(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionSIPServersDomainNameList, opt.Code())
+	require.Equal(t, []string{"sip.example.com", "sip2.example.net"}, opt.Domains)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "domains=[sip.example.com sip2.example.net]")
+
+	_, err = ParseOptSIPServerDomains([]byte{3, 's', 'i'})
+	require.Error(t, err)
+}
+
+func TestParseOptSIPServerAddresses(t *testing.T) {
+	data := append(net.ParseIP("2001:db8::5060"), net.ParseIP("2001:db8::5061")...)
+	opt, err := ParseOptSIPServerAddresses(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionSIPServersIPv6AddressList, opt.Code())
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::5060"), net.ParseIP("2001:db8::5061")}, opt.Servers)
+	require.Equal(t, []byte(data), opt.ToBytes())
+	require.Contains(t, opt.String(), "servers=[2001:db8::5060 2001:db8::5061]")
+
+	_, err = ParseOptSIPServerAddresses(data[:20])
+	require.Error(t, err)
+}
+
+func TestOptSIPServersRoundTrip(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	require.Nil(t, m.SIPServerDomains())
+	require.Nil(t, m.SIPServerAddresses())
+
+	m.AddOption(&OptSIPServerDomains{Domains: []string{"sip.example.com"}})
+	m.AddOption(&OptSIPServerAddresses{Servers: []net.IP{net.ParseIP("2001:db8::5060")}})
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, []string{"sip.example.com"}, parsed.SIPServerDomains())
+	require.Equal(t, []net.IP{net.ParseIP("2001:db8::5060")}, parsed.SIPServerAddresses())
+}
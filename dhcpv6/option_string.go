@@ -0,0 +1,95 @@
+package dhcpv6
+
+import (
+	"fmt"
+
+	"github.com/insomniacslk/dhcp/rfc1035label"
+)
+
+// StringEncoding is the encoding of the value of a StringOption.
+type StringEncoding uint8
+
+// String encodings.
+const (
+	// StringASCII encodes the value as is, without any terminator, like
+	// the timezone options of RFC 4833.
+	StringASCII StringEncoding = iota
+	// StringFQDN encodes the value as a single domain name in the format of
+	// RFC 1035, Section 3.1, like the AFTR-Name option of RFC 6334.
+	StringFQDN
+)
+
+func (e StringEncoding) String() string {
+	switch e {
+	case StringASCII:
+		return "ASCII"
+	case StringFQDN:
+		return "FQDN"
+	}
+	return fmt.Sprintf("StringEncoding(%d)", uint8(e))
+}
+
+// StringOption is a generic option whose value is a single, non-empty string.
+// It can be used for options the package has no specific type for, and is the
+// base of the encoding of the package's own string options.
+type StringOption struct {
+	OptionCode OptionCode
+	Encoding   StringEncoding
+	Value      string
+}
+
+// Code returns the option code
+func (op *StringOption) Code() OptionCode {
+	return op.OptionCode
+}
+
+// ToBytes marshals the value of this option according to its encoding.
+func (op *StringOption) ToBytes() []byte {
+	return encodeString(op.Encoding, op.Value)
+}
+
+func (op *StringOption) String() string {
+	return fmt.Sprintf("%s{value=%v}", op.OptionCode, op.Value)
+}
+
+// StringOptionFromBytes builds a StringOption with the given code and encoding
+// from a sequence of bytes. The input data does not include option code and
+// length bytes.
+func StringOptionFromBytes(code OptionCode, enc StringEncoding, data []byte) (*StringOption, error) {
+	v, err := decodeString(code.String(), enc, data)
+	if err != nil {
+		return nil, err
+	}
+	return &StringOption{OptionCode: code, Encoding: enc, Value: v}, nil
+}
+
+// encodeString encodes s with enc.
+func encodeString(enc StringEncoding, s string) []byte {
+	if enc == StringFQDN {
+		labels := rfc1035label.Labels{Labels: []string{s}}
+		return labels.ToBytes()
+	}
+	return []byte(s)
+}
+
+// decodeString decodes the non-empty string encoded with enc in data. name
+// describes the string in errors.
+func decodeString(name string, enc StringEncoding, data []byte) (string, error) {
+	switch enc {
+	case StringASCII:
+		if len(data) == 0 {
+			return "", fmt.Errorf("%s cannot be empty", name)
+		}
+		return string(data), nil
+	case StringFQDN:
+		labels, err := rfc1035label.FromBytes(data)
+		if err != nil {
+			return "", err
+		}
+		if len(labels.Labels) != 1 {
+			return "", fmt.Errorf("%s must contain exactly one FQDN, got %d", name, len(labels.Labels))
+		}
+		return labels.Labels[0], nil
+	}
+	return "", fmt.Errorf("unknown string encoding %s", enc)
+}
@@ -0,0 +1,44 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringOptionRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		enc  StringEncoding
+		data []byte
+	}{
+		{StringASCII, []byte("Europe/Zurich")},
+		{StringFQDN, []byte{4, 'a', 'f', 't', 'r', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0}},
+	} {
+		t.Run(tt.enc.String(), func(t *testing.T) {
+			opt, err := StringOptionFromBytes(OptionCode(200), tt.enc, tt.data)
+			require.NoError(t, err)
+			require.Equal(t, OptionCode(200), opt.Code())
+			require.Equal(t, tt.data, opt.ToBytes())
+
+			got, err := StringOptionFromBytes(opt.Code(), opt.Encoding, opt.ToBytes())
+			require.NoError(t, err)
+			require.Equal(t, opt, got)
+		})
+	}
+
+	opt, err := StringOptionFromBytes(OptionAFTRName, StringFQDN, (&OptAFTRName{Name: "aftr.example.com"}).ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, "aftr.example.com", opt.Value)
+}
+
+func TestStringOptionFromBytesInvalid(t *testing.T) {
+	_, err := StringOptionFromBytes(OptionNewTZDBTimezone, StringASCII, []byte{})
+	require.Error(t, err)
+	// Two FQDNs.
+	_, err = StringOptionFromBytes(OptionAFTRName, StringFQDN, []byte{1, 'a', 0, 1, 'b', 0})
+	require.Error(t, err)
+	_, err = StringOptionFromBytes(OptionAFTRName, StringFQDN, []byte{})
+	require.Error(t, err)
+	_, err = StringOptionFromBytes(OptionAFTRName, StringEncoding(42), []byte("x"))
+	require.Error(t, err)
+}
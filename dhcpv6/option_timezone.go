@@ -0,0 +1,88 @@
+package dhcpv6
+
+import (
+	"fmt"
+)
+
+// OptPosixTimezone implements the New POSIX Timezone option.
+//
+// This module defines the OptPosixTimezone structure.
+// https://www.ietf.org/rfc/rfc4833.txt
+type OptPosixTimezone struct {
+	// TZ is a POSIX TZ string, such as "EST5EDT4,M3.2.0/02:00,M11.1.0/02:00".
+	TZ string
+}
+
+func (op *OptPosixTimezone) Code() OptionCode {
+	return OptionNewPOSIXTimezone
+}
+
+// ToBytes marshals this option according to RFC 4833, Section 3.
+func (op *OptPosixTimezone) ToBytes() []byte {
+	return encodeString(StringASCII, op.TZ)
+}
+
+func (op *OptPosixTimezone) String() string {
+	return fmt.Sprintf("OptPosixTimezone{tz=%v}", op.TZ)
+}
+
+// ParseOptPosixTimezone builds an OptPosixTimezone structure from a sequence
+// of bytes. The input data does not include option code and length bytes.
+func ParseOptPosixTimezone(data []byte) (*OptPosixTimezone, error) {
+	tz, err := decodeString("POSIX timezone", StringASCII, data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptPosixTimezone{TZ: tz}, nil
+}
+
+// OptTZDBTimezone implements the New TZDB Timezone option.
+//
+// This module defines the OptTZDBTimezone structure.
+// https://www.ietf.org/rfc/rfc4833.txt
+type OptTZDBTimezone struct {
+	// Name is the name of a TZ Database timezone, such as "Europe/Zurich".
+	Name string
+}
+
+func (op *OptTZDBTimezone) Code() OptionCode {
+	return OptionNewTZDBTimezone
+}
+
+// ToBytes marshals this option according to RFC 4833, Section 3.
+func (op *OptTZDBTimezone) ToBytes() []byte {
+	return encodeString(StringASCII, op.Name)
+}
+
+func (op *OptTZDBTimezone) String() string {
+	return fmt.Sprintf("OptTZDBTimezone{name=%v}", op.Name)
+}
+
+// ParseOptTZDBTimezone builds an OptTZDBTimezone structure from a sequence of
+// bytes. The input data does not include option code and length bytes.
+func ParseOptTZDBTimezone(data []byte) (*OptTZDBTimezone, error) {
+	name, err := decodeString("TZDB timezone", StringASCII, data)
+	if err != nil {
+		return nil, err
+	}
+	return &OptTZDBTimezone{Name: name}, nil
+}
+
+// PosixTimezone returns the POSIX TZ string carried by the message, if any.
+func (m *Message) PosixTimezone() (string, bool) {
+	opt, ok := m.GetOneOption(OptionNewPOSIXTimezone).(*OptPosixTimezone)
+	if !ok {
+		return "", false
+	}
+	return opt.TZ, true
+}
+
+// TZDBTimezone returns the TZ Database timezone name carried by the message,
+// if any.
+func (m *Message) TZDBTimezone() (string, bool) {
+	opt, ok := m.GetOneOption(OptionNewTZDBTimezone).(*OptTZDBTimezone)
+	if !ok {
+		return "", false
+	}
+	return opt.Name, true
+}
@@ -0,0 +1,53 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptPosixTimezone(t *testing.T) {
+	data := []byte("EST5EDT4,M3.2.0/02:00,M11.1.0/02:00")
+	opt, err := ParseOptPosixTimezone(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionNewPOSIXTimezone, opt.Code())
+	require.Equal(t, "EST5EDT4,M3.2.0/02:00,M11.1.0/02:00", opt.TZ)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "tz=EST5EDT4")
+
+	_, err = ParseOptPosixTimezone([]byte{})
+	require.Error(t, err)
+}
+
+func TestParseOptTZDBTimezone(t *testing.T) {
+	data := []byte("Europe/Zurich")
+	opt, err := ParseOptTZDBTimezone(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionNewTZDBTimezone, opt.Code())
+	require.Equal(t, "Europe/Zurich", opt.Name)
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "name=Europe/Zurich")
+
+	_, err = ParseOptTZDBTimezone(nil)
+	require.Error(t, err)
+}
+
+func TestTimezoneRoundTrip(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	_, ok := m.PosixTimezone()
+	require.False(t, ok)
+	_, ok = m.TZDBTimezone()
+	require.False(t, ok)
+
+	m.AddOption(&OptPosixTimezone{TZ: "CET-1CEST,M3.5.0,M10.5.0/3"})
+	m.AddOption(&OptTZDBTimezone{Name: "Europe/Zurich"})
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+
+	tz, ok := parsed.PosixTimezone()
+	require.True(t, ok)
+	require.Equal(t, "CET-1CEST,M3.5.0,M10.5.0/3", tz)
+	name, ok := parsed.TZDBTimezone()
+	require.True(t, ok)
+	require.Equal(t, "Europe/Zurich", name)
+}
@@ -0,0 +1,38 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// OptUnicast implements the Server Unicast option, by which a server lets
+// clients send it messages by unicast.
+//
+// This module defines the OptUnicast structure.
+// https://www.ietf.org/rfc/rfc3315.txt
+type OptUnicast struct {
+	ServerAddr net.IP
+}
+
+func (op *OptUnicast) Code() OptionCode {
+	return OptionUnicast
+}
+
+// ToBytes marshals this option according to RFC 3315, Section 22.12.
+func (op *OptUnicast) ToBytes() []byte {
+	return op.ServerAddr.To16()
+}
+
+func (op *OptUnicast) String() string {
+	return fmt.Sprintf("OptUnicast{serveraddr=%v}", op.ServerAddr)
+}
+
+// ParseOptUnicast builds an OptUnicast structure from a sequence of bytes.
+// The input data does not include option code and length bytes.
+func ParseOptUnicast(data []byte) (*OptUnicast, error) {
+	buf := uio.NewBigEndianBuffer(data)
+	opt := OptUnicast{ServerAddr: net.IP(buf.CopyN(net.IPv6len))}
+	return &opt, buf.FinError()
+}
@@ -0,0 +1,26 @@
+package dhcpv6
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptUnicast(t *testing.T) {
+	data := []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01}
+	opt, err := ParseOptUnicast(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionUnicast, opt.Code())
+	require.True(t, net.ParseIP("2001:db8::1").Equal(opt.ServerAddr))
+	require.Equal(t, data, opt.ToBytes())
+	require.Contains(t, opt.String(), "serveraddr=2001:db8::1")
+}
+
+func TestParseOptUnicastInvalidLength(t *testing.T) {
+	_, err := ParseOptUnicast([]byte{0x20, 0x01})
+	require.Error(t, err)
+
+	_, err = ParseOptUnicast(make([]byte, 17))
+	require.Error(t, err)
+}
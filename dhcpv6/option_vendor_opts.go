@@ -52,5 +52,74 @@ func ParseOptVendorOpts(data []byte) (*OptVendorOpts, error) {
 // sub-options include codes specific to each vendor. There are overlaps in these
 // codes with RFC standard codes.
 func vendParseOption(code OptionCode, data []byte) (Option, error) {
-	return &OptionGeneric{OptionCode: code, OptionData: data}, nil
+	return &OptionGeneric{OptionCode: code, OptionData: append([]byte(nil), data...)}, nil
+}
+
+// Vendor-specific sub-options used by PXE servers to offer a boot menu, as in
+// the PXE specification, carried under Intel's enterprise number.
+const (
+	PXEEnterpriseNumber = 343
+
+	PXEBootMenu OptionCode = 9
+)
+
+// BootEntry is an entry of a PXE boot menu.
+type BootEntry struct {
+	// Type is the boot server type of the entry, which the client sends
+	// back to the server to select it.
+	Type        uint16
+	Description string
+}
+
+// NewOptBootMenu returns a Vendor-specific Information option carrying a PXE
+// boot menu made of entries.
+func NewOptBootMenu(entries []BootEntry) *OptVendorOpts {
+	buf := uio.NewBigEndianBuffer(nil)
+	for _, e := range entries {
+		buf.Write16(e.Type)
+		buf.Write8(uint8(len(e.Description)))
+		buf.WriteBytes([]byte(e.Description))
+	}
+	return &OptVendorOpts{
+		EnterpriseNumber: PXEEnterpriseNumber,
+		VendorOpts:       Options{&OptionGeneric{OptionCode: PXEBootMenu, OptionData: buf.Data()}},
+	}
+}
+
+// parseBootMenu parses the entries of a PXE boot menu sub-option.
+func parseBootMenu(data []byte) ([]BootEntry, error) {
+	var entries []BootEntry
+	buf := uio.NewBigEndianBuffer(data)
+	for buf.Len() > 0 {
+		e := BootEntry{Type: buf.Read16()}
+		e.Description = string(buf.CopyN(int(buf.Read8())))
+		entries = append(entries, e)
+	}
+	return entries, buf.FinError()
+}
+
+// BootMenu returns the PXE boot menu offered by the server in a
+// Vendor-specific Information option, if any. Servers usually tailor the
+// menu to the Client Architecture Type and Client Network Interface
+// Identifier options the client sent, so that a netboot client can present
+// the entries to the user and request the one selected.
+//
+// false is returned if there is no boot menu or if it is malformed.
+func (m *Message) BootMenu() ([]BootEntry, bool) {
+	for _, opt := range m.GetOption(OptionVendorOpts) {
+		vo, ok := opt.(*OptVendorOpts)
+		if !ok || vo.EnterpriseNumber != PXEEnterpriseNumber {
+			continue
+		}
+		sub, ok := vo.VendorOpts.GetOne(PXEBootMenu).(*OptionGeneric)
+		if !ok {
+			continue
+		}
+		entries, err := parseBootMenu(sub.OptionData)
+		if err != nil {
+			return nil, false
+		}
+		return entries, true
+	}
+	return nil, false
 }
@@ -45,3 +45,40 @@ func TestOptVendorOptsToBytes(t *testing.T) {
 	toBytes := opt.ToBytes()
 	require.Equal(t, expected, toBytes)
 }
+
+func TestBootMenu(t *testing.T) {
+	entries := []BootEntry{
+		{Type: 0, Description: "Local boot"},
+		{Type: 0x8001, Description: "Install"},
+	}
+	m := &Message{MessageType: MessageTypeReply}
+	// Options of other vendors are ignored.
+	m.AddOption(&OptVendorOpts{EnterpriseNumber: 4242, VendorOpts: Options{
+		&OptionGeneric{OptionCode: PXEBootMenu, OptionData: []byte{1}},
+	}})
+	m.AddOption(NewOptBootMenu(entries))
+
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	got, ok := parsed.BootMenu()
+	require.True(t, ok)
+	require.Equal(t, entries, got)
+
+	require.Equal(t, []byte{
+		0, 9, 0, 13,
+		0, 0, 10, 'L', 'o', 'c', 'a', 'l', ' ', 'b', 'o', 'o', 't',
+	}, NewOptBootMenu(entries[:1]).VendorOpts.ToBytes())
+}
+
+func TestBootMenuInvalid(t *testing.T) {
+	m := &Message{MessageType: MessageTypeReply}
+	_, ok := m.BootMenu()
+	require.False(t, ok)
+
+	// The description is longer than the remaining data.
+	m.AddOption(&OptVendorOpts{EnterpriseNumber: PXEEnterpriseNumber, VendorOpts: Options{
+		&OptionGeneric{OptionCode: PXEBootMenu, OptionData: []byte{0, 1, 5, 'a'}},
+	}})
+	_, ok = m.BootMenu()
+	require.False(t, ok)
+}
@@ -0,0 +1,100 @@
+package dhcpv6
+
+import (
+	"fmt"
+
+	"github.com/u-root/u-root/pkg/uio"
+)
+
+// VSS types, as defined by RFC 6607, Section 3.1.
+const (
+	// VSSTypeNVTASCII identifies the VPN with an NVT-ASCII string.
+	VSSTypeNVTASCII uint8 = 0
+	// VSSTypeVPNID identifies the VPN with an RFC 2685 VPN-ID.
+	VSSTypeVPNID uint8 = 1
+	// VSSTypeGlobal identifies the global, default VPN. It carries no
+	// information.
+	VSSTypeGlobal uint8 = 255
+)
+
+// vpnIDLen is the length of an RFC 2685 VPN-ID: a 3-byte OUI followed by a
+// 4-byte VPN index.
+const vpnIDLen = 7
+
+// OptVirtualSubnetSelection implements the Virtual Subnet Selection option,
+// which identifies the VPN or VRF a client belongs to.
+//
+// This module defines the OptVirtualSubnetSelection structure.
+// https://www.ietf.org/rfc/rfc6607.txt
+type OptVirtualSubnetSelection struct {
+	Type uint8
+	// Info is the VSS information, whose format depends on Type.
+	Info []byte
+}
+
+// Code returns the option code
+func (op *OptVirtualSubnetSelection) Code() OptionCode {
+	return OptionVirtualSubnetSelection
+}
+
+// ToBytes marshals this option according to RFC 6607, Section 3.5.
+func (op *OptVirtualSubnetSelection) ToBytes() []byte {
+	buf := uio.NewBigEndianBuffer(nil)
+	buf.Write8(op.Type)
+	buf.WriteBytes(op.Info)
+	return buf.Data()
+}
+
+func (op *OptVirtualSubnetSelection) String() string {
+	switch op.Type {
+	case VSSTypeNVTASCII:
+		return fmt.Sprintf("OptVirtualSubnetSelection{type=%d, vpn=%q}", op.Type, op.Info)
+	default:
+		return fmt.Sprintf("OptVirtualSubnetSelection{type=%d, info=%x}", op.Type, op.Info)
+	}
+}
+
+// Validate checks that the VSS information has the length required by its
+// type, as described by RFC 6607, Section 3.1.
+func (op *OptVirtualSubnetSelection) Validate() error {
+	switch op.Type {
+	case VSSTypeVPNID:
+		if len(op.Info) != vpnIDLen {
+			return fmt.Errorf("VPN-ID must be %d bytes long, got %d", vpnIDLen, len(op.Info))
+		}
+	case VSSTypeGlobal:
+		if len(op.Info) != 0 {
+			return fmt.Errorf("global VPN must have no VSS information, got %d bytes", len(op.Info))
+		}
+	}
+	return nil
+}
+
+// VPNID returns the OUI and VPN index of an RFC 2685 VPN-ID.
+func (op *OptVirtualSubnetSelection) VPNID() (oui uint32, index uint32, ok bool) {
+	if op.Type != VSSTypeVPNID || len(op.Info) != vpnIDLen {
+		return 0, 0, false
+	}
+	buf := uio.NewBigEndianBuffer(op.Info)
+	oui = uint32(buf.Read8())<<16 | uint32(buf.Read16())
+	index = buf.Read32()
+	return oui, index, true
+}
+
+// ParseOptVirtualSubnetSelection builds an OptVirtualSubnetSelection
+// structure from a sequence of bytes. The input data does not include option
+// code and length bytes.
+func ParseOptVirtualSubnetSelection(data []byte) (*OptVirtualSubnetSelection, error) {
+	var opt OptVirtualSubnetSelection
+	buf := uio.NewBigEndianBuffer(data)
+	opt.Type = buf.Read8()
+	opt.Info = buf.ReadAll()
+	return &opt, buf.FinError()
+}
+
+// VirtualSubnetSelection returns the Virtual Subnet Selection option carried
+// by the message, if any.
+func (m *Message) VirtualSubnetSelection() *OptVirtualSubnetSelection {
+	opt, _ := m.GetOneOption(OptionVirtualSubnetSelection).(*OptVirtualSubnetSelection)
+	return opt
+}
@@ -0,0 +1,64 @@
+package dhcpv6
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptVirtualSubnetSelectionNVTASCII(t *testing.T) {
+	data := []byte{VSSTypeNVTASCII, 'v', 'r', 'f', '-', 'r', 'e', 'd'}
+	opt, err := ParseOptVirtualSubnetSelection(data)
+	require.NoError(t, err)
+	require.Equal(t, OptionVirtualSubnetSelection, opt.Code())
+	require.Equal(t, VSSTypeNVTASCII, opt.Type)
+	require.Equal(t, []byte("vrf-red"), opt.Info)
+	require.NoError(t, opt.Validate())
+	require.Contains(t, opt.String(), `"vrf-red"`)
+	require.Equal(t, data, opt.ToBytes())
+
+	_, _, ok := opt.VPNID()
+	require.False(t, ok)
+}
+
+func TestParseOptVirtualSubnetSelectionVPNID(t *testing.T) {
+	data := []byte{VSSTypeVPNID, 0x00, 0x00, 0x5e, 0, 0, 0x01, 0x02}
+	opt, err := ParseOptVirtualSubnetSelection(data)
+	require.NoError(t, err)
+	require.Equal(t, VSSTypeVPNID, opt.Type)
+	require.NoError(t, opt.Validate())
+	oui, index, ok := opt.VPNID()
+	require.True(t, ok)
+	require.Equal(t, uint32(0x00005e), oui)
+	require.Equal(t, uint32(0x0102), index)
+	require.Equal(t, data, opt.ToBytes())
+
+	opt.Info = opt.Info[:6]
+	require.Error(t, opt.Validate())
+	_, _, ok = opt.VPNID()
+	require.False(t, ok)
+}
+
+func TestParseOptVirtualSubnetSelectionGlobal(t *testing.T) {
+	opt, err := ParseOptVirtualSubnetSelection([]byte{VSSTypeGlobal})
+	require.NoError(t, err)
+	require.Empty(t, opt.Info)
+	require.NoError(t, opt.Validate())
+
+	opt.Info = []byte{1}
+	require.Error(t, opt.Validate())
+
+	_, err = ParseOptVirtualSubnetSelection(nil)
+	require.Error(t, err)
+}
+
+func TestMessageVirtualSubnetSelection(t *testing.T) {
+	m := &Message{MessageType: MessageTypeSolicit}
+	require.Nil(t, m.VirtualSubnetSelection())
+
+	opt := &OptVirtualSubnetSelection{Type: VSSTypeNVTASCII, Info: []byte("blue")}
+	m.AddOption(opt)
+	parsed, err := MessageFromBytes(m.ToBytes())
+	require.NoError(t, err)
+	require.Equal(t, opt, parsed.VirtualSubnetSelection())
+}
@@ -0,0 +1,109 @@
+package dhcpv6
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	optionNamesMu sync.RWMutex
+	// optionNames holds the names registered with RegisterOptionCodeName.
+	optionNames = make(map[OptionCode]string)
+)
+
+// OptionCodeName returns the name of code, as assigned by the IANA registry
+// (e.g. "OPTION_DNS_SERVERS") for the codes known to the package or with
+// RegisterOptionCodeName for others. "UNKNOWN(n)" is returned for unnamed
+// codes.
+func OptionCodeName(code OptionCode) string {
+	if s, ok := optionCodeToString[code]; ok {
+		return s
+	}
+	optionNamesMu.RLock()
+	defer optionNamesMu.RUnlock()
+	if s, ok := optionNames[code]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", code)
+}
+
+// RegisterOptionCodeName registers name as the name of code, e.g. for a
+// proprietary or not yet supported option, so that it is used by logs and
+// marshaled messages.
+//
+// The names of codes known to the package cannot be overridden, and names
+// must be unique.
+func RegisterOptionCodeName(code OptionCode, name string) error {
+	if name == "" {
+		return fmt.Errorf("option code %d: name cannot be empty", code)
+	}
+	if s, ok := optionCodeToString[code]; ok {
+		return fmt.Errorf("option code %d is already named %s", code, s)
+	}
+	for _, s := range optionCodeToString {
+		if s == name {
+			return fmt.Errorf("option name %s is already in use", name)
+		}
+	}
+	optionNamesMu.Lock()
+	defer optionNamesMu.Unlock()
+	for c, s := range optionNames {
+		if s == name && c != code {
+			return fmt.Errorf("option name %s is already in use", name)
+		}
+	}
+	optionNames[code] = name
+	return nil
+}
+
+// optionCodeByName returns the code named name, see OptionCodeName.
+func optionCodeByName(name string) (OptionCode, bool) {
+	for code, s := range optionCodeToString {
+		if s == name {
+			return code, true
+		}
+	}
+	optionNamesMu.RLock()
+	defer optionNamesMu.RUnlock()
+	for code, s := range optionNames {
+		if s == name {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// optionCodeFromName is like optionCodeByName, but also accepts "UNKNOWN(n)"
+// and decimal values.
+func optionCodeFromName(name string) (OptionCode, error) {
+	if code, ok := optionCodeByName(name); ok {
+		return code, nil
+	}
+	n := name
+	if strings.HasPrefix(n, "UNKNOWN(") && strings.HasSuffix(n, ")") {
+		n = n[len("UNKNOWN(") : len(n)-1]
+	}
+	code, err := strconv.ParseUint(n, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("unknown option name %q", name)
+	}
+	return OptionCode(code), nil
+}
+
+// MarshalText marshals the option code as its name, e.g. in JSON documents.
+func (o OptionCode) MarshalText() ([]byte, error) {
+	return []byte(OptionCodeName(o)), nil
+}
+
+// UnmarshalText parses an option code from its name, or from its decimal
+// value.
+func (o *OptionCode) UnmarshalText(text []byte) error {
+	code, err := optionCodeFromName(string(text))
+	if err != nil {
+		return err
+	}
+	*o = code
+	return nil
+}
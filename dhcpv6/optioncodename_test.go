@@ -0,0 +1,47 @@
+package dhcpv6
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionCodeName(t *testing.T) {
+	require.Equal(t, "OPTION_DNS_SERVERS", OptionCodeName(OptionDNSRecursiveNameServer))
+	// RFC 4833, as spelled in the IANA registry.
+	require.Equal(t, "OPTION_NEW_POSIX_TIMEZONE", OptionCodeName(41))
+	require.Equal(t, "UNKNOWN(65000)", OptionCodeName(65000))
+	require.Equal(t, "UNKNOWN(65000)", OptionCode(65000).String())
+
+	require.NoError(t, RegisterOptionCodeName(65001, "OPTION_EXAMPLE_PROPRIETARY"))
+	defer func() {
+		optionNamesMu.Lock()
+		delete(optionNames, 65001)
+		optionNamesMu.Unlock()
+	}()
+	require.Equal(t, "OPTION_EXAMPLE_PROPRIETARY", OptionCodeName(65001))
+	og := &OptionGeneric{OptionCode: 65001, OptionData: []byte{1}}
+	require.Contains(t, og.String(), "OPTION_EXAMPLE_PROPRIETARY")
+
+	// Standard names can neither be overridden nor reused.
+	require.Error(t, RegisterOptionCodeName(OptionDNSRecursiveNameServer, "DNS"))
+	require.Error(t, RegisterOptionCodeName(65002, "OPTION_DNS_SERVERS"))
+	require.Error(t, RegisterOptionCodeName(65002, "OPTION_EXAMPLE_PROPRIETARY"))
+	require.Error(t, RegisterOptionCodeName(65002, ""))
+}
+
+func TestOptionCodeJSON(t *testing.T) {
+	codes := []OptionCode{OptionClientID, OptionNTPServer, 65000}
+	b, err := json.Marshal(codes)
+	require.NoError(t, err)
+	require.JSONEq(t, `["OPTION_CLIENTID", "OPTION_NTP_SERVER", "UNKNOWN(65000)"]`, string(b))
+
+	var got []OptionCode
+	require.NoError(t, json.Unmarshal(b, &got))
+	require.Equal(t, codes, got)
+
+	require.NoError(t, json.Unmarshal([]byte(`["23"]`), &got))
+	require.Equal(t, []OptionCode{OptionDNSRecursiveNameServer}, got)
+	require.Error(t, json.Unmarshal([]byte(`["OPTION_BOGUS"]`), &got))
+}
@@ -13,6 +13,12 @@ type Option interface {
 	String() string
 }
 
+// Validator is implemented by options that can check their consistency beyond
+// what parsing them requires. See Message.Validate.
+type Validator interface {
+	Validate() error
+}
+
 type OptionGeneric struct {
 	OptionCode OptionCode
 	OptionData []byte
@@ -53,6 +59,8 @@ func ParseOption(code OptionCode, optData []byte) (Option, error) {
 		opt, err = ParseOptElapsedTime(optData)
 	case OptionRelayMsg:
 		opt, err = ParseOptRelayMsg(optData)
+	case OptionUnicast:
+		opt, err = ParseOptUnicast(optData)
 	case OptionStatusCode:
 		opt, err = ParseOptStatusCode(optData)
 	case OptionUserClass:
@@ -67,10 +75,16 @@ func ParseOption(code OptionCode, optData []byte) (Option, error) {
 		opt, err = ParseOptDNSRecursiveNameServer(optData)
 	case OptionDomainSearchList:
 		opt, err = ParseOptDomainSearchList(optData)
+	case OptionSIPServersDomainNameList:
+		opt, err = ParseOptSIPServerDomains(optData)
+	case OptionSIPServersIPv6AddressList:
+		opt, err = ParseOptSIPServerAddresses(optData)
 	case OptionIAPD:
 		opt, err = ParseOptIAForPrefixDelegation(optData)
 	case OptionIAPrefix:
 		opt, err = ParseOptIAPrefix(optData)
+	case OptionPDExclude:
+		opt, err = ParseOptPDExclude(optData)
 	case OptionRemoteID:
 		opt, err = ParseOptRemoteId(optData)
 	case OptionBootfileURL:
@@ -79,8 +93,50 @@ func ParseOption(code OptionCode, optData []byte) (Option, error) {
 		opt, err = ParseOptClientArchType(optData)
 	case OptionNII:
 		opt, err = ParseOptNetworkInterfaceId(optData)
+	case OptionNTPServer:
+		opt, err = ParseOptNTPServer(optData)
+	case OptionLQQuery:
+		opt, err = ParseOptLQQuery(optData)
+	case OptionClientData:
+		opt, err = ParseOptClientData(optData)
+	case OptionCLTTime:
+		opt, err = ParseOptCLTTime(optData)
+	case OptionLQRelayData:
+		opt, err = ParseOptLQRelayData(optData)
+	case OptionLQClientLink:
+		opt, err = ParseOptLQClientLink(optData)
+	case OptionNewPOSIXTimezone:
+		opt, err = ParseOptPosixTimezone(optData)
+	case OptionNewTZDBTimezone:
+		opt, err = ParseOptTZDBTimezone(optData)
+	case OptionAFTRName:
+		opt, err = ParseOptAFTRName(optData)
+	case OptionERPLocalDomainName:
+		opt, err = ParseOptERPLocalDomainName(optData)
+	case OptionDHCPv4Msg:
+		opt, err = ParseOptDHCPv4Msg(optData)
+	case OptionVirtualSubnetSelection:
+		opt, err = ParseOptVirtualSubnetSelection(optData)
+	case OptionS46Rule:
+		opt, err = ParseOptS46Rule(optData)
+	case OptionS46BR:
+		opt, err = ParseOptS46BR(optData)
+	case OptionS46DMR:
+		opt, err = ParseOptS46DMR(optData)
+	case OptionS46V4V6Bind:
+		opt, err = ParseOptS46V4V6Bind(optData)
+	case OptionS46PortParams:
+		opt, err = ParseOptS46PortParams(optData)
+	case OptionS46ContMapE:
+		opt, err = ParseOptS46MapE(optData)
+	case OptionS46ContMapT:
+		opt, err = ParseOptS46MapT(optData)
+	case OptionS46ContLW:
+		opt, err = ParseOptS46Lw(optData)
+	case OptionCaptivePortal:
+		opt, err = ParseOptCaptivePortal(optData)
 	default:
-		opt = &OptionGeneric{OptionCode: code, OptionData: optData}
+		opt = &OptionGeneric{OptionCode: code, OptionData: append([]byte(nil), optData...)}
 	}
 	if err != nil {
 		return nil, err
@@ -102,6 +158,22 @@ func (o Options) Get(code OptionCode) []Option {
 	return ret
 }
 
+// GetMany returns all options matching any of the option codes, grouped by
+// code, in a single pass over the options. Codes without a matching option
+// are not present in the returned map.
+func (o Options) GetMany(codes ...OptionCode) map[OptionCode][]Option {
+	ret := make(map[OptionCode][]Option, len(codes))
+	for _, opt := range o {
+		for _, code := range codes {
+			if opt.Code() == code {
+				ret[code] = append(ret[code], opt)
+				break
+			}
+		}
+	}
+	return ret
+}
+
 // GetOne returns the first option matching the option code.
 func (o Options) GetOne(code OptionCode) Option {
 	for _, opt := range o {
@@ -154,6 +226,19 @@ func (o Options) ToBytes() []byte {
 	return buf.Data()
 }
 
+// Validate calls Validate on each option of o that implements Validator, and
+// returns the first error.
+func (o Options) Validate() error {
+	for _, opt := range o {
+		if v, ok := opt.(Validator); ok {
+			if err := v.Validate(); err != nil {
+				return fmt.Errorf("invalid %s: %v", opt.Code(), err)
+			}
+		}
+	}
+	return nil
+}
+
 // FromBytes reads data into o and returns an error if the options are not a
 // valid serialized representation of DHCPv6 options per RFC 3315.
 func (o *Options) FromBytes(data []byte) error {
@@ -165,6 +250,10 @@ type OptionParser func(code OptionCode, data []byte) (Option, error)
 
 // FromBytesWithParser parses Options from byte sequences using the parsing
 // function that is passed in as a paremeter
+//
+// An error is returned if an option is truncated, or if data ends with 1 to 3
+// bytes, too short for an option header. This applies to the options of
+// messages and relay messages as well as to those of IAs.
 func (o *Options) FromBytesWithParser(data []byte, parser OptionParser) error {
 	*o = make(Options, 0, 10)
 	if len(data) == 0 {
@@ -176,6 +265,10 @@ func (o *Options) FromBytesWithParser(data []byte, parser OptionParser) error {
 	for buf.Has(4) {
 		code := OptionCode(buf.Read16())
 		length := int(buf.Read16())
+		if !buf.Has(length) {
+			// Truncated, e.g. by a buggy relay agent.
+			return fmt.Errorf("option %s declares %d bytes, only %d left", code, length, buf.Len())
+		}
 
 		// Consume, but do not Copy. Each parser will make a copy of
 		// pertinent data.
@@ -187,5 +280,8 @@ func (o *Options) FromBytesWithParser(data []byte, parser OptionParser) error {
 		}
 		*o = append(*o, opt)
 	}
+	if buf.Len() > 0 {
+		return fmt.Errorf("%d trailing bytes are too short for an option header", buf.Len())
+	}
 	return buf.FinError()
 }
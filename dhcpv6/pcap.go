@@ -0,0 +1,160 @@
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// LinkType is the link-layer header type of a pcap file, as listed at
+// https://www.tcpdump.org/linktypes.html.
+type LinkType uint32
+
+// Link-layer header types supported by WritePCAP.
+const (
+	LinkTypeEthernet LinkType = 1
+	LinkTypeRaw      LinkType = 101
+)
+
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapSnapLen      = 65535
+	ipv6HeaderLen    = 40
+	udpHeaderLen     = 8
+	ethernetLen      = 14
+	etherTypeIPv6    = 0x86dd
+	ipProtocolUDP    = 17
+	defaultHopLimit  = 64
+	pcapRecordHeader = 16
+)
+
+// CapturedMessage is a DHCPv6 message along with the addresses it was sent
+// from and to, as written by WritePCAP.
+type CapturedMessage struct {
+	Timestamp time.Time
+	Src, Dst  *net.UDPAddr
+
+	// SrcMAC and DstMAC are only used with LinkTypeEthernet. If DstMAC is
+	// nil and Dst is a multicast address, the corresponding 33:33:xx:xx:xx:xx
+	// address is used. Missing addresses are written as all zeros.
+	SrcMAC, DstMAC net.HardwareAddr
+
+	Message DHCPv6
+}
+
+// WritePCAP writes msgs to w as a pcap file with the given link-layer header
+// type, wrapping each message in synthetic IPv6 and UDP headers (and an
+// Ethernet header for LinkTypeEthernet) built from its addresses. The result
+// can be opened with tools such as Wireshark or tcpdump.
+func WritePCAP(w io.Writer, linkType LinkType, msgs []CapturedMessage) error {
+	if linkType != LinkTypeEthernet && linkType != LinkTypeRaw {
+		return fmt.Errorf("unsupported link type %d", linkType)
+	}
+
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], uint32(linkType))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	for i, m := range msgs {
+		frame, err := m.frame(linkType)
+		if err != nil {
+			return fmt.Errorf("message %d: %v", i, err)
+		}
+		rec := make([]byte, pcapRecordHeader, pcapRecordHeader+len(frame))
+		binary.LittleEndian.PutUint32(rec[0:], uint32(m.Timestamp.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:], uint32(m.Timestamp.Nanosecond()/1000))
+		binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+		binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+		if _, err := w.Write(append(rec, frame...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frame returns the message wrapped in link-layer, IPv6 and UDP headers.
+func (m *CapturedMessage) frame(linkType LinkType) ([]byte, error) {
+	if m.Message == nil {
+		return nil, fmt.Errorf("message cannot be nil")
+	}
+	if m.Src == nil || m.Dst == nil {
+		return nil, fmt.Errorf("source and destination addresses are required")
+	}
+	src, dst := m.Src.IP.To16(), m.Dst.IP.To16()
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("invalid source or destination address")
+	}
+
+	payload := m.Message.ToBytes()
+	udpLen := udpHeaderLen + len(payload)
+	if udpLen > 0xffff {
+		return nil, fmt.Errorf("message too long: %d bytes", len(payload))
+	}
+
+	var b []byte
+	if linkType == LinkTypeEthernet {
+		eth := make([]byte, ethernetLen)
+		dstMAC := m.DstMAC
+		if dstMAC == nil && dst.IsMulticast() {
+			dstMAC = net.HardwareAddr{0x33, 0x33, dst[12], dst[13], dst[14], dst[15]}
+		}
+		copy(eth[0:6], dstMAC)
+		copy(eth[6:12], m.SrcMAC)
+		binary.BigEndian.PutUint16(eth[12:], etherTypeIPv6)
+		b = eth
+	}
+
+	ip := make([]byte, ipv6HeaderLen)
+	ip[0] = 0x60
+	binary.BigEndian.PutUint16(ip[4:], uint16(udpLen))
+	ip[6] = ipProtocolUDP
+	ip[7] = defaultHopLimit
+	copy(ip[8:24], src)
+	copy(ip[24:40], dst)
+
+	udp := make([]byte, udpHeaderLen, udpLen)
+	binary.BigEndian.PutUint16(udp[0:], uint16(m.Src.Port))
+	binary.BigEndian.PutUint16(udp[2:], uint16(m.Dst.Port))
+	binary.BigEndian.PutUint16(udp[4:], uint16(udpLen))
+	udp = append(udp, payload...)
+	binary.BigEndian.PutUint16(udp[6:], udpChecksum(src, dst, udp))
+
+	b = append(b, ip...)
+	return append(b, udp...), nil
+}
+
+// udpChecksum computes the checksum of a UDP datagram over IPv6, as defined
+// by RFC 8200, Section 8.1. The checksum field of udp must be zero.
+func udpChecksum(src, dst net.IP, udp []byte) uint16 {
+	var sum uint32
+	add := func(b []byte) {
+		for i := 0; i+1 < len(b); i += 2 {
+			sum += uint32(b[i])<<8 | uint32(b[i+1])
+		}
+		if len(b)%2 == 1 {
+			sum += uint32(b[len(b)-1]) << 8
+		}
+	}
+	add(src)
+	add(dst)
+	sum += uint32(len(udp))
+	sum += ipProtocolUDP
+	add(udp)
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	cs := ^uint16(sum)
+	if cs == 0 {
+		// A zero checksum is transmitted as all ones.
+		cs = 0xffff
+	}
+	return cs
+}
@@ -0,0 +1,88 @@
+package dhcpv6
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePCAP(t *testing.T) {
+	client := &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: DefaultClientPort}
+	server := &net.UDPAddr{IP: net.ParseIP("fe80::2"), Port: DefaultServerPort}
+	multicast := &net.UDPAddr{IP: net.ParseIP("ff02::1:2"), Port: DefaultServerPort}
+	solicit := &Message{MessageType: MessageTypeSolicit, TransactionID: TransactionID{1, 2, 3}}
+	solicit.AddOption(&OptElapsedTime{})
+	advertise := &Message{MessageType: MessageTypeAdvertise, TransactionID: TransactionID{1, 2, 3}}
+	ts := time.Unix(1500000000, 123456000)
+
+	msgs := []CapturedMessage{
+		{Timestamp: ts, Src: client, Dst: multicast, SrcMAC: net.HardwareAddr{1, 2, 3, 4, 5, 6}, Message: solicit},
+		{Timestamp: ts, Src: server, Dst: client, Message: advertise},
+	}
+
+	for _, tt := range []struct {
+		linkType LinkType
+		linkLen  int
+	}{
+		{LinkTypeEthernet, ethernetLen},
+		{LinkTypeRaw, 0},
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, WritePCAP(&buf, tt.linkType, msgs))
+		b := buf.Bytes()
+
+		require.Equal(t, uint32(pcapMagic), binary.LittleEndian.Uint32(b[0:]))
+		require.Equal(t, uint32(tt.linkType), binary.LittleEndian.Uint32(b[20:]))
+		b = b[24:]
+
+		for i, m := range msgs {
+			require.Equal(t, uint32(ts.Unix()), binary.LittleEndian.Uint32(b[0:]))
+			require.Equal(t, uint32(123456), binary.LittleEndian.Uint32(b[4:]))
+			n := int(binary.LittleEndian.Uint32(b[8:]))
+			frame := b[pcapRecordHeader : pcapRecordHeader+n]
+			b = b[pcapRecordHeader+n:]
+
+			if tt.linkType == LinkTypeEthernet {
+				if i == 0 {
+					require.Equal(t, []byte{0x33, 0x33, 0, 1, 0, 2}, frame[0:6])
+					require.Equal(t, []byte{1, 2, 3, 4, 5, 6}, frame[6:12])
+				}
+				require.Equal(t, uint16(etherTypeIPv6), binary.BigEndian.Uint16(frame[12:]))
+			}
+			ip := frame[tt.linkLen:]
+			require.Equal(t, byte(0x60), ip[0])
+			require.Equal(t, byte(ipProtocolUDP), ip[6])
+			require.True(t, m.Src.IP.Equal(ip[8:24]))
+			require.True(t, m.Dst.IP.Equal(ip[24:40]))
+
+			udp := ip[ipv6HeaderLen:]
+			require.Equal(t, uint16(m.Src.Port), binary.BigEndian.Uint16(udp[0:]))
+			require.Equal(t, uint16(m.Dst.Port), binary.BigEndian.Uint16(udp[2:]))
+			require.Equal(t, len(udp), int(binary.BigEndian.Uint16(udp[4:])))
+			// Checksumming a datagram including a valid checksum yields
+			// zero, which udpChecksum reports as all ones.
+			require.Equal(t, uint16(0xffff), udpChecksum(ip[8:24], ip[24:40], udp))
+
+			parsed, err := MessageFromBytes(udp[udpHeaderLen:])
+			require.NoError(t, err)
+			require.Equal(t, m.Message.ToBytes(), parsed.ToBytes())
+		}
+		require.Empty(t, b)
+	}
+}
+
+func TestWritePCAPErrors(t *testing.T) {
+	var buf bytes.Buffer
+	require.Error(t, WritePCAP(&buf, LinkType(228), nil))
+
+	msg := &Message{MessageType: MessageTypeSolicit}
+	require.Error(t, WritePCAP(&buf, LinkTypeRaw, []CapturedMessage{{Message: msg}}))
+	require.Error(t, WritePCAP(&buf, LinkTypeRaw, []CapturedMessage{{
+		Src: &net.UDPAddr{IP: net.ParseIP("fe80::1")},
+		Dst: &net.UDPAddr{IP: net.ParseIP("fe80::2")},
+	}}))
+}
@@ -36,6 +36,8 @@ const (
 	MessageTypeLeaseQueryReply    MessageType = 15
 	MessageTypeLeaseQueryDone     MessageType = 16
 	MessageTypeLeaseQueryData     MessageType = 17
+	MessageTypeDHCPv4Query        MessageType = 20
+	MessageTypeDHCPv4Response     MessageType = 21
 )
 
 // String prints the message type name.
@@ -66,17 +68,16 @@ var messageTypeToStringMap = map[MessageType]string{
 	MessageTypeLeaseQueryReply:    "LEASEQUERY-REPLY",
 	MessageTypeLeaseQueryDone:     "LEASEQUERY-DONE",
 	MessageTypeLeaseQueryData:     "LEASEQUERY-DATA",
+	MessageTypeDHCPv4Query:        "DHCPV4-QUERY",
+	MessageTypeDHCPv4Response:     "DHCPV4-RESPONSE",
 }
 
 // OptionCode is a single byte representing the code for a given Option.
 type OptionCode uint16
 
-// String returns the option code name.
+// String returns the option code name. See OptionCodeName.
 func (o OptionCode) String() string {
-	if s, ok := optionCodeToString[o]; ok {
-		return s
-	}
-	return fmt.Sprintf("unknown (%d)", o)
+	return OptionCodeName(o)
 }
 
 // All DHCPv6 options.
@@ -154,9 +155,21 @@ const (
 	OptionMIPv6HomeNetworkPrefix                  OptionCode = 71
 	OptionMIPv6HomeAgentAddress                   OptionCode = 72
 	OptionMIPv6HomeAgentFQDN                      OptionCode = 73
+	OptionDHCPv4Msg                               OptionCode = 87
+	OptionDHCP4oDHCP6Server                       OptionCode = 88
+	OptionS46Rule                                 OptionCode = 89
+	OptionS46BR                                   OptionCode = 90
+	OptionS46DMR                                  OptionCode = 91
+	OptionS46V4V6Bind                             OptionCode = 92
+	OptionS46PortParams                           OptionCode = 93
+	OptionS46ContMapE                             OptionCode = 94
+	OptionS46ContMapT                             OptionCode = 95
+	OptionS46ContLW                               OptionCode = 96
+	OptionCaptivePortal                           OptionCode = 103
 )
 
-// optionCodeToString maps DHCPv6 OptionCodes to human-readable strings.
+// optionCodeToString maps DHCPv6 OptionCodes to their names in the IANA
+// registry.
 var optionCodeToString = map[OptionCode]string{
 	OptionClientID:                                "OPTION_CLIENTID",
 	OptionServerID:                                "OPTION_SERVERID",
@@ -177,40 +190,40 @@ var optionCodeToString = map[OptionCode]string{
 	OptionInterfaceID:                             "OPTION_INTERFACE_ID",
 	OptionReconfMessage:                           "OPTION_RECONF_MSG",
 	OptionReconfAccept:                            "OPTION_RECONF_ACCEPT",
-	OptionSIPServersDomainNameList:                "SIP Servers Domain Name List",
-	OptionSIPServersIPv6AddressList:               "SIP Servers IPv6 Address List",
-	OptionDNSRecursiveNameServer:                  "DNS Recursive Name Server",
-	OptionDomainSearchList:                        "Domain Search List",
+	OptionSIPServersDomainNameList:                "OPTION_SIP_SERVER_D",
+	OptionSIPServersIPv6AddressList:               "OPTION_SIP_SERVER_A",
+	OptionDNSRecursiveNameServer:                  "OPTION_DNS_SERVERS",
+	OptionDomainSearchList:                        "OPTION_DOMAIN_LIST",
 	OptionIAPD:                                    "OPTION_IA_PD",
 	OptionIAPrefix:                                "OPTION_IAPREFIX",
 	OptionNISServers:                              "OPTION_NIS_SERVERS",
 	OptionNISPServers:                             "OPTION_NISP_SERVERS",
 	OptionNISDomainName:                           "OPTION_NIS_DOMAIN_NAME",
 	OptionNISPDomainName:                          "OPTION_NISP_DOMAIN_NAME",
-	OptionSNTPServerList:                          "SNTP Server List",
-	OptionInformationRefreshTime:                  "Information Refresh Time",
-	OptionBCMCSControllerDomainNameList:           "BCMCS Controller Domain Name List",
-	OptionBCMCSControllerIPv6AddressList:          "BCMCS Controller IPv6 Address List",
-	OptionGeoConfCivic:                            "OPTION_GEOCONF",
+	OptionSNTPServerList:                          "OPTION_SNTP_SERVERS",
+	OptionInformationRefreshTime:                  "OPTION_INFORMATION_REFRESH_TIME",
+	OptionBCMCSControllerDomainNameList:           "OPTION_BCMCS_SERVER_D",
+	OptionBCMCSControllerIPv6AddressList:          "OPTION_BCMCS_SERVER_A",
+	OptionGeoConfCivic:                            "OPTION_GEOCONF_CIVIC",
 	OptionRemoteID:                                "OPTION_REMOTE_ID",
-	OptionRelayAgentSubscriberID:                  "Relay-Agent Subscriber ID",
-	OptionFQDN:                                    "FQDN",
-	OptionPANAAuthenticationAgent:                 "PANA Authentication Agent",
-	OptionNewPOSIXTimezone:                        "OPTION_NEW_POSIX_TIME_ZONE",
+	OptionRelayAgentSubscriberID:                  "OPTION_SUBSCRIBER_ID",
+	OptionFQDN:                                    "OPTION_CLIENT_FQDN",
+	OptionPANAAuthenticationAgent:                 "OPTION_PANA_AGENT",
+	OptionNewPOSIXTimezone:                        "OPTION_NEW_POSIX_TIMEZONE",
 	OptionNewTZDBTimezone:                         "OPTION_NEW_TZDB_TIMEZONE",
-	OptionEchoRequest:                             "Echo Request",
+	OptionEchoRequest:                             "OPTION_ERO",
 	OptionLQQuery:                                 "OPTION_LQ_QUERY",
 	OptionClientData:                              "OPTION_CLIENT_DATA",
 	OptionCLTTime:                                 "OPTION_CLT_TIME",
 	OptionLQRelayData:                             "OPTION_LQ_RELAY_DATA",
 	OptionLQClientLink:                            "OPTION_LQ_CLIENT_LINK",
-	OptionMIPv6HomeNetworkIDFQDN:                  "MIPv6 Home Network ID FQDN",
-	OptionMIPv6VisitedHomeNetworkInformation:      "MIPv6 Visited Home Network Information",
-	OptionLoSTServer:                              "LoST Server",
-	OptionCAPWAPAccessControllerAddresses:         "CAPWAP Access Controller Addresses",
-	OptionRelayID:                                 "RELAY_ID",
+	OptionMIPv6HomeNetworkIDFQDN:                  "OPTION_MIP6_HNIDF",
+	OptionMIPv6VisitedHomeNetworkInformation:      "OPTION_MIP6_VDINF",
+	OptionLoSTServer:                              "OPTION_V6_LOST",
+	OptionCAPWAPAccessControllerAddresses:         "OPTION_CAPWAP_AC_V6",
+	OptionRelayID:                                 "OPTION_RELAY_ID",
 	OptionIPv6AddressMOS:                          "OPTION-IPv6_Address-MoS",
-	OptionIPv6FQDNMOS:                             "OPTION-IPv6-FQDN-MoS",
+	OptionIPv6FQDNMOS:                             "OPTION-IPv6_FQDN-MoS",
 	OptionNTPServer:                               "OPTION_NTP_SERVER",
 	OptionV6AccessDomain:                          "OPTION_V6_ACCESS_DOMAIN",
 	OptionSIPUACSList:                             "OPTION_SIP_UA_CS_LIST",
@@ -223,10 +236,21 @@ var optionCodeToString = map[OptionCode]string{
 	OptionERPLocalDomainName:                      "OPTION_ERP_LOCAL_DOMAIN_NAME",
 	OptionRSOO:                                    "OPTION_RSOO",
 	OptionPDExclude:                               "OPTION_PD_EXCLUDE",
-	OptionVirtualSubnetSelection:                  "Virtual Subnet Selection",
-	OptionMIPv6IdentifiedHomeNetworkInformation:   "MIPv6 Identified Home Network Information",
-	OptionMIPv6UnrestrictedHomeNetworkInformation: "MIPv6 Unrestricted Home Network Information",
-	OptionMIPv6HomeNetworkPrefix:                  "MIPv6 Home Network Prefix",
-	OptionMIPv6HomeAgentAddress:                   "MIPv6 Home Agent Address",
-	OptionMIPv6HomeAgentFQDN:                      "MIPv6 Home Agent FQDN",
+	OptionVirtualSubnetSelection:                  "OPTION_VSS",
+	OptionMIPv6IdentifiedHomeNetworkInformation:   "OPTION_MIP6_IDINF",
+	OptionMIPv6UnrestrictedHomeNetworkInformation: "OPTION_MIP6_UDINF",
+	OptionMIPv6HomeNetworkPrefix:                  "OPTION_MIP6_HNP",
+	OptionMIPv6HomeAgentAddress:                   "OPTION_MIP6_HAA",
+	OptionMIPv6HomeAgentFQDN:                      "OPTION_MIP6_HAF",
+	OptionDHCPv4Msg:                               "OPTION_DHCPV4_MSG",
+	OptionDHCP4oDHCP6Server:                       "OPTION_DHCP4_O_DHCP6_SERVER",
+	OptionS46Rule:                                 "OPTION_S46_RULE",
+	OptionS46BR:                                   "OPTION_S46_BR",
+	OptionS46DMR:                                  "OPTION_S46_DMR",
+	OptionS46V4V6Bind:                             "OPTION_S46_V4V6BIND",
+	OptionS46PortParams:                           "OPTION_S46_PORTPARAMS",
+	OptionS46ContMapE:                             "OPTION_S46_CONT_MAPE",
+	OptionS46ContMapT:                             "OPTION_S46_CONT_MAPT",
+	OptionS46ContLW:                               "OPTION_S46_CONT_LW",
+	OptionCaptivePortal:                           "OPTION_V6_CAPTIVE_PORTAL",
 }
@@ -81,6 +81,12 @@ var hwTypeToString = map[HWType]string{
 	HWTypePureIP:               "Pure IP",
 }
 
+// IsKnown returns whether h is one of the hardware types defined by the IANA.
+func (h HWType) IsKnown() bool {
+	_, ok := hwTypeToString[h]
+	return ok
+}
+
 // String implements fmt.Stringer.
 func (h HWType) String() string {
 	hwtype := hwTypeToString[h]